@@ -2,11 +2,16 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/0gfoundation/0g-storage-client/cmd"
@@ -17,136 +22,952 @@ import (
 const (
 	FragmentSize = 400 * 1024 * 1024 // 400 MB
 	FragmentNum  = 10                // 目标切成 10 片
+
+	DefaultMaxFragments = 10000 // 分片数上限，防止过小的 --fragment-size 产生海量分片
 )
 
 var (
-	rpcURL     string // 0G Chain RPC
-	privateKey string // 私钥（不带0x）
-	filePath   string // 要上传的 4GB 文件路径
-	indexerURL string // indexer 地址，推荐使用
+	rpcURL       string // 0G Chain RPC
+	privateKey   string // 私钥（不带0x）
+	filePath     string // 要上传的 4GB 文件路径
+	indexerURL   string // indexer 地址，推荐使用
+	manifestPath string // manifest 输出路径，记录每个分片的 offset/size/root；"-" 表示写到标准输出
+	appendTo     string // 追加到已有的多文件 manifest，而不是单独写一个
+	namespace    string // 多团队共用 manifest / 目录时的隔离前缀
+	paranoid     bool   // 切分后再完整读一遍源文件，检测切分期间被修改
+
+	fragmentSize int64  // 每个分片的大小
+	maxFragments int    // 分片数上限
+	padLast      bool   // 最后一个分片补零到完整大小
+	dumpOptions  bool   // 上传每个分片前打印传给 SDK 的完整参数
+	noVerify     bool   // 不计算整文件 MD5，也不校验恢复结果
+	appTag       string // --app-tag：随每个分片的 submission 提交的应用标识
+
+	ioBufferSize int64 = 4 << 20 // --io-buffer：切分和合并时的复制缓冲区大小
+
+	concurrency         int  // 同时传输的分片数，上传/下载没有单独指定时使用
+	uploadConcurrency   int  // --upload-concurrency，0 表示沿用 --concurrency
+	downloadConcurrency int  // --download-concurrency，0 表示沿用 --concurrency
+	concurrencyAuto     bool // 根据实测吞吐自动调节并发数
 )
 
+// Fragment 描述一个本地分片文件及其在原始文件中的位置
+type Fragment struct {
+	Index  int
+	Path   string
+	Offset int64
+	Size   int64
+	Parity bool   // 纠删码的校验分片，不对应原始文件中的某一段
+	Hash   string // 按 --fragment-hash 计算的分片哈希（--pad-last 时按补零后的内容计算）
+
+	// --pad-last 时最后一个分片末尾补的零字节数，Size 仍是原始数据长度
+	Padding int64
+
+	ContentHash string // --content-addressed 时分片内容的 sha256，也是本地文件名
+	Source      string // 多文件模式下所属的源文件
+
+	// --compress 时 Path 指向压缩后的文件，Codec 为空表示没有压缩
+	Codec          string
+	CompressedSize int64
+
+	Zero bool // --skip-zero-fragments 时全零、不上传的分片
+}
+
 func main() {
+	err := newRootCmd().Execute()
+	webhook.flush()
+	cancelRun()
+	stopMetrics()
+	stopProfiling()
+	if err != nil {
+		logrus.Error(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// 根命令和全部子命令。flags 注册时就会把对应的全局变量设为默认值，测试里重新调用一次即可复位
+func newRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "split-upload-4g",
 		Short: "将 4GB 文件切分成 10 个 400MB 分片并使用 0g-storage-client 上传/下载",
-		Run: func(c *cobra.Command, args []string) {
-			if err := run(); err != nil {
-				logrus.Fatal(err)
+		Long:  "将 4GB 文件切分成 10 个 400MB 分片并使用 0g-storage-client 上传/下载\n\n" + exitCodeHelp,
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true // 走到这里参数已经解析成功，运行期错误不用再打印用法
+			compressSet = c.Flags().Changed("compress")
+			paths, err := dedupeFilePaths(filePaths)
+			if err != nil {
+				return configError(err)
+			}
+			filePaths = paths
+			if len(filePaths) > 1 {
+				return runMulti(filePaths)
+			}
+			if len(filePaths) == 1 {
+				filePath = filePaths[0]
 			}
+			return run()
 		},
+		PersistentPreRunE: func(c *cobra.Command, args []string) error {
+			if manifestPath == stdoutManifest {
+				reserveStdoutForManifest()
+			}
+			if err := setupLogging(); err != nil {
+				return configError(err)
+			}
+			if err := applyEnv(c); err != nil {
+				return configError(err)
+			}
+			if err := normalizeEndpoints(); err != nil {
+				return configError(err)
+			}
+			if err := validateGasFlags(c); err != nil {
+				return configError(err)
+			}
+			if err := parseAssumedThroughput(); err != nil {
+				return configError(err)
+			}
+			if err := validateWebhook(); err != nil {
+				return configError(err)
+			}
+			if ioBufferSize <= 0 {
+				return configError(fmt.Errorf("--io-buffer 必须大于 0: %d", ioBufferSize))
+			}
+			if err := applyMemoryLimit(); err != nil {
+				return configError(err)
+			}
+			setupRunContext()
+			if err := validateNamespace(namespace); err != nil {
+				return configError(err)
+			}
+			if err := setupHTTPClient(); err != nil {
+				return configError(err)
+			}
+			if gcOnStart {
+				if _, err := sweepTempDirs(os.TempDir(), gcOlderThan, false); err != nil {
+					logrus.Warnf("清理遗留临时目录失败: %v", err)
+				}
+			}
+			stop, err := startMetricsServer(metricsAddr)
+			if err != nil {
+				return configError(err)
+			}
+			stopMetrics = stop
+			if stopProfiling, err = startProfiling(); err != nil {
+				stopProfiling = func() {}
+				return configError(err)
+			}
+			return nil
+		},
+		SilenceErrors: true, // 错误统一由 main 打印
 	}
 
-	rootCmd.Flags().StringVar(&rpcURL, "rpc", "https://rpc.0g.ai", "0G Chain RPC URL")
+	rootCmd.PersistentFlags().StringVar(&rpcURL, "rpc", knownEnvs[defaultEnv].RPC, "0G Chain RPC URL")
+	rootCmd.PersistentFlags().StringVar(&indexerURL, "indexer", knownEnvs[defaultEnv].Indexer, "0G Storage Indexer URL")
+	rootCmd.PersistentFlags().StringVar(&envName, "env", "", "预置网络环境: mainnet / testnet / custom，自动填充 --rpc 和 --indexer（显式指定的优先）")
+	rootCmd.PersistentFlags().StringVar(&namespace, "namespace", "", "命名空间：写入 manifest 并作为本地分片文件名前缀，下载时只在该命名空间内查找")
+	rootCmd.PersistentFlags().DurationVar(&slowThreshold, "slow-threshold", 0, "单个分片上传/下载超过该时长（如 5m）时在汇总中标出，0 表示不检查")
+	rootCmd.PersistentFlags().DurationVar(&runTimeout, "timeout", 0, "整个运行的总时长上限，0 表示不限制")
+	rootCmd.PersistentFlags().DurationVar(&perFragmentTimeout, "per-fragment-timeout", 0, "单个分片一次上传/下载的时长上限，超时后重试（默认沿用 SDK 的 30m 上传 / 20m 下载）")
+	rootCmd.PersistentFlags().StringVar(&throughputSpec, "assumed-throughput", "", "按假定吞吐（例如 10M/s）放宽单个分片的超时: --per-fragment-timeout（默认 1m）+ 分片大小 / 吞吐")
+	rootCmd.PersistentFlags().IntVar(&fragmentRetries, "retries", 2, "单个分片失败或超时后的重试次数")
+	rootCmd.PersistentFlags().Int64Var(&maxTotalRetries, "max-total-retries", 0, "整个运行所有分片共享的重试次数上限，用完后再失败即终止，0 表示不限制")
+	rootCmd.PersistentFlags().StringVar(&onSuccessHook, "on-success", "", "恢复并校验成功后执行的 shell 命令，恢复文件路径在环境变量 OG_RESTORED_PATH 中")
+	rootCmd.PersistentFlags().StringVar(&onFailureHook, "on-failure", "", "恢复或校验失败后执行的 shell 命令，失败原因在 OG_RESTORE_ERROR 中")
+	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", "", "本程序访问 RPC / indexer / 存储节点使用的 HTTP 代理，例如 http://127.0.0.1:8080（SDK 上传下载请用 HTTPS_PROXY 环境变量）")
+	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "本程序的 RPC / indexer 请求跳过 TLS 证书校验（不安全，仅用于调试；不影响 SDK）")
+	rootCmd.PersistentFlags().Int64Var(&ioBufferSize, "io-buffer", 4<<20, "切分和合并时的复制缓冲区字节数，和分片大小无关")
+	rootCmd.PersistentFlags().Int64Var(&gasPrice, "gas-price", 0, "交易 gas price（wei），默认由 SDK 决定")
+	rootCmd.PersistentFlags().Int64Var(&gasPriceBump, "gas-price-bump", 0, "在 gas price 之上再加的 wei（legacy 交易，不是 EIP-1559 小费）；不指定 --gas-price 时以节点当前 gas price 为基础")
+	rootCmd.PersistentFlags().Int64Var(&gasPriceBump, "priority-fee", 0, "同 --gas-price-bump")
+	rootCmd.PersistentFlags().MarkDeprecated("priority-fee", "SDK 只发 legacy 交易，请改用 --gas-price-bump")
+	rootCmd.PersistentFlags().StringVar(&maxMemory, "max-memory", "", "内存上限（如 512M、2G），据此缩小 --io-buffer 和并发数，保证 并发数 × 缓冲区 不超过上限")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 1, "同时传输的分片数（上传、下载没有单独指定时都用它）")
+	rootCmd.PersistentFlags().IntVar(&uploadConcurrency, "upload-concurrency", 0, "同时上传的分片数，0 表示沿用 --concurrency")
+	rootCmd.PersistentFlags().BoolVar(&failFast, "fail-fast", false, "有分片上传失败后不再开始新的分片（默认其余分片照常上传，成功的记进 checkpoint）")
+	rootCmd.PersistentFlags().IntVar(&downloadConcurrency, "download-concurrency", 0, "--sparse-restore 时同时下载的分片数，0 表示沿用 --concurrency")
+	rootCmd.PersistentFlags().BoolVar(&sidecarHashes, "sidecar-hashes", false, "切分时为每个分片写 <分片>.sha256，放在 <文件>.sidecars/ 目录（download --output-dir 时写在输出的分片旁）")
+	rootCmd.PersistentFlags().StringVar(&dumpBadDir, "dump-bad-fragments", "", "下载的分片哈希校验失败时，重试前把收到的数据和期望/实际哈希（.json）保存到该目录")
+	rootCmd.PersistentFlags().BoolVar(&strictOrder, "strict-order", false, "恢复后按 manifest 的 offset 从最终文件逐个重算分片哈希，检测分片被写到错误位置")
+	rootCmd.PersistentFlags().BoolVar(&sparseRestore, "sparse-restore", false, "恢复时预分配输出文件，分片按 offset 乱序并行写入（并行数见 --download-concurrency），不再顺序拼接")
+	rootCmd.PersistentFlags().IntVar(&fragmentDownloadParallelism, "fragment-download-parallelism", 1, "每个分片拆成多少段并发直接从存储节点下载，1 表示整体交给 SDK 下载")
+	rootCmd.PersistentFlags().IntVar(&fragmentUploadParallelism, "fragment-upload-parallelism", 1, "单个分片内部并发提交 segment 的 goroutine 数（SDK 的 --routines），1 表示使用 SDK 默认值")
+	rootCmd.PersistentFlags().BoolVar(&gcOnStart, "gc-on-start", false, "启动时先清理以前崩溃遗留的临时目录（见 gc 子命令）")
+	rootCmd.PersistentFlags().DurationVar(&gcOlderThan, "gc-older-than", 24*time.Hour, "gc 只清理早于这个时长的临时目录，避免删到正在运行的任务")
+	rootCmd.PersistentFlags().BoolVar(&parallelVerify, "parallel-verify", false, "恢复后按分片边界多核并行校验分片哈希，代替单线程整文件 MD5（纠删码或缺少分片哈希时仍用 MD5）")
+	rootCmd.PersistentFlags().StringVar(&logFilePath, "log-file", "", "同时把日志以 JSON 格式追加写到该文件，按 --log-max-size 轮转")
+	rootCmd.PersistentFlags().StringVar(&logMaxSize, "log-max-size", logMaxSize, "--log-file 超过该大小（例如 100M）时轮转")
+	rootCmd.PersistentFlags().IntVar(&logBackups, "log-backups", logBackups, "--log-file 轮转后保留的旧文件份数")
+	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "终端只输出错误日志（--log-file 仍记录全部日志）")
+	rootCmd.PersistentFlags().StringVar(&cpuProfilePath, "cpuprofile", "", "把整个运行的 CPU profile 写到该文件（go tool pprof 查看）")
+	rootCmd.PersistentFlags().StringVar(&memProfilePath, "memprofile", "", "运行结束时把内存（heap）profile 写到该文件")
+	rootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "在该地址（如 :9100）提供 Prometheus /metrics，运行结束时关闭")
+	rootCmd.PersistentFlags().DurationVar(&httpTimeout, "http-timeout", 0, "本程序单个 RPC / indexer 请求的超时，0 表示不限制")
 	rootCmd.Flags().StringVar(&privateKey, "key", "", "私钥（必填）")
-	rootCmd.Flags().StringVar(&filePath, "file", "", "要上传的 4GB 文件路径（必填）")
-	rootCmd.Flags().StringVar(&indexerURL, "indexer", "https://indexer.0g.ai", "0G Storage Indexer URL")
+	rootCmd.Flags().StringArrayVar(&filePaths, "file", nil, "要上传的文件路径，可重复指定多个文件（和 --dir 二选一）")
+	rootCmd.Flags().BoolVar(&allowDuplicates, "allow-duplicates", false, "同一个 --file 路径给了多次时按第一次出现的位置去重，不报错")
+	rootCmd.Flags().BoolVar(&streamTar, "stream", false, "配合 --dir：tar 流直接切分上传，磁盘上最多只有一个分片（串行上传）")
+	rootCmd.Flags().StringVar(&dirPath, "dir", "", "要上传的目录，先打成 tar（保留权限和 mtime）再切分（和 --file 二选一）")
+	rootCmd.Flags().StringVar(&manifestPath, "manifest", "", "manifest 输出路径（默认 <file>.manifest.json）；- 表示写到标准输出，其余输出全部改到标准错误")
+	rootCmd.Flags().StringVar(&appendTo, "append-to-manifest", "", "把本文件的分片信息追加到多文件 manifest（不存在则新建），按文件名区分")
+	rootCmd.Flags().Int64Var(&fragmentSize, "fragment-size", FragmentSize, "每个分片的字节数")
+	rootCmd.Flags().IntVar(&maxFragments, "max-fragments", DefaultMaxFragments, "分片数上限，超出时在切分前直接报错")
+	rootCmd.Flags().StringVar(&erasureSpec, "erasure", "", "Reed-Solomon 纠删码 k:m，编码成 m 个分片，任意 k 个即可恢复（例如 10:14）")
+	rootCmd.Flags().StringVar(&fragmentHashAlgo, "fragment-hash", "sha256", "单个分片的校验算法: crc32 / xxhash / sha256（与整文件 MD5 独立）")
+	rootCmd.Flags().StringVar(&indexCSVPath, "index-csv", "", "额外写一份 CSV 分片索引（index,offset,length,hash,root），方便表格工具使用")
+	rootCmd.Flags().BoolVar(&cdcMode, "cdc", false, "按内容定义分片边界（Gear 滚动哈希），平均大小为 --fragment-size，文件插入/删除内容后大部分分片不变")
+	rootCmd.Flags().StringVar(&offsetsPath, "offsets", "", "按 JSON 数组给出的字节边界切分（从 0 开始、严格递增、以文件大小结束），代替 --fragment-size")
+	rootCmd.Flags().BoolVar(&padLast, "pad-last", false, "最后一个分片补零到完整 --fragment-size，manifest 记录真实长度，恢复时截掉补零")
+	rootCmd.Flags().StringVar(&compressCodec, "compress", "zstd", "上传前压缩每个分片: none / gzip / zstd / lz4 / auto（auto 按取样压缩率逐个分片决定）；--erasure / --pad-last / --content-addressed / --stream / --pipeline 和多个 --file 时默认不压缩")
+	rootCmd.Flags().BoolVar(&logNonces, "log-nonces", false, "打印交易发送地址和每个分片提交前的 nonce，并记录到 manifest")
+	rootCmd.Flags().BoolVar(&skipZeroFragments, "skip-zero-fragments", false, "全零的分片不上传，manifest 标记 zero，下载时直接补零（适合稀疏文件）")
+	rootCmd.Flags().StringVar(&reportFormat, "report-format", "text", "上传完成后汇总的输出格式: text / json（RunSummary）/ table")
+	rootCmd.Flags().StringVar(&sha256SumsPath, "sha256sums", "", "恢复校验通过后把原始文件和恢复文件的 sha256 按 sha256sum 格式写到该路径，之后可用 sha256sum -c 校验")
+	rootCmd.Flags().BoolVar(&manifestLock, "manifest-lock", true, "运行期间对 manifest 和 checkpoint 加文件锁（<路径>.lock），另一个运行已持有时立即失败；--manifest-lock=false 关闭")
+	rootCmd.Flags().BoolVar(&pipelineUpload, "pipeline", false, "边切分边上传：分片写完就开始上传，不等整个文件切完；整文件 MD5 仍在切分时顺带计算")
+	rootCmd.Flags().DurationVar(&waitAvailable, "wait-available", 0, "上传后轮询每个 root 直到都能下载，参数为最长等待时长（如 10m），0 表示不等待")
+	rootCmd.Flags().StringVar(&sinceManifest, "since-manifest", "", "增量上传：校验文件前缀与该 manifest 一致后，只上传之后追加的数据，写出包含全部分片的新 manifest")
+	rootCmd.Flags().StringVar(&retrySubSize, "fragment-retry-different-size", "", "分片整片上传重试用完仍失败时，改为按这个大小（如 100M）分段上传")
+	rootCmd.Flags().BoolVar(&assumeYes, "yes", false, "不询问，直接确认上传花费（非交互环境必须指定）")
+	rootCmd.Flags().BoolVar(&skipBalanceCheck, "skip-balance-check", false, "上传前不检查账户余额是否足够支付存储费和 gas")
+	rootCmd.PersistentFlags().BoolVar(&dedupeAcross, "dedupe-across-manifests", false, "在本地记录已上传分片的 sha256 -> root，之后任何一次运行遇到同样内容的分片直接复用 root")
+	rootCmd.PersistentFlags().StringVar(&webhookURL, "webhook", "", "每个分片上传结束后向该地址 POST JSON 事件（index / root / size / status），发送失败会重试，不影响上传")
+	rootCmd.PersistentFlags().StringVar(&webhookSecret, "webhook-secret", "", "用该密钥对 webhook 请求体做 HMAC-SHA256，放在 X-Signature-256: sha256=<hex> 头里")
+	rootCmd.PersistentFlags().StringVar(&dedupeStorePath, "dedupe-store", "", "--dedupe-across-manifests 的记录文件（默认在用户缓存目录下）")
+	rootCmd.Flags().BoolVar(&publishManifest, "publish-manifest", false, "写完 manifest 后把它也上传为一个 submission（tags 带 file_root），之后只凭 file_root 就能用 recover-manifest 找回")
+	rootCmd.Flags().StringVar(&appTag, "app-tag", "", "应用标识，作为 SDK --tags 随每个分片的链上 submission 提交，并记录在 manifest 中")
+	rootCmd.Flags().BoolVar(&signManifest, "sign-manifest", false, "用 --key 对 manifest 签名（ECDSA over keccak256），记录签名者地址，download 时自动校验")
+	rootCmd.Flags().StringVar(&uploadOrder, "upload-order", "", "分片上传顺序: sequential（默认）/ head-tail / smallest-first")
+	rootCmd.Flags().BoolVar(&priorityFirst, "priority-first", false, "先上传首尾分片，等同 --upload-order head-tail")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "只切分并列出每个分片将要执行的 SDK 上传和构造出的 submission 交易（不上传、不发交易）")
+	rootCmd.Flags().BoolVar(&dumpOptions, "dump-options", false, "上传每个分片前打印传给 SDK upload 命令的完整参数（私钥打码），便于提交问题")
+	rootCmd.Flags().BoolVar(&noVerify, "no-verify", false, "不计算原始文件 MD5、不校验恢复文件，manifest 中没有整文件哈希；省两次完整读取，但无法发现拼接错误，只适合一次性测试数据")
+	rootCmd.Flags().BoolVar(&contentAddressed, "content-addressed", false, "本地分片按内容 sha256 命名为 <sha256>.frag，内容相同的分片只存一份，manifest 记录每个分片的内容哈希")
+	rootCmd.Flags().BoolVar(&paranoid, "paranoid", false, "切分后再独立读一遍源文件比对 MD5，检测切分期间文件被修改")
+	rootCmd.Flags().StringVar(&resumeManifest, "resume-manifest", "", "checkpoint 丢失时，用（部分写出的）manifest 中已有 root 的分片作为续传依据，分片哈希不一致的会重新上传")
+	rootCmd.Flags().IntVar(&splitRetries, "split-retries", 0, "切分遇到磁盘 I/O 错误时换新的临时目录重新切分的次数（源文件不存在等错误不重试）")
+	rootCmd.Flags().BoolVar(&resumeUpload, "resume", false, "从 <file>.0gresume 继续上次中断的上传，跳过已确认存储的分片")
+	rootCmd.Flags().BoolVar(&concurrencyAuto, "concurrency-auto", false, "从 1 开始按实测吞吐自动增加并发，吞吐不再提升或出错时回退（忽略 --concurrency）")
 	rootCmd.MarkFlagRequired("key")
-	rootCmd.MarkFlagRequired("file")
 
-	if err := rootCmd.Execute(); err != nil {
-		logrus.Fatal(err)
-	}
+	rootCmd.AddCommand(newDownloadCmd())
+	rootCmd.AddCommand(newRootsCmd())
+	rootCmd.AddCommand(newSplitCmd())
+	rootCmd.AddCommand(newAssembleCmd())
+	rootCmd.AddCommand(newVerifyChainCmd())
+	rootCmd.AddCommand(newVersionCmd())
+	rootCmd.AddCommand(newUploadPartsCmd())
+	rootCmd.AddCommand(newGCCmd())
+	rootCmd.AddCommand(newAuditLocalCmd())
+	rootCmd.AddCommand(newVerifyCmd())
+	rootCmd.AddCommand(newBundleCmd())
+	rootCmd.AddCommand(newReconstructCmd())
+	rootCmd.AddCommand(newRecoverManifestCmd())
+	rootCmd.AddCommand(newListAvailableCmd())
+
+	return rootCmd
 }
 
 func run() error {
-	// 1. 计算原始文件 MD5（后面用来校验）
-	originMD5, err := fileMD5(filePath)
+	defer slowReport.print()
+	defer printRetrySummary()
+
+	if (filePath == "") == (dirPath == "") {
+		return configError(fmt.Errorf("--file 和 --dir 必须且只能指定一个"))
+	}
+	if streamTar && dirPath == "" {
+		return configError(fmt.Errorf("--stream 只能配合 --dir 使用"))
+	}
+
+	// manifest / 恢复文件的默认路径都以输入为准；--dir 时对应 <dir>.tar
+	outBase := filePath
+	if dirPath != "" {
+		outBase = filepath.Clean(dirPath) + ".tar"
+	}
+	if manifestPath == "" {
+		manifestPath = outBase + ".manifest.json"
+	}
+	if manifestPath == stdoutManifest && appendTo != "" {
+		return configError(fmt.Errorf("--manifest - 不能和 --append-to-manifest 同时使用"))
+	}
+	manifestTarget := manifestPath
+	if appendTo != "" {
+		manifestTarget = appendTo
+	} else if manifestPath == stdoutManifest {
+		manifestTarget = ""
+	}
+	unlock, err := lockRunTargets(manifestTarget, outBase+".0gresume")
 	if err != nil {
 		return err
 	}
-	fmt.Printf("原始文件 MD5: %s\n", originMD5)
+	defer unlock()
+
+	// 追加到多文件 manifest 时先检查重名，免得上传完才发现
+	if appendTo != "" {
+		set, err := loadManifestSet(appendTo)
+		if err != nil {
+			return configError(fmt.Errorf("读取 manifest 失败: %w", err))
+		}
+		if set.Find(namespace, filepath.Base(outBase)) != nil {
+			return configError(fmt.Errorf("%s 中 namespace %q 下已存在同名文件: %s", appendTo, namespace, filepath.Base(outBase)))
+		}
+	}
+
+	if _, err := newFragmentHasher(fragmentHashAlgo); err != nil {
+		return configError(err)
+	}
+	order, err := resolveOrder()
+	if err != nil {
+		return configError(err)
+	}
+	if noVerify && paranoid {
+		return configError(fmt.Errorf("--no-verify 和 --paranoid 不能同时使用"))
+	}
+	if padLast && erasureSpec != "" {
+		return configError(fmt.Errorf("--pad-last 不能和 --erasure 同时使用（纠删码分片本身就是等长的）"))
+	}
+	if cdcMode && (offsetsPath != "" || erasureSpec != "" || padLast) {
+		return configError(fmt.Errorf("--cdc 不能和 --offsets / --erasure / --pad-last 同时使用"))
+	}
+	if offsetsPath != "" && (erasureSpec != "" || padLast) {
+		return configError(fmt.Errorf("--offsets 不能和 --erasure / --pad-last 同时使用"))
+	}
+	defaultCompressOff(erasureSpec != "" || padLast || contentAddressed || streamTar || pipelineUpload)
+	if err := validateCompress(compressCodec); err != nil {
+		return configError(err)
+	}
+	if sinceManifest != "" && (dirPath != "" || erasureSpec != "" || offsetsPath != "" || cdcMode || padLast || resumeManifest != "") {
+		return configError(fmt.Errorf("--since-manifest 不能和 --dir / --erasure / --offsets / --cdc / --pad-last / --resume-manifest 同时使用"))
+	}
+	if retrySubSize != "" {
+		if subFragmentSize, err = parseByteSize(retrySubSize); err != nil || subFragmentSize <= 0 {
+			return configError(fmt.Errorf("--fragment-retry-different-size 无效: %q", retrySubSize))
+		}
+		if streamTar {
+			return configError(fmt.Errorf("--fragment-retry-different-size 不能和 --stream 同时使用"))
+		}
+	}
+	if compressCodec != "none" && (erasureSpec != "" || padLast || contentAddressed || streamTar) {
+		return configError(fmt.Errorf("--compress 不能和 --erasure / --pad-last / --content-addressed / --stream 同时使用"))
+	}
+	if sha256SumsPath != "" && dirPath != "" {
+		return configError(fmt.Errorf("--sha256sums 不能和 --dir 同时使用（打包的 tar 只是临时文件）"))
+	}
+	if err := validateReportFormat(reportFormat); err != nil {
+		return configError(err)
+	}
+	if skipZeroFragments && (erasureSpec != "" || pipelineUpload || streamTar) {
+		return configError(fmt.Errorf("--skip-zero-fragments 不能和 --erasure / --pipeline / --stream 同时使用"))
+	}
+	if pipelineUpload && (erasureSpec != "" || offsetsPath != "" || cdcMode || sinceManifest != "" || contentAddressed || sidecarHashes || dryRun || compressCodec != "none" || resumeUpload || resumeManifest != "" || streamTar) {
+		return configError(fmt.Errorf("--pipeline 只支持固定大小切分，不能和 --erasure / --offsets / --cdc / --since-manifest / --content-addressed / --sidecar-hashes / --dry-run / --compress / --resume / --resume-manifest / --stream 同时使用"))
+	}
+
+	// --dir：先打成 tar，后面的流程把 tar 当普通文件处理
+	if dirPath != "" {
+		if info, err := os.Stat(dirPath); err != nil || !info.IsDir() {
+			return configError(fmt.Errorf("--dir 不是目录: %s", dirPath))
+		}
+		if streamTar {
+			return runStreamDir(outBase)
+		}
+		tarDir, err := makeTempDir("0g-tar-*")
+		if err != nil {
+			return uploadError(err)
+		}
+		defer os.RemoveAll(tarDir)
+
+		filePath = filepath.Join(tarDir, filepath.Base(outBase))
+		if err := tarDirectory(dirPath, filePath); err != nil {
+			return uploadError(fmt.Errorf("打包目录失败: %w", err))
+		}
+		fmt.Printf("目录已打包: %s\n", dirPath)
+	}
+
+	// 1. 检查源文件
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return configError(err)
+	}
+	if info.Size() == 0 {
+		// 空文件切不出分片，manifest 里没有分片，恢复时直接得到空文件；
+		// 纠删码对 0 字节没有定义（reedsolomon 会返回 ErrShortData），按普通切分处理
+		if erasureSpec != "" {
+			logrus.Warnf("源文件为空，忽略 --erasure")
+			erasureSpec = ""
+		}
+		fmt.Println("源文件为空，不需要上传任何分片")
+	}
+	var bounds []int64
+	if offsetsPath != "" {
+		if bounds, err = loadOffsets(offsetsPath, info.Size()); err != nil {
+			return configError(err)
+		}
+		if len(bounds)-1 > maxFragments {
+			return configError(fmt.Errorf("--offsets 给出 %d 个分片，超过 --max-fragments %d", len(bounds)-1, maxFragments))
+		}
+		fragmentSize = largestFragment(bounds)
+	} else if cdcMode {
+		if fragmentSize <= 0 {
+			return configError(fmt.Errorf("--fragment-size 必须大于 0: %d", fragmentSize))
+		}
+		if bounds, err = cdcBounds(filePath, fragmentSize); err != nil {
+			return uploadError(err)
+		}
+		if len(bounds)-1 > maxFragments {
+			return configError(fmt.Errorf("--cdc 切出 %d 个分片，超过 --max-fragments %d，请调大 --fragment-size", len(bounds)-1, maxFragments))
+		}
+		fragmentSize = largestFragment(bounds)
+	}
+	var since *Manifest
+	if sinceManifest != "" {
+		if since, err = loadSinceManifest(filepath.Base(outBase), info.Size()); err != nil {
+			return configError(err)
+		}
+		if since.FileSize == info.Size() {
+			fmt.Printf("文件与 %s 相比没有新增数据，无需上传\n", sinceManifest)
+			return nil
+		}
+		if fragmentSize <= 0 {
+			return configError(fmt.Errorf("--fragment-size 必须大于 0: %d", fragmentSize))
+		}
+		bounds = tailBounds(since.FileSize, info.Size(), fragmentSize)
+		if len(bounds)-1 > maxFragments {
+			return configError(fmt.Errorf("新增部分切出 %d 个分片，超过 --max-fragments %d", len(bounds)-1, maxFragments))
+		}
+		fmt.Printf("增量上传: %s 已覆盖前 %d bytes，新增 %d bytes\n", sinceManifest, since.FileSize, info.Size()-since.FileSize)
+	}
 
 	// 2. 创建临时目录存放分片
-	tmpDir, err := os.MkdirTemp("", "0g-split-*")
+	tmpDir, err := makeTempDir("0g-split-*")
+	if err != nil {
+		return uploadError(err)
+	}
+	defer func() { os.RemoveAll(tmpDir) }() // 结束后自动清理；重试切分时 tmpDir 会换成新目录
+
+	// 3. 切分文件（--erasure 时改为 Reed-Solomon 编码），切分时顺带计算原始文件 MD5，省掉一次完整读取
+	var splitStart time.Time
+	var originHash io.Writer
+	var originSum, originSHA256 func() string
+	var fragmentFiles []Fragment
+	var erasure *ErasureInfo
+	var split *pipelineSplit
+	err = retrySplit(&tmpDir, func() error {
+		cacheKey := filePath
+		if dirPath != "" {
+			cacheKey = "" // 临时 tar 每次路径都不同，不查缓存
+		}
+		originHash, originSum = newOriginHash(cacheKey)
+		originHash, originSHA256 = withOriginSHA256(originHash)
+		if since != nil {
+			// 前缀只读一遍：边校验旧分片哈希边计入整文件 MD5，新增部分接着在切分时计入
+			if err := verifyAppendPrefix(since, filePath, originHash); err != nil {
+				return verifyError(err)
+			}
+		}
+		splitStart = time.Now() // split 阶段只算切分（含读路径上顺带的哈希），不含上面的前缀校验
+		if erasureSpec != "" {
+			k, total, err := parseErasure(erasureSpec)
+			if err != nil {
+				return configError(err)
+			}
+			if fragmentFiles, erasure, err = splitErasure(filePath, tmpDir, k, total, fragmentHashAlgo, originHash); err != nil {
+				return uploadError(err)
+			}
+			fmt.Printf("纠删码编码完成: %d 个数据分片 + %d 个校验分片，每片 %d bytes，任意 %d 片即可恢复\n",
+				k, total-k, erasure.ShardSize, k)
+		} else if bounds != nil {
+			if fragmentFiles, err = splitAtOffsets(filePath, tmpDir, bounds, fragmentHashAlgo, originHash); err != nil {
+				return uploadError(err)
+			}
+			fmt.Printf("按边界切分成 %d 个分片（%s），最大 %d bytes\n", len(fragmentFiles), boundsSource(), fragmentSize)
+		} else {
+			if err := checkFragmentCount(filePath, fragmentSize, maxFragments); err != nil {
+				return configError(err)
+			}
+			if pipelineUpload {
+				// 切分在后台进行，出错时由上传阶段返回，不在这里重试
+				split = startPipelineSplit(filePath, tmpDir, info.Size(), fragmentSize, fragmentHashAlgo, originHash)
+				fragmentFiles = split.plan()
+				fmt.Printf("边切分边上传，共 %d 个分片，每个约 %dMB\n", len(fragmentFiles), fragmentSize/1024/1024)
+			} else if fragmentFiles, err = splitFile(filePath, tmpDir, fragmentSize, fragmentHashAlgo, originHash); err != nil {
+				return uploadError(err)
+			} else {
+				fmt.Printf("成功切分成 %d 个分片，每个约 %dMB\n", len(fragmentFiles), fragmentSize/1024/1024)
+			}
+		}
+		return nil
+	})
+	if split != nil {
+		defer split.stop()
+	}
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(tmpDir) // 结束后自动清理
+	var originMD5 string
+	if split == nil {
+		originMD5 = originSum()
+		metrics.phase("split", splitStart)
+	}
+	if contentAddressed {
+		if err := contentAddress(fragmentFiles, tmpDir); err != nil {
+			return uploadError(err)
+		}
+		fmt.Printf("分片按内容命名，去重后 %d 个本地文件\n", distinctFragmentFiles(fragmentFiles))
+	}
+	if sidecarHashes {
+		if err := writeFragmentSidecars(fragmentFiles, sidecarDir(outBase)); err != nil {
+			return uploadError(err)
+		}
+	}
+	if split == nil {
+		hashStart := time.Now()
+		if err := checkOriginMD5(originMD5); err != nil {
+			return err
+		}
+		if paranoid {
+			metrics.phase("hash", hashStart)
+		}
+	}
+	if skipZeroFragments {
+		n, err := markZeroFragments(fragmentFiles)
+		if err != nil {
+			return uploadError(err)
+		}
+		if n > 0 {
+			fmt.Printf("%d 个全零分片不上传\n", n)
+		}
+	}
+	if dryRun {
+		printDryRun(fragmentFiles)
+		return nil
+	}
+	if compressCodec != "none" {
+		if err := compressFragments(fragmentFiles); err != nil {
+			return uploadError(err)
+		}
+	}
 
-	// 3. 切分文件
-	fragmentFiles, err := splitFile(filePath, tmpDir, FragmentSize)
+	// 4. 上传每个分片，收集 root（--resume 时跳过 checkpoint 中已确认存储的分片）
+	var ckpt *Checkpoint
+	if resumeManifest != "" {
+		ckpt, err = checkpointFromManifest(resumeManifest, filepath.Base(outBase), outBase+".0gresume", filePath)
+	} else {
+		ckpt, err = openCheckpoint(outBase+".0gresume", filePath, resumeUpload)
+	}
 	if err != nil {
+		return uploadError(err)
+	}
+	roots := make([]string, len(fragmentFiles))
+	toUpload := nonZeroFragments(fragmentFiles)
+	pending := order(ckpt.pending(toUpload, roots))
+	progress := newByteProgress(toUpload, pending)
+	if len(pending) < len(toUpload) {
+		fmt.Printf("从 checkpoint 继续，当前进度 %s\n", progress)
+	}
+	if err := preflightUpload(pending); err != nil {
 		return err
 	}
-	fmt.Printf("成功切分成 %d 个分片，每个约 400MB\n", len(fragmentFiles))
 
-	// 4. 上传每个分片，收集 root
-	var roots []string
-	for i, frag := range fragmentFiles {
-		fmt.Printf("\n[%d/%d] 正在上传分片: %s\n", i+1, len(fragmentFiles), filepath.Base(frag))
+	uploadStart := time.Now()
+	durations := make([]time.Duration, len(fragmentFiles)) // 每个 worker 只写自己分片的下标
+	usedConcurrency, err := uploadFragments(pending, roots, func(frag Fragment) (string, error) {
+		if split != nil {
+			var err error
+			if frag, err = split.wait(frag.Index); err != nil {
+				return "", err
+			}
+		}
+		fmt.Printf("\n[%d/%d] 正在上传分片: %s\n", frag.Index+1, len(fragmentFiles), filepath.Base(frag.Path))
 
-		root, err := uploadSingleFragment(frag)
+		start := time.Now()
+		root, err := uploadWithFallback(frag)
 		if err != nil {
-			return fmt.Errorf("上传分片 %d 失败: %w", i+1, err)
+			return "", fmt.Errorf("上传分片 %d 失败: %w", frag.Index+1, err)
+		}
+		durations[frag.Index] = time.Since(start)
+		slowReport.record("上传", frag.Index, root, durations[frag.Index])
+		progress.add(frag.Size)
+		metrics.addBytes("上传", frag.Size)
+		subs := subUploads.get(frag.Index)
+		if root == "" {
+			fmt.Printf("分片 %d 分 %d 段上传成功，总进度 %s\n", frag.Index+1, len(subs), progress)
+		} else {
+			fmt.Printf("分片 %d 上传成功，root = %s，总进度 %s\n", frag.Index+1, root, progress)
+		}
+		if err := ckpt.record(frag, root, subs); err != nil {
+			logrus.Warnf("写 checkpoint 失败: %v", err)
+		}
+		return root, nil
+	})
+	if err != nil {
+		if split == nil && erasure == nil && since == nil {
+			writePartialManifest(filePath, outBase, originMD5, fragmentFiles, roots)
+		}
+		return uploadError(err)
+	}
+	uploadedAt := time.Now()
+	metrics.phaseTook("upload", uploadedAt.Sub(uploadStart))
+	if split != nil {
+		// 整文件 MD5 要等切分读完整个文件才有，必须在写 manifest 之前取出
+		if fragmentFiles, err = split.finish(); err != nil {
+			return uploadError(err)
+		}
+		originMD5 = originSum()
+		metrics.phaseTook("split", split.took) // 切分与上传重叠，按后台切分自己的耗时记
+		hashStart := time.Now()
+		if err := checkOriginMD5(originMD5); err != nil {
+			return err
+		}
+		if paranoid {
+			metrics.phase("hash", hashStart)
 		}
-		roots = append(roots, root)
-		fmt.Printf("分片 %d 上传成功，root = %s\n", i+1, root)
 	}
 
 	fmt.Printf("\n=== 所有分片上传完成 ===\n")
-	for i, r := range roots {
-		fmt.Printf("分片 %02d root: %s\n", i+1, r)
+	summary := newRunSummary(filepath.Base(outBase), fragmentFiles, roots, durations, usedConcurrency, uploadedAt.Sub(uploadStart))
+	if err := summary.render(os.Stdout, reportFormat); err != nil {
+		logrus.Warnf("输出汇总失败: %v", err)
+	}
+
+	// 写 manifest，之后可以用 download 子命令单独恢复（或只取一段）
+	m := buildManifest(filePath, originMD5, fragmentFiles, roots)
+	if noVerify {
+		m.HashAlgo = ""
+	}
+	if erasure != nil {
+		// 分片大小之和包含校验分片和补零，原始大小以源文件为准
+		m.FileSize = info.Size()
+		m.FragmentSize = erasure.ShardSize
+		m.Erasure = erasure
+	}
+	if dirPath != "" {
+		m.Archive = "tar"
+	}
+	m.Offsets = bounds
+	if cdcMode {
+		m.Chunking = "cdc-gear"
+	}
+	if since != nil {
+		appendToSince(m, since, info.Size())
+	}
+	if err := writeManifest(m); err != nil {
+		return uploadError(fmt.Errorf("写 manifest 失败: %w", err))
 	}
+	if indexCSVPath != "" {
+		if err := writeIndexCSV(indexCSVPath, m); err != nil {
+			return uploadError(fmt.Errorf("写 %s 失败: %w", indexCSVPath, err))
+		}
+		fmt.Printf("分片索引 CSV 已写入: %s\n", indexCSVPath)
+	}
+	ckpt.remove()
+	if waitAvailable > 0 {
+		waitStart := time.Now()
+		if err := waitForAvailability(m); err != nil {
+			return uploadError(err)
+		}
+		metrics.phase("availability", waitStart)
+	}
+
+	// 5. 下载 + 合并，6. 校验 MD5
+	mergedFile := outBase + ".restored"
+	restoreStart := time.Now()
+	err = downloadError(restoreFile(m, mergedFile))
+	metrics.phase("restore", restoreStart)
+	if err == nil {
+		verifyStart := time.Now()
+		err = verifyRestored(mergedFile, m)
+		metrics.phase("verify", verifyStart)
+	}
+	if err == nil && sha256SumsPath != "" {
+		err = writeSHA256Sums(sha256SumsPath, filePath, originSHA256(), mergedFile)
+	}
+	return runRestoreHooks(mergedFile, err)
+}
 
-	// 5. 下载 + 合并
-	mergedFile := filePath + ".restored"
-	if err := downloadAndMerge(roots, mergedFile); err != nil {
+func writeManifest(m *Manifest) error {
+	setFileRoot(m)
+	if err := maybeSignManifest(m); err != nil {
 		return err
 	}
+	if appendTo != "" {
+		set, err := loadManifestSet(appendTo)
+		if err != nil {
+			return err
+		}
+		if err := set.Append(m); err != nil {
+			return err
+		}
+		if err := saveManifestSet(appendTo, set); err != nil {
+			return err
+		}
+		fmt.Printf("已追加到多文件 manifest: %s（共 %d 个文件）\n", appendTo, len(set.Files))
+		if publishManifest {
+			return publishManifestOnChain(m)
+		}
+		return nil
+	}
 
-	// 6. 校验 MD5
-	restoredMD5, _ := fileMD5(mergedFile)
-	fmt.Printf("\n恢复文件 MD5: %s\n", restoredMD5)
-	if originMD5 == restoredMD5 {
-		fmt.Println("MD5 校验通过！文件 100% 完整恢复")
+	if manifestPath == stdoutManifest {
+		if err := writeManifestStdout(m); err != nil {
+			return err
+		}
+		fmt.Println("manifest 已写到标准输出")
 	} else {
-		fmt.Println("MD5 校验失败！")
+		if err := saveManifest(manifestPath, m); err != nil {
+			return err
+		}
+		fmt.Printf("manifest 已写入: %s\n", manifestPath)
 	}
+	if publishManifest {
+		return publishManifestOnChain(m)
+	}
+	return nil
+}
 
+// 上传没有全部成功时，把已经拿到 root 的分片写进 <manifest>.partial.json（失败的分片 root 为空），
+// 之后可以用 --resume-manifest 只补传缺的分片。--manifest - 时不往标准输出写，免得下游当成完整的，
+// 改写到 <输入>.partial.json
+func writePartialManifest(src, outBase string, originMD5 string, frags []Fragment, roots []string) {
+	done := 0
+	for _, frag := range frags {
+		if roots[frag.Index] != "" || frag.Zero {
+			done++
+		}
+	}
+	if done == 0 {
+		return
+	}
+	m := buildManifest(src, originMD5, frags, roots)
+	if noVerify {
+		m.HashAlgo = ""
+	}
+	path := strings.TrimSuffix(manifestPath, ".json") + ".partial.json"
+	if manifestPath == stdoutManifest {
+		path = outBase + ".partial.json"
+	}
+	if err := saveManifest(path, m); err != nil {
+		logrus.Warnf("写部分 manifest 失败: %v", err)
+		return
+	}
+	fmt.Printf("已完成 %d/%d 个分片，部分 manifest 已写入: %s（可用 --resume-manifest 续传）\n", done, len(frags), path)
+}
+
+func verifyMD5(path string, originMD5 string) error {
+	if originMD5 == "" {
+		fmt.Println("\nmanifest 没有整文件哈希（上传时用了 --no-verify），跳过 MD5 校验")
+		return nil
+	}
+	restoredMD5, err := fileMD5(runCtx, path)
+	if err != nil {
+		return verifyError(err)
+	}
+	fmt.Printf("\n恢复文件 MD5: %s\n", restoredMD5)
+	if originMD5 != restoredMD5 {
+		fmt.Println("MD5 校验失败！")
+		return verifyErrorf("MD5 不一致: 原始 %s，恢复 %s", originMD5, restoredMD5)
+	}
+	fmt.Println("MD5 校验通过！文件 100% 完整恢复")
 	return nil
 }
 
 // ==================== 工具函数 ====================
 
 // 把大文件切成固定大小的分片（最后一个可能小一点）
-func splitFile(src string, dstDir string, chunkSize int64) ([]string, error) {
-	f, err := os.Open(src)
+// 读到的原始数据同时写入 origin，用来在切分过程中计算整文件哈希
+func splitFile(src string, dstDir string, chunkSize int64, hashAlgo string, origin io.Writer) ([]Fragment, error) {
+	var files []Fragment
+	err := splitFileEach(runCtx, src, dstDir, chunkSize, hashAlgo, origin, func(frag Fragment) error {
+		files = append(files, frag)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return orderFragments(files)
+}
+
+// 逐个写出分片，每写完一个（文件已关闭、哈希已算好）就交给 emit
+func splitFileEach(ctx context.Context, src string, dstDir string, chunkSize int64, hashAlgo string, origin io.Writer, emit func(Fragment) error) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
 	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	total := int((info.Size() + chunkSize - 1) / chunkSize)
 
-	info, _ := f.Stat()
-	var files []string
+	var offset int64
 
-	buf := make([]byte, chunkSize)
+	// 按 --io-buffer 流式复制，内存占用和分片大小无关
+	buf := make([]byte, ioBufferSize)
 	for i := 0; ; i++ {
-		n, err := f.Read(buf)
-		if n == 0 {
-			if err != nil && err.Error() != "EOF" {
-				return nil, err
-			}
-			break
+		h, err := newFragmentHasher(hashAlgo)
+		if err != nil {
+			return err
 		}
-
-		fragPath := filepath.Join(dstDir, fmt.Sprintf("fragment_%03d.dat", i))
+		fragPath := filepath.Join(dstDir, fragmentFileName(i, total))
 		out, err := os.Create(fragPath)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if _, err := out.Write(buf[:n]); err != nil {
+		w := io.MultiWriter(out, h)
+		n, err := io.CopyBuffer(w, io.TeeReader(io.LimitReader(ctxReader{ctx, f}, chunkSize), origin), buf)
+		if err == nil && n == 0 {
 			out.Close()
-			return nil, err
+			os.Remove(fragPath)
+			break
+		}
+
+		// 不足一个分片时按需补零，存储上的分片都是等长的
+		var padding int64
+		if err == nil && padLast && n < chunkSize {
+			padding = chunkSize - n
+			_, err = io.CopyBuffer(w, io.LimitReader(zeroReader{}, padding), buf)
+		}
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
 		}
-		out.Close()
-		files = append(files, fragPath)
 
-		if err != nil && err.Error() == "EOF" {
+		if err := emit(Fragment{Index: i, Path: fragPath, Offset: offset, Size: n, Hash: hex.EncodeToString(h.Sum(nil)), Padding: padding}); err != nil {
+			return err
+		}
+		offset += n
+		if n < chunkSize {
 			break
 		}
 	}
-	return files, nil
+	return nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// 把 path 的前 n 字节按 --io-buffer 复制到 dst；文件长度必须正好是 want（含补零）
+func copyFragmentData(dst io.Writer, path string, n int64, want int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != want {
+		return fmt.Errorf("分片大小不符: 期望 %d bytes，实际 %d bytes", want, info.Size())
+	}
+	_, err = io.CopyBuffer(dst, io.LimitReader(f, n), make([]byte, min(ioBufferSize, max(n, 1))))
+	return err
+}
+
+// 切分前检查分片数，太多会耗尽内存和链上交易
+func checkFragmentCount(src string, size int64, limit int) error {
+	if size <= 0 {
+		return fmt.Errorf("--fragment-size 必须大于 0: %d", size)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	count := (info.Size() + size - 1) / size
+	if count > int64(limit) {
+		suggest := (info.Size() + int64(limit) - 1) / int64(limit)
+		return fmt.Errorf("文件 %d bytes 按 --fragment-size %d 会切成 %d 个分片，超过 --max-fragments %d；请把 --fragment-size 调到至少 %d",
+			info.Size(), size, count, limit, suggest)
+	}
+	return nil
+}
+
+// 本地分片文件名，设置了 --namespace 时加前缀，避免不同团队的分片在同一目录下冲突。
+// 序号至少 3 位，按分片总数 total 补足位数，保证文件名按字典序排列就是分片顺序
+func fragmentFileName(i int, total int) string {
+	width := max(len(strconv.Itoa(total-1)), 3)
+	name := fmt.Sprintf("fragment_%0*d.dat", width, i)
+	if namespace != "" {
+		name = namespace + "_" + name
+	}
+	return name
+}
+
+// namespace 会出现在文件名里，只允许字母、数字、- 和 _
+func validateNamespace(ns string) error {
+	for _, r := range ns {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("--namespace 只能包含字母、数字、- 和 _: %q", ns)
+		}
+	}
+	return nil
+}
+
+// 按 Index 排序并检查 Index 恰好是 0..n-1。
+// 下游（root 收集、manifest、合并）都按 Index 定位，不依赖分片的产生顺序。
+func orderFragments(frags []Fragment) ([]Fragment, error) {
+	sort.Slice(frags, func(i, j int) bool { return frags[i].Index < frags[j].Index })
+	for i, f := range frags {
+		if f.Index != i {
+			return nil, fmt.Errorf("分片序号不连续: 位置 %d 上是分片 %d", i, f.Index)
+		}
+	}
+	return frags, nil
+}
+
+// --fragment-upload-parallelism：SDK 上传单个文件时可以用多个 goroutine 并发提交 segment（--routines），
+// 大分片在带宽充足时更快。root 只由分片内容的 merkle 树决定，和提交顺序、并发数无关
+var fragmentUploadParallelism int
+
+// 传给 SDK upload 命令的参数（和命令行完全等价）
+func sdkUploadArgs(file string) []string {
+	args := []string{
+		"--url", rpcURL,
+		"--key", privateKey,
+		"--file", file,
+		"--indexer", indexerURL,
+		"--fragment-size", fmt.Sprintf("%d", fragmentSize), // 关键！和本地分片大小一致，SDK 不再二次切分
+		"--expected-replica", "1",
+		"--skip-tx", "false", // 每次都发链上交易，确保 root 被记录
+		"--timeout", sdkTimeout(defaultUploadTimeout, fileSizeOrUnknown(file)),
+	}
+	args = append(args, sdkGasArgs()...)
+	if fragmentUploadParallelism > 1 {
+		args = append(args, "--routines", fmt.Sprintf("%d", fragmentUploadParallelism))
+	}
+	if tag := submissionTag(); tag != "" {
+		// SDK 的 --tags 是随 submission 上链的任意字节（hex），存储端可以据此筛选
+		args = append(args, "--tags", "0x"+hex.EncodeToString([]byte(tag)))
+	}
+	return args
+}
+
+func submissionTag() string {
+	if uploadTagOverride != "" {
+		return uploadTagOverride
+	}
+	return appTag
+}
+
+// 按 "--flag value" 成对输出 SDK 参数，--key 只保留首尾几位
+func formatSDKArgs(args []string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(args); i += 2 {
+		val := args[i+1]
+		if args[i] == "--key" && len(val) > 8 {
+			val = val[:4] + "..." + val[len(val)-4:]
+		} else if args[i] == "--key" {
+			val = "***"
+		}
+		fmt.Fprintf(&b, "  %-20s %s\n", args[i], val)
+	}
+	return b.String()
 }
 
 // 上传单个分片（复用 0g-storage-client 原生的 upload 命令逻辑）
@@ -159,16 +980,9 @@ func uploadSingleFragment(file string) (string, error) {
 		f.Changed = false
 	})
 
-	// 关键参数（和命令行完全等价）
-	args := []string{
-		"--url", rpcURL,
-		"--key", privateKey,
-		"--file", file,
-		"--indexer", indexerURL,
-		"--fragment-size", fmt.Sprintf("%d", FragmentSize), // 关键！强制 400MB 分片
-		"--expected-replica", "1",
-		"--skip-tx", "false", // 每次都发链上交易，确保 root 被记录
-		"--timeout", "30m",
+	args := sdkUploadArgs(file)
+	if dumpOptions {
+		fmt.Printf("SDK upload 参数 (%s):\n%s", filepath.Base(file), formatSDKArgs(args))
 	}
 
 	// 临时捕获日志输出，只取 root
@@ -216,47 +1030,170 @@ func uploadSingleFragment(file string) (string, error) {
 }
 
 // 下载 + 合并
-func downloadAndMerge(roots []string, outputPath string) error {
-	out, err := os.Create(outputPath)
+func downloadAndMerge(m *Manifest, outputPath string) error {
+	return downloadAndMergeFrom(m, outputPath, 0)
+}
+
+// 从第 from 个分片开始下载并追加；from > 0 时保留输出文件中前面已校验过的部分
+func downloadAndMergeFrom(m *Manifest, outputPath string, from int) error {
+	out, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
+	var keep int64
+	if from > 0 {
+		keep = m.Fragments[from-1].Offset + m.Fragments[from-1].Size
+	}
+	if err := out.Truncate(keep); err != nil {
+		return err
+	}
+	if _, err := out.Seek(keep, io.SeekStart); err != nil {
+		return err
+	}
 
-	for i, root := range roots {
-		fmt.Printf("[%d/%d] 正在下载 root: %s\n", i+1, len(roots), root)
-
-		downloadCmd := cmd.GetDownloadCmd() // 同样复用官方 download 命令
+	for i := from; i < len(m.Fragments); i++ {
+		frag := m.Fragments[i]
+		fmt.Printf("[%d/%d] 正在下载 root: %s\n", i+1, len(m.Fragments), frag.Root)
 
-		tmpFile, _ := os.CreateTemp("", "0g-download-*.dat")
-		tmpPath := tmpFile.Name()
-		tmpFile.Close()
+		start := time.Now()
+		tmpPath, err := downloadFragment(m, frag)
+		if err != nil {
+			return err
+		}
 		defer os.Remove(tmpPath)
+		slowReport.record("下载", frag.Index, frag.Root, time.Since(start))
 
-		args := []string{
-			"--url", rpcURL,
-			"--indexer", indexerURL,
-			"--root", root,
-			"--output", tmpPath,
-			"--timeout", "20m",
+		// 追加到最终文件，--pad-last 补的零不写入
+		if err := copyFragmentData(out, tmpPath, frag.Size, frag.storedSize()); err != nil {
+			return fmt.Errorf("分片 %d: %w", frag.Index+1, err)
 		}
+		fmt.Printf("分片 %d 下载完成，%d bytes\n", i+1, frag.Size)
+	}
+	return nil
+}
 
-		downloadCmd.SetArgs(args)
-		if err := downloadCmd.Execute(); err != nil {
-			return fmt.Errorf("下载 root %s 失败: %w", root, err)
+// 下载一个分片并按 manifest 记录的分片哈希校验。刚上传的 root 可能还没同步到当前 indexer，
+// 返回 not found 时再用上传时记录的 indexer 试一次
+func downloadFragment(m *Manifest, frag ManifestFragment) (string, error) {
+	if frag.Zero {
+		return zeroFragmentFile(frag)
+	}
+	tmpPath, err := withFragmentRetry("下载", frag.Index, frag.uploadedSize(), func() (string, error) {
+		return downloadFragmentOnce(m, frag)
+	}, func(tmpPath string) { os.Remove(tmpPath) })
+	if err == nil {
+		metrics.addBytes("下载", frag.uploadedSize())
+	}
+	return tmpPath, err
+}
+
+func downloadFragmentOnce(m *Manifest, frag ManifestFragment) (string, error) {
+	var tmpPath string
+	var err error
+	if len(frag.Subs) > 0 {
+		tmpPath, err = downloadSubFragments(frag)
+	} else {
+		tmpPath, err = downloadStoredRoot(frag, frag.Root)
+	}
+	if err != nil {
+		return "", err
+	}
+	if frag.Codec != "" {
+		if tmpPath, err = decompressFragment(frag, tmpPath); err != nil {
+			return "", err
 		}
+	}
 
-		// 追加到最终文件
-		data, _ := os.ReadFile(tmpPath)
-		if _, err := out.Write(data); err != nil {
-			return err
+	if err := verifyFragmentFile(m, frag, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+// 下载分片（或分片的一段）对应的 root，当前 indexer 找不到时回退到上传时的 indexer
+func downloadStoredRoot(frag ManifestFragment, root string) (string, error) {
+	tmpPath, err := storage.Download(root, indexerURL)
+	if err != nil && isNotFound(err) && dlNode == "" && frag.Indexer != "" && frag.Indexer != indexerURL {
+		logrus.Warnf("分片 %d 在 %s 上未找到，改用上传时的 indexer %s 重试", frag.Index+1, indexerURL, frag.Indexer)
+		tmpPath, err = storage.Download(root, frag.Indexer)
+	}
+	return tmpPath, err
+}
+
+func isNotFound(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "not found")
+}
+
+// 下载单个 root 到临时文件，返回临时文件路径（调用方负责删除）
+func downloadToTemp(root string, indexer string) (string, error) {
+	downloadCmd := cmd.GetDownloadCmd() // 同样复用官方 download 命令
+
+	tmpFile, err := os.CreateTemp("", "0g-download-*.dat")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+
+	source := []string{"--indexer", indexer}
+	if dlNode != "" {
+		source = []string{"--node", dlNode} // SDK 的 --indexer 和 --node 只能二选一
+	}
+	args := append([]string{"--url", rpcURL}, source...)
+	args = append(args,
+		"--root", root,
+		"--output", tmpPath,
+		"--timeout", sdkTimeout(defaultDownloadTimeout, -1),
+	)
+
+	downloadCmd.SetArgs(args)
+	if err := downloadCmd.Execute(); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("下载 root %s 失败: %w", root, err)
+	}
+	return tmpPath, nil
+}
+
+// 打印整文件 MD5；--paranoid 时再独立读一遍源文件，两次不一致说明切分期间文件被改动（例如日志被截断）
+func checkOriginMD5(originMD5 string) error {
+	if originMD5 != "" {
+		fmt.Printf("原始文件 MD5: %s\n", originMD5)
+	}
+	if paranoid {
+		again, err := fileMD5(runCtx, filePath)
+		if err != nil {
+			return uploadError(err)
+		}
+		if again != originMD5 {
+			return verifyErrorf("源文件在切分期间发生变化: 切分时 MD5 %s，重新读取 MD5 %s", originMD5, again)
 		}
-		fmt.Printf("分片 %d 下载完成，%d bytes\n", i+1, len(data))
+		fmt.Println("二次读取 MD5 一致，源文件切分期间未变化")
+	}
+	if dirPath == "" && originMD5 != "" {
+		storeCachedMD5(filePath, originMD5) // 临时 tar 每次路径都不同，不写缓存
 	}
 	return nil
 }
 
-func fileMD5(path string) (string, error) {
+// 切分时顺带计算原始文件 MD5 的 writer；--no-verify 时直接丢弃，sum 返回空串。
+// path 自上次运行后没有变化（哈希缓存按路径+大小+mtime 命中）时直接用缓存的 MD5，切分时不再计算；
+// --paranoid 要和二次读取比较，总是重新计算。path 为空表示不查缓存（例如临时 tar）
+func newOriginHash(path string) (io.Writer, func() string) {
+	if noVerify {
+		return io.Discard, func() string { return "" }
+	}
+	if path != "" && !paranoid {
+		if sum, ok := lookupCachedMD5(path); ok {
+			return io.Discard, func() string { return sum }
+		}
+	}
+	h := md5.New()
+	return h, func() string { return hex.EncodeToString(h.Sum(nil)) }
+}
+
+func fileMD5(ctx context.Context, path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return "", err
@@ -264,8 +1201,8 @@ func fileMD5(path string) (string, error) {
 	defer f.Close()
 
 	h := md5.New()
-	if _, err := io.Copy(h, f); err != nil {
+	if _, err := io.Copy(h, ctxReader{ctx, f}); err != nil {
 		return "", err
 	}
 	return hex.EncodeToString(h.Sum(nil)), nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// 离线（air-gap）传输格式：split --stdout-base64 把分片编码成纯文本，assemble 读回并还原。
+//
+//	0G-SPLIT file=<URL 转义的文件名> size=<bytes> fragments=<n>
+//	0G-FRAGMENT-BEGIN index=<i> offset=<bytes> size=<bytes>
+//	<base64，每行 76 个字符>
+//	0G-FRAGMENT-END index=<i> sha256=<hex>
+const (
+	agFileHeader  = "0G-SPLIT"
+	agFragBegin   = "0G-FRAGMENT-BEGIN"
+	agFragEnd     = "0G-FRAGMENT-END"
+	agLineBytes   = 57 // 57 字节正好编码成 76 个字符，且每行都能单独解码
+	agScanBufSize = 1024 * 1024
+)
+
+var (
+	agFile         string
+	agFragmentSize int64
+	agStdoutBase64 bool
+	agInput        string
+	agOutput       string
+)
+
+func newSplitCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "split",
+		Short: "只切分不上传，--stdout-base64 把分片编码输出到 stdout，用于离线环境拷贝",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			if !agStdoutBase64 {
+				return configError(fmt.Errorf("split 目前只支持 --stdout-base64 输出"))
+			}
+			if agFragmentSize <= 0 {
+				return configError(fmt.Errorf("--fragment-size 必须大于 0: %d", agFragmentSize))
+			}
+			w := bufio.NewWriter(os.Stdout)
+			if err := writeBase64Fragments(agFile, agFragmentSize, w); err != nil {
+				return uploadError(err)
+			}
+			return uploadError(w.Flush())
+		},
+	}
+	c.Flags().StringVar(&agFile, "file", "", "要切分的文件（必填）")
+	c.Flags().Int64Var(&agFragmentSize, "fragment-size", FragmentSize, "每个分片的字节数")
+	c.Flags().BoolVar(&agStdoutBase64, "stdout-base64", false, "把每个分片 base64 编码后输出到 stdout")
+	c.MarkFlagRequired("file")
+	return c
+}
+
+func newAssembleCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "assemble",
+		Short: "读取 split --stdout-base64 的输出并还原文件",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			in := io.Reader(os.Stdin)
+			if agInput != "" && agInput != "-" {
+				f, err := os.Open(agInput)
+				if err != nil {
+					return configError(err)
+				}
+				defer f.Close()
+				in = f
+			}
+			n, err := assembleBase64Fragments(in, agOutput)
+			if err != nil {
+				return verifyError(err)
+			}
+			fmt.Fprintf(os.Stderr, "还原完成: %d 个分片，已写入 %s\n", n, agOutput)
+			return nil
+		},
+	}
+	c.Flags().StringVar(&agInput, "input", "-", "split --stdout-base64 的输出文件，- 表示 stdin")
+	c.Flags().StringVar(&agOutput, "output", "", "还原后的文件路径（必填）")
+	c.MarkFlagRequired("output")
+	return c
+}
+
+func writeBase64Fragments(src string, chunkSize int64, w io.Writer) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	count := (info.Size() + chunkSize - 1) / chunkSize
+	fmt.Fprintf(w, "%s file=%s size=%d fragments=%d\n", agFileHeader, url.PathEscape(filepath.Base(src)), info.Size(), count)
+
+	line := make([]byte, agLineBytes)
+	for i := int64(0); i < count; i++ {
+		offset := i * chunkSize
+		size := min(chunkSize, info.Size()-offset)
+		fmt.Fprintf(w, "%s index=%d offset=%d size=%d\n", agFragBegin, i, offset, size)
+
+		h := sha256.New()
+		r := io.TeeReader(io.LimitReader(f, size), h)
+		for {
+			n, err := io.ReadFull(r, line)
+			if n > 0 {
+				fmt.Fprintln(w, base64.StdEncoding.EncodeToString(line[:n]))
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(w, "%s index=%d sha256=%s\n", agFragEnd, i, hex.EncodeToString(h.Sum(nil)))
+	}
+	return nil
+}
+
+// 返回还原的分片数。每个分片都校验长度和 sha256，分片必须按顺序且不缺失
+func assembleBase64Fragments(in io.Reader, outputPath string) (int, error) {
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, agScanBufSize), agScanBufSize)
+
+	if !sc.Scan() {
+		return 0, fmt.Errorf("输入为空")
+	}
+	hdr, err := parseAirgapLine(sc.Text(), agFileHeader)
+	if err != nil {
+		return 0, err
+	}
+	var total, fragments int64
+	if _, err := fmt.Sscan(hdr["size"], &total); err != nil {
+		return 0, fmt.Errorf("文件头 size 无效: %q", hdr["size"])
+	}
+	if _, err := fmt.Sscan(hdr["fragments"], &fragments); err != nil {
+		return 0, fmt.Errorf("文件头 fragments 无效: %q", hdr["fragments"])
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var index, written int64
+	for sc.Scan() {
+		begin, err := parseAirgapLine(sc.Text(), agFragBegin)
+		if err != nil {
+			return 0, err
+		}
+		if begin["index"] != fmt.Sprint(index) {
+			return 0, fmt.Errorf("分片顺序错误: 期望 %d，实际 %s", index, begin["index"])
+		}
+
+		h := sha256.New()
+		var size int64
+		var end map[string]string
+		for sc.Scan() {
+			text := sc.Text()
+			if strings.HasPrefix(text, agFragEnd) {
+				if end, err = parseAirgapLine(text, agFragEnd); err != nil {
+					return 0, err
+				}
+				break
+			}
+			data, err := base64.StdEncoding.DecodeString(text)
+			if err != nil {
+				return 0, fmt.Errorf("分片 %d base64 解码失败: %w", index, err)
+			}
+			if _, err := out.Write(data); err != nil {
+				return 0, err
+			}
+			h.Write(data)
+			size += int64(len(data))
+		}
+		if end == nil {
+			return 0, fmt.Errorf("分片 %d 不完整: 缺少 %s", index, agFragEnd)
+		}
+		if begin["size"] != fmt.Sprint(size) {
+			return 0, fmt.Errorf("分片 %d 长度不符: 期望 %s，实际 %d", index, begin["size"], size)
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); end["sha256"] != sum {
+			return 0, fmt.Errorf("分片 %d sha256 校验失败: 期望 %s，实际 %s", index, end["sha256"], sum)
+		}
+		written += size
+		index++
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	if index != fragments || written != total {
+		return 0, fmt.Errorf("输入不完整: 期望 %d 个分片 / %d bytes，实际 %d 个 / %d bytes", fragments, total, index, written)
+	}
+	return int(index), nil
+}
+
+// 解析 "<tag> k=v k=v ..." 形式的行
+func parseAirgapLine(line string, tag string) (map[string]string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != tag {
+		return nil, fmt.Errorf("格式错误: 期望 %s 行，实际 %q", tag, line)
+	}
+	kv := map[string]string{}
+	for _, f := range fields[1:] {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("格式错误: %q", line)
+		}
+		kv[k] = v
+	}
+	return kv, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBase64RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "small.bin")
+	data := writeTestFile(t, src, 1000, 7)
+
+	var buf bytes.Buffer
+	if err := writeBase64Fragments(src, 300, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(buf.String(), agFragBegin); got != 4 {
+		t.Fatalf("期望 4 个分片头，实际 %d", got)
+	}
+	out := filepath.Join(dir, "assembled.bin")
+	n, err := assembleBase64Fragments(bytes.NewReader(buf.Bytes()), out)
+	if err != nil || n != 4 {
+		t.Fatalf("还原失败: %d 个分片，%v", n, err)
+	}
+	assertFileContent(t, out, data)
+}
+
+func TestBase64AssembleDetectsDamage(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "small.bin")
+	writeTestFile(t, src, 1000, 7)
+	var buf bytes.Buffer
+	writeBase64Fragments(src, 300, &buf)
+	lines := strings.Split(buf.String(), "\n")
+
+	// 改动一行 base64 的第一个字符
+	damaged := append([]string{}, lines...)
+	first := "A"
+	if damaged[2][0] == 'A' {
+		first = "B"
+	}
+	damaged[2] = first + damaged[2][1:]
+	_, err := assembleBase64Fragments(strings.NewReader(strings.Join(damaged, "\n")), filepath.Join(dir, "x"))
+	if err == nil || !strings.Contains(err.Error(), "sha256 校验失败") {
+		t.Fatalf("期望 sha256 校验失败，实际 %v", err)
+	}
+
+	// 丢掉最后一个分片
+	var cut []string
+	for _, l := range lines {
+		if strings.HasPrefix(l, agFragBegin+" index=3") {
+			break
+		}
+		cut = append(cut, l)
+	}
+	_, err = assembleBase64Fragments(strings.NewReader(strings.Join(cut, "\n")), filepath.Join(dir, "y"))
+	if err == nil || !strings.Contains(err.Error(), "输入不完整") {
+		t.Fatalf("期望输入不完整，实际 %v", err)
+	}
+}
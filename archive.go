@@ -0,0 +1,159 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	dirPath          string // --dir：把整个目录打成 tar 后上传
+	streamTar        bool   // --stream：tar 流直接切分上传，不在磁盘上生成完整 tar
+	extractDir       string // download --extract：恢复后把 tar 解到这个目录
+	preserveMetadata bool   // 解包时恢复文件权限和 mtime
+)
+
+// 把目录打成 tar，记录相对路径、权限和 mtime
+func tarDirectory(src string, dst string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return writeTar(src, out)
+}
+
+func writeTar(src string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// 把 tar 流解到 dst。preserve 为 true 时按 tar 头恢复权限和 mtime，
+// 返回元数据没能恢复的文件列表（内容已经写出，只是权限/时间不对）
+func extractTar(r io.Reader, dst string, preserve bool) ([]string, error) {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return nil, err
+	}
+
+	type pending struct {
+		path  string
+		mode  os.FileMode
+		mtime time.Time
+	}
+	var metas []pending
+	var failed []string
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(hdr.Name))
+		if target != filepath.Clean(dst) && !strings.HasPrefix(target, filepath.Clean(dst)+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("tar 中的路径越界: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return nil, err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return nil, err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return nil, err
+			}
+			continue // 符号链接不恢复元数据
+		default:
+			logrus.Warnf("跳过不支持的 tar 条目类型 %c: %s", hdr.Typeflag, hdr.Name)
+			continue
+		}
+		metas = append(metas, pending{path: target, mode: hdr.FileInfo().Mode().Perm(), mtime: hdr.ModTime})
+	}
+
+	if !preserve {
+		return nil, nil
+	}
+
+	// 倒序处理：先子项后目录，否则往目录里写文件会把目录的 mtime 冲掉
+	for i := len(metas) - 1; i >= 0; i-- {
+		p := metas[i]
+		if err := os.Chmod(p.path, p.mode); err != nil {
+			failed = append(failed, p.path)
+			continue
+		}
+		if err := os.Chtimes(p.path, p.mtime, p.mtime); err != nil {
+			failed = append(failed, p.path)
+		}
+	}
+	return failed, nil
+}
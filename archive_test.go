@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// --preserve-metadata：打包时记录的权限和 mtime 在解包后重新应用（目录的 mtime 也不能被里面的文件冲掉）
+func TestExtractTarPreservesMetadata(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	file := filepath.Join(src, "sub", "report.txt")
+	os.WriteFile(file, []byte("hello"), 0644)
+	fileTime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	dirTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chmod(file, 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(file, fileTime, fileTime)
+	os.Chmod(filepath.Join(src, "sub"), 0750)
+	os.Chtimes(filepath.Join(src, "sub"), dirTime, dirTime)
+
+	tarPath := filepath.Join(dir, "src.tar")
+	if err := tarDirectory(src, tarPath); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "dst")
+	f, err := os.Open(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	failed, err := extractTar(f, dst, true)
+	if err != nil || len(failed) > 0 {
+		t.Fatalf("解包失败: %v，元数据未恢复: %v", err, failed)
+	}
+	assertFileContent(t, filepath.Join(dst, "sub", "report.txt"), []byte("hello"))
+	checkMeta := func(path string, mode os.FileMode, mtime time.Time) {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode || !info.ModTime().Equal(mtime) {
+			t.Fatalf("%s: 权限 %v mtime %v，期望 %v %v", path, info.Mode().Perm(), info.ModTime().UTC(), mode, mtime)
+		}
+	}
+	checkMeta(filepath.Join(dst, "sub", "report.txt"), 0600, fileTime)
+	checkMeta(filepath.Join(dst, "sub"), 0750, dirTime)
+
+	// 不加 --preserve-metadata 时按默认权限创建
+	plain := filepath.Join(dir, "plain")
+	f.Seek(0, io.SeekStart)
+	if _, err := extractTar(f, plain, false); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(filepath.Join(plain, "sub", "report.txt")); info.ModTime().Equal(fileTime) {
+		t.Fatal("没有 --preserve-metadata 时不应恢复 mtime")
+	}
+}
+
+// --dir --stream：上传和恢复都经 io.Pipe，恢复出的目录与源目录一致，磁盘上不留完整的 tar
+func TestStreamDirRoundTrip(t *testing.T) {
+	dir := setupTest(t)
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "a", "b"), 0755)
+	want := map[string][]byte{
+		"top.txt":        []byte("top level"),
+		"a/one.bin":      writeTestFile(t, filepath.Join(src, "a", "one.bin"), 2500, 1),
+		"a/b/two.bin":    writeTestFile(t, filepath.Join(src, "a", "b", "two.bin"), 700, 2),
+		"a/b/empty.file": nil,
+	}
+	os.WriteFile(filepath.Join(src, "top.txt"), want["top.txt"], 0644)
+	os.WriteFile(filepath.Join(src, "a", "b", "empty.file"), nil, 0644)
+
+	dirPath = src
+	streamTar = true
+	fragmentSize = 1000
+	ioBufferSize = 64 // 比分片小：每个分片分多次写盘，不按分片大小分配内存
+	manifestPath = filepath.Join(dir, "src.manifest.json")
+	if err := run(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, frag := range m.Fragments {
+		if i < len(m.Fragments)-1 && frag.Size != fragmentSize {
+			t.Fatalf("分片 %d 大小 %d，期望 %d", i+1, frag.Size, fragmentSize)
+		}
+	}
+
+	restored := filepath.Join(dir, "src.restored")
+	got := map[string][]byte{}
+	err = filepath.WalkDir(restored, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(restored, path)
+		data, err := os.ReadFile(path)
+		got[filepath.ToSlash(rel)] = data
+		return err
+	})
+	if err != nil {
+		t.Fatalf("恢复的目录: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("恢复出 %d 个文件，期望 %d 个: %v", len(got), len(want), got)
+	}
+	for name, data := range want {
+		if string(got[name]) != string(data) {
+			t.Fatalf("%s 内容不一致", name)
+		}
+	}
+	tars, _ := filepath.Glob(filepath.Join(dir, "*.tar*"))
+	for _, p := range tars {
+		if !strings.HasSuffix(p, ".lock") { // checkpoint 的锁文件不算
+			t.Fatalf("不应在磁盘上生成 tar: %v", tars)
+		}
+	}
+}
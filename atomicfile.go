@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 所有 manifest / 缓存文件的写入都经过这里，避免并发 worker 交错写
+var writeMu sync.Mutex
+
+// 先写同目录下的临时文件并 fsync，再 rename 覆盖目标。
+// rename 在同一文件系统内是原子的，崩溃时目标文件要么是旧内容要么是新内容，不会只写一半。
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // rename 成功后这里是空操作
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// 把目录项也刷盘，保证 rename 本身落盘；部分平台不支持对目录 fsync，忽略错误
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
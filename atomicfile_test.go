@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// 多个 worker 交错写同一个文件，同时不停地读：任何时刻读到的都必须是完整的 JSON
+func TestWriteFileAtomicInterleaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.json")
+	var stop atomic.Bool
+	var reads, bad atomic.Int64
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for !stop.Load() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue // 第一次写之前文件还不存在
+			}
+			reads.Add(1)
+			if !json.Valid(data) {
+				bad.Add(1)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				// 每次内容长度都不同，交错写入一定会留下残缺的 JSON
+				data, _ := json.Marshal(map[string]string{"writer": fmt.Sprint(w), "pad": strings.Repeat("x", (w*50+i)*37)})
+				if err := writeFileAtomic(path, data, 0644); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	stop.Store(true)
+	<-readerDone
+
+	if bad.Load() > 0 {
+		t.Fatalf("%d/%d 次读到不完整的 JSON", bad.Load(), reads.Load())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !json.Valid(data) {
+		t.Fatalf("最终文件不是合法 JSON: %v", err)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".m.json.tmp-*"))
+	if len(leftovers) > 0 {
+		t.Fatalf("留下了临时文件: %v", leftovers)
+	}
+}
+
+// 并发记录 checkpoint，最终文件包含全部分片
+func TestCheckpointConcurrentRecord(t *testing.T) {
+	dir := setupTest(t)
+	src := filepath.Join(dir, "src.bin")
+	writeTestFile(t, src, 10, 0)
+	ckpt, err := openCheckpoint(filepath.Join(dir, "src.bin.0gresume"), src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 40
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := ckpt.record(Fragment{Index: i, Hash: fmt.Sprint(i)}, fmt.Sprintf("0x%064x", i), nil); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(ckpt.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Checkpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("checkpoint 不是合法 JSON: %v", err)
+	}
+	if len(saved.Completed) != n {
+		t.Fatalf("checkpoint 里有 %d 个分片，期望 %d", len(saved.Completed), n)
+	}
+}
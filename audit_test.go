@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupTest 把 localRoot 换成和 fakeStorage 相同的内容 sha256（真实环境下两边都是 SDK 的 Merkle root），
+// 所以刚上传得到的 manifest 离线审计应全部一致；改掉一个 root 后应报告该分片不一致
+func TestAuditLocal(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 3500, 2)
+	m := uploadTestFile(t, path)
+
+	out := captureStdout(t, func() {
+		if err := auditManifestLocal(m, path); err != nil {
+			t.Fatalf("未改动的 manifest 审计失败: %v", err)
+		}
+	})
+	if !strings.Contains(out, "全部 4 个分片的 root 与本地文件一致") {
+		t.Fatalf("输出缺少一致的结论:\n%s", out)
+	}
+
+	m.Fragments[2].Root = "0x" + strings.Repeat("ab", 32)
+	var err error
+	out = captureStdout(t, func() { err = auditManifestLocal(m, path) })
+	if err == nil || exitCode(err) != ExitVerify {
+		t.Fatalf("被改动的 manifest 应以校验错误退出，实际 %v", err)
+	}
+	if !strings.Contains(out, "分片 03 root 不一致") || strings.Contains(out, "分片 01 root 不一致") {
+		t.Fatalf("应只报告分片 03 不一致:\n%s", out)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// --wait-available：刚上传的 root 可能要过一段时间才能从 indexer 下载到。
+// 写完 manifest 后轮询每个 root，全部可下载（或超时）后再继续，保证紧接着的下载能成功
+var (
+	waitAvailable    time.Duration // 最长等待时长，0 表示不等待
+	availablePollGap = 10 * time.Second
+)
+
+type pendingPiece struct {
+	name string
+	root string
+	from time.Time // 这个分片上传完成的时间，time-to-availability 从这里算
+}
+
+// 本次运行中每个分片上传完成的时间，Index -> 时间；上传 worker 并发写入
+type uploadTimes struct {
+	mu sync.Mutex
+	at map[int]time.Time
+}
+
+var fragmentUploadedAt = &uploadTimes{at: map[int]time.Time{}}
+
+func (u *uploadTimes) done(index int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.at[index] = time.Now()
+}
+
+// 没有记录（--resume 跳过、本次没有上传）的分片按 fallback 算
+func (u *uploadTimes) get(index int, fallback time.Time) time.Time {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if t, ok := u.at[index]; ok {
+		return t
+	}
+	return fallback
+}
+
+func waitForAvailability(m *Manifest) error {
+	checker, ok := storage.(availabilityChecker)
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	var pending []pendingPiece
+	for _, frag := range m.Fragments {
+		pieces := frag.pieces()
+		from := fragmentUploadedAt.get(frag.Index, start)
+		for i, p := range pieces {
+			name := fmt.Sprintf("分片 %02d", frag.Index+1)
+			if len(pieces) > 1 {
+				name += fmt.Sprintf(" 第 %d 段", i+1)
+			}
+			pending = append(pending, pendingPiece{name, p.Root, from})
+		}
+	}
+
+	fmt.Printf("\n等待 %d 个 root 可下载（最长 %s）\n", len(pending), waitAvailable)
+	deadline := start.Add(waitAvailable)
+	for {
+		var still []pendingPiece
+		for _, p := range pending {
+			ok, err := checker.Available(p.root)
+			if err != nil {
+				logrus.Warnf("查询%s是否可下载失败: %v", p.name, err)
+			}
+			if !ok {
+				still = append(still, p)
+				continue
+			}
+			fmt.Printf("%s 已可下载，上传完成后 %s\n", p.name, time.Since(p.from).Round(time.Millisecond))
+		}
+		pending = still
+		if len(pending) == 0 {
+			fmt.Println("全部 root 已可下载")
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			for _, p := range pending {
+				fmt.Printf("%s 仍不可下载，root = %s\n", p.name, p.root)
+			}
+			return fmt.Errorf("等待 %s 后仍有 %d 个 root 不可下载", waitAvailable, len(pending))
+		}
+
+		select {
+		case <-runCtx.Done():
+			return runCtx.Err()
+		case <-time.After(min(availablePollGap, time.Until(deadline))):
+		}
+	}
+}
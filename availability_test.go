@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 每个 root 前 polls 次查询都报告不可下载，之后才可下载；每次上传耗时 delay
+type flipAvailableStorage struct {
+	*countingStorage
+	polls int
+	delay time.Duration
+
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func (s *flipAvailableStorage) Upload(path string) (string, error) {
+	time.Sleep(s.delay)
+	return s.countingStorage.Upload(path)
+}
+
+func (s *flipAvailableStorage) Available(root string) (bool, error) {
+	s.mu.Lock()
+	s.seen[root]++
+	n := s.seen[root]
+	s.mu.Unlock()
+	if n <= s.polls {
+		return false, nil
+	}
+	return s.fakeStorage.Available(root)
+}
+
+// 轮询几次后 root 才可下载，等待正常结束；time-to-availability 从各分片自己上传完成的时刻算起，
+// 先上传完的分片等得更久
+func TestWaitAvailableUntilFlip(t *testing.T) {
+	dir := setupTest(t)
+	s := &flipAvailableStorage{countingStorage: useCountingStorage(), polls: 2, delay: 100 * time.Millisecond, seen: map[string]int{}}
+	storage = s
+	fragmentSize, concurrency = 1000, 1
+	waitAvailable = 5 * time.Second
+	prevGap := availablePollGap
+	availablePollGap = 20 * time.Millisecond
+	t.Cleanup(func() { availablePollGap = prevGap })
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 3000, 1)
+
+	out := captureStdout(t, func() { uploadTestFile(t, path) })
+	took := map[int]time.Duration{}
+	for _, match := range regexp.MustCompile(`分片 (\d+) 已可下载，上传完成后 (\S+)\n`).FindAllStringSubmatch(out, -1) {
+		idx, _ := strconv.Atoi(match[1])
+		took[idx], _ = time.ParseDuration(match[2])
+	}
+	if len(took) != 3 {
+		t.Fatalf("应报告 3 个分片的可下载时间:\n%s", out)
+	}
+	if took[1]-took[3] < 150*time.Millisecond {
+		t.Fatalf("分片 1 比分片 3 早上传完约 200ms，等待时间应相应更长: %v", took)
+	}
+	for root, n := range s.seen {
+		if n != s.polls+1 {
+			t.Fatalf("root %s 查询了 %d 次，期望在第 %d 次可下载后停止", root, n, s.polls+1)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/sirupsen/logrus"
+)
+
+// 上传前检查签名账户余额够不够付全部分片的存储费和 gas，免得上传到一半没钱，前面的分片白传；
+// 之后再让用户确认这笔花费，--yes 跳过确认
+var (
+	skipBalanceCheck bool
+	assumeYes        bool
+
+	confirmInput       io.Reader = os.Stdin
+	confirmInteractive           = stdinIsTerminal
+)
+
+const (
+	sectorSize     = 256     // 存储费按 256 字节的扇区计价
+	submissionGas  = 500_000 // 每个分片一笔 submit 交易的 gas 估计值（偏保守）
+	costMarginPerc = 10      // 扇区对齐和 gas 价格波动的余量
+)
+
+// 上传前的余额检查和花费确认。费用/余额查询失败只告警，不阻止上传（RPC 不稳定时不应该比原来更容易失败）
+func preflightUpload(frags []Fragment) error {
+	if len(frags) == 0 {
+		return nil
+	}
+	if _, ok := storage.(sdkStorage); !ok {
+		return nil // RunDeterministic 等不花钱的后端
+	}
+	if err := resolveGasPrice(); err != nil {
+		return uploadError(err)
+	}
+	cost, err := estimateUploadCost(frags)
+	if err != nil {
+		logrus.Warnf("估算上传费用失败，跳过余额检查: %v", err)
+	}
+	if cost != nil && !skipBalanceCheck {
+		addr, balance, err := signerBalance()
+		if err != nil {
+			logrus.Warnf("查询账户余额失败，跳过余额检查: %v", err)
+		} else if err := checkBalance(balance, cost); err != nil {
+			return uploadError(fmt.Errorf("账户 %s %w（可用 --skip-balance-check 跳过）", addr, err))
+		} else {
+			fmt.Printf("账户 %s 余额 %s，预计费用 %s\n", addr, formatWei(balance), formatWei(cost))
+		}
+	}
+	return confirmSpend(frags, cost)
+}
+
+// 发交易前让用户确认分片数、总字节数和预计费用；非交互环境必须显式给 --yes
+func confirmSpend(frags []Fragment, cost *big.Int) error {
+	if assumeYes {
+		return nil
+	}
+	if !confirmInteractive() {
+		return configError(fmt.Errorf("标准输入不是终端，无法确认上传花费，请加 --yes"))
+	}
+	var total int64
+	for _, frag := range frags {
+		total += frag.Size
+	}
+	estimate := "未知"
+	if cost != nil {
+		estimate = formatWei(cost)
+	}
+	fmt.Printf("即将上传 %d 个分片，共 %d bytes，预计费用 %s。继续？[y/N] ", len(frags), total, estimate)
+	answer, _ := bufio.NewReader(confirmInput).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return configError(fmt.Errorf("已取消上传"))
+}
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// 余额不足时返回包含缺口的错误
+func checkBalance(balance, cost *big.Int) error {
+	if balance.Cmp(cost) >= 0 {
+		return nil
+	}
+	short := new(big.Int).Sub(cost, balance)
+	return fmt.Errorf("余额不足: 余额 %s，预计需要 %s，还差 %s", formatWei(balance), formatWei(cost), formatWei(short))
+}
+
+// 存储费（扇区数 × Market 合约的 pricePerSector）+ 每个分片一笔交易的 gas，再加余量
+func estimateUploadCost(frags []Fragment) (*big.Int, error) {
+	price, err := pricePerSector()
+	if err != nil {
+		return nil, err
+	}
+	gp := effectiveGasPrice
+	if gp == nil {
+		var raw string
+		if err := rpcCall(rpcURL, "eth_gasPrice", []interface{}{}, &raw); err != nil {
+			return nil, err
+		}
+		if gp, err = parseQuantity(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	var sectors int64
+	for _, frag := range frags {
+		size := frag.Size + frag.Padding
+		if frag.Codec != "" {
+			size = frag.CompressedSize
+		}
+		sectors += (size + sectorSize - 1) / sectorSize
+	}
+	cost := new(big.Int).Mul(price, big.NewInt(sectors))
+	cost.Add(cost, new(big.Int).Mul(gp, big.NewInt(submissionGas*int64(len(frags)))))
+	cost.Mul(cost, big.NewInt(100+costMarginPerc))
+	return cost.Div(cost, big.NewInt(100)), nil
+}
+
+// 从 Flow.market() 找到 Market 合约读单价
+func pricePerSector() (*big.Int, error) {
+	flow, err := flowAddress()
+	if err != nil {
+		return nil, err
+	}
+	return marketPrice(flow)
+}
+
+func signerBalance() (string, *big.Int, error) {
+	prv, err := crypto.HexToECDSA(strings.TrimPrefix(privateKey, "0x"))
+	if err != nil {
+		return "", nil, fmt.Errorf("私钥无效: %w", err)
+	}
+	addr := crypto.PubkeyToAddress(prv.PublicKey).Hex()
+	var raw string
+	if err := rpcCall(rpcURL, "eth_getBalance", []interface{}{addr, "latest"}, &raw); err != nil {
+		return "", nil, err
+	}
+	balance, err := parseQuantity(raw)
+	return addr, balance, err
+}
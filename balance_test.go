@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 预检走真实的 sdkStorage 分支（不会真的上传），链上查询由 fakeChain 应答
+func setupPreflight(t *testing.T) *fakeChain {
+	t.Helper()
+	setupTest(t)
+	chain := newFakeChain(t)
+	storage = sdkStorage{}
+	rpcURL, indexerURL, privateKey = chain.URL, chain.URL, testKey
+	assumeYes = true
+	return chain
+}
+
+// 2 个 1000 bytes 的分片：(8 扇区 × 1000 + 2 笔 × 500000 gas × 7) × 110% = 7708800 wei
+func TestPreflightBalanceBelowEstimate(t *testing.T) {
+	chain := setupPreflight(t)
+	frags := []Fragment{{Index: 0, Size: 1000}, {Index: 1, Size: 1000}}
+	cost, err := estimateUploadCost(frags)
+	if err != nil || cost.Int64() != 7708800 {
+		t.Fatalf("预计费用 %v（%v），期望 7708800 wei", cost, err)
+	}
+
+	chain.setBalance(7000000)
+	err = preflightUpload(frags)
+	if err == nil || exitCode(err) != ExitUpload || !strings.Contains(err.Error(), "余额不足") || !strings.Contains(err.Error(), "还差") {
+		t.Fatalf("余额低于预计费用时应中止并说明缺口，实际 %v", err)
+	}
+
+	chain.setBalance(7708800)
+	if err := preflightUpload(frags); err != nil {
+		t.Fatalf("余额足够时不应中止: %v", err)
+	}
+
+	chain.setBalance(0)
+	skipBalanceCheck = true
+	if err := preflightUpload(frags); err != nil {
+		t.Fatalf("--skip-balance-check 时不应检查余额: %v", err)
+	}
+}
+
+// 花费确认：回答 n 时中止、y 时继续；非交互环境必须加 --yes
+func TestConfirmSpendPrompt(t *testing.T) {
+	setupPreflight(t)
+	assumeYes = false
+	input, interactive := confirmInput, confirmInteractive
+	t.Cleanup(func() { confirmInput, confirmInteractive = input, interactive })
+	frags := []Fragment{{Index: 0, Size: 1000}, {Index: 1, Size: 1000}}
+
+	confirmInteractive = func() bool { return true }
+	for answer, ok := range map[string]bool{"n\n": false, "\n": false, "y\n": true, "YES\n": true} {
+		confirmInput = strings.NewReader(answer)
+		var err error
+		out := captureStdout(t, func() { err = confirmSpend(frags, nil) })
+		if (err == nil) != ok {
+			t.Fatalf("回答 %q: err = %v", answer, err)
+		}
+		if !strings.Contains(out, "即将上传 2 个分片，共 2000 bytes") {
+			t.Fatalf("提示中缺少分片数和字节数:\n%s", out)
+		}
+	}
+
+	// 整个预检走到确认这一步：拒绝时不会开始上传
+	confirmInput = strings.NewReader("n\n")
+	skipBalanceCheck = true
+	if err := preflightUpload(frags); err == nil || !strings.Contains(err.Error(), "已取消") {
+		t.Fatalf("拒绝确认时应中止，实际 %v", err)
+	}
+
+	confirmInteractive = func() bool { return false }
+	if err := confirmSpend(frags, nil); err == nil || exitCode(err) != ExitConfig {
+		t.Fatalf("非交互环境没有 --yes 时应报参数错误，实际 %v", err)
+	}
+	assumeYes = true
+	if err := confirmSpend(frags, nil); err != nil {
+		t.Fatalf("--yes 时不应提示: %v", err)
+	}
+}
+
+// --dir --stream 边打包边上传，同样要先按估算的 tar 大小确认花费；拒绝时不会开始上传
+func TestStreamDirPreflight(t *testing.T) {
+	setupPreflight(t)
+	assumeYes, skipBalanceCheck = false, true
+	input, interactive := confirmInput, confirmInteractive
+	t.Cleanup(func() { confirmInput, confirmInteractive = input, interactive })
+
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(src, "a.bin"), 2500, 1)
+	filePath, dirPath, streamTar, fragmentSize = "", src, true, 1000
+
+	// 1 个文件：512 头 + 2560 数据（按块对齐）+ 1024 结束标记，和实际打出的 tar 一样大
+	var tarball bytes.Buffer
+	if err := writeTar(src, &tarball); err != nil {
+		t.Fatal(err)
+	}
+	if tarball.Len() != 4096 {
+		t.Fatalf("tar 大小 %d，期望 4096", tarball.Len())
+	}
+
+	confirmInteractive = func() bool { return true }
+	confirmInput = strings.NewReader("n\n")
+	var err error
+	out := captureStdout(t, func() { err = run() })
+	if err == nil || !strings.Contains(err.Error(), "已取消") {
+		t.Fatalf("拒绝确认时应中止，实际 %v", err)
+	}
+	if !strings.Contains(out, "即将上传 5 个分片，共 4096 bytes") {
+		t.Fatalf("确认提示应按估算的 tar 大小列出分片:\n%s", out)
+	}
+
+	confirmInteractive = func() bool { return false }
+	if err := run(); err == nil || exitCode(err) != ExitConfig {
+		t.Fatalf("非交互环境没有 --yes 时应报参数错误，实际 %v", err)
+	}
+}
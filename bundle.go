@@ -0,0 +1,173 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// .0gbundle 是可以直接发给别人的恢复包：一个 tar，里面只有 bundle.json（版本和校验和）和 manifest.json，
+// 不含分片数据。对方用 download --bundle 从网络恢复
+const (
+	BundleVersion    = 1
+	bundleInfoName   = "bundle.json"
+	bundleManifestFn = "manifest.json"
+	maxBundleEntry   = 64 << 20 // manifest 再大也不会超过这个，防止恶意包撑爆内存
+)
+
+type bundleInfo struct {
+	Version        int    `json:"version"`
+	FileName       string `json:"file_name"`
+	FileSize       int64  `json:"file_size"`
+	Created        string `json:"created"`
+	ManifestSHA256 string `json:"manifest_sha256"`
+}
+
+var (
+	bundleManifest string
+	bundleName     string
+	bundleOutput   string
+	dlBundle       string // download --bundle
+)
+
+func newBundleCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "bundle",
+		Short: "把 manifest 打包成可分享的 .0gbundle，对方用 download --bundle 恢复",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			m, err := loadManifestFor(bundleManifest, namespace, bundleName)
+			if err != nil {
+				return configError(fmt.Errorf("读取 manifest 失败: %w", err))
+			}
+			if bundleOutput == "" {
+				bundleOutput = m.FileName + ".0gbundle"
+			}
+			if err := writeBundle(bundleOutput, m); err != nil {
+				return fmt.Errorf("写 %s 失败: %w", bundleOutput, err)
+			}
+			fmt.Printf("恢复包已写入: %s（%s，%d 个分片）\n", bundleOutput, m.FileName, len(m.Fragments))
+			return nil
+		},
+	}
+	c.Flags().StringVar(&bundleManifest, "manifest", "", "manifest 路径（必填）")
+	c.Flags().StringVar(&bundleName, "name", "", "多文件 manifest 中要打包的原始文件名")
+	c.Flags().StringVar(&bundleOutput, "output", "", "输出路径（默认 <原文件名>.0gbundle）")
+	c.MarkFlagRequired("manifest")
+	return c
+}
+
+func writeBundle(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	info, err := json.MarshalIndent(bundleInfo{
+		Version:        BundleVersion,
+		FileName:       m.FileName,
+		FileSize:       m.FileSize,
+		Created:        time.Now().UTC().Format(time.RFC3339),
+		ManifestSHA256: hex.EncodeToString(sum[:]),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range []struct {
+		name string
+		data []byte
+	}{{bundleInfoName, info}, {bundleManifestFn, data}} {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), ModTime: time.Now(), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// 读取恢复包并校验版本和 manifest 的 sha256，返回 manifest 的原始字节
+func readBundle(path string) (*bundleInfo, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	entries := map[string][]byte{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s 不是有效的恢复包: %w", path, err)
+		}
+		if hdr.Name != bundleInfoName && hdr.Name != bundleManifestFn {
+			continue // 以后的版本可能附带更多元数据
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxBundleEntry+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(data) > maxBundleEntry {
+			return nil, nil, fmt.Errorf("%s 中的 %s 过大", path, hdr.Name)
+		}
+		entries[hdr.Name] = data
+	}
+
+	var info bundleInfo
+	if err := json.Unmarshal(entries[bundleInfoName], &info); err != nil {
+		return nil, nil, fmt.Errorf("%s 缺少有效的 %s: %w", path, bundleInfoName, err)
+	}
+	if info.Version > BundleVersion {
+		return nil, nil, fmt.Errorf("恢复包版本 %d 高于当前程序支持的版本 %d，请升级程序后再试", info.Version, BundleVersion)
+	}
+	data, ok := entries[bundleManifestFn]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s 中没有 %s", path, bundleManifestFn)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != info.ManifestSHA256 {
+		return nil, nil, fmt.Errorf("%s 校验失败: manifest sha256 为 %s，包内记录 %s", path, hex.EncodeToString(sum[:]), info.ManifestSHA256)
+	}
+	return &info, data, nil
+}
+
+// download --bundle：把包里的 manifest 解到临时目录，之后和 --manifest 走同样的流程
+func manifestFromBundle(path string) (string, func(), error) {
+	info, data, err := readBundle(path)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := makeTempDir("0g-bundle-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	manifest := filepath.Join(dir, bundleManifestFn)
+	if err := os.WriteFile(manifest, data, 0644); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	fmt.Printf("恢复包 %s: %s，%d bytes，创建于 %s\n", path, info.FileName, info.FileSize, info.Created)
+	return manifest, cleanup, nil
+}
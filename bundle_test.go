@@ -0,0 +1,81 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 上传 → 打包 .0gbundle → 只凭 bundle 恢复；改动包内 manifest 后校验和不符，拒绝恢复
+func TestBundleRoundTrip(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3500, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "src.0gbundle")
+	if err := writeBundle(bundle, m); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(manifest) // 接收方只有 bundle
+
+	dlBundle = bundle
+	dlOutput = filepath.Join(dir, "restored.bin")
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dlOutput, data)
+
+	info, raw, err := readBundle(bundle)
+	if err != nil || info.Version != BundleVersion || info.FileSize != 3500 {
+		t.Fatalf("bundle 信息 %+v（%v）", info, err)
+	}
+	tampered := filepath.Join(dir, "tampered.0gbundle")
+	writeTarEntries(t, tampered, map[string][]byte{
+		bundleInfoName:   mustReadBundleInfo(t, bundle),
+		bundleManifestFn: bytes.Replace(raw, []byte(m.Fragments[0].Root), []byte(m.Fragments[1].Root), 1),
+	})
+	dlBundle, dlManifests = tampered, nil
+	if err := restoreFromManifest(); err == nil || !strings.Contains(err.Error(), "校验失败") {
+		t.Fatalf("manifest 被改动的 bundle 应拒绝恢复，实际 %v", err)
+	}
+}
+
+func mustReadBundleInfo(t *testing.T, path string) []byte {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("bundle 中没有 %s: %v", bundleInfoName, err)
+		}
+		if hdr.Name == bundleInfoName {
+			var buf bytes.Buffer
+			buf.ReadFrom(tr)
+			return buf.Bytes()
+		}
+	}
+}
+
+func writeTarEntries(t *testing.T, path string, entries map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, data := range entries {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write(data)
+	}
+	tw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
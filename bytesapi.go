@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// uploadBytes / downloadBytes 给本程序里数据已经在内存中的调用方用：按 fragmentSize 切分内存中的切片，
+// 不生成本地分片文件。这里是 package main，不能被其他模块导入，也就不是对外的库接口。
+// 后端实现 bytesStorage 时全程不落盘（目前只有测试里的内存后端）；SDK 后端只接受文件路径，
+// 每次只把当前分片写到临时文件，上传/读取后立即删除
+
+const maxBytesPrealloc = 64 << 20 // downloadBytes 按 manifest 预分配的上限，更大的数据边下载边增长
+
+// 可选：能直接上传/下载字节的后端实现它
+type bytesStorage interface {
+	UploadBytes(data []byte) (root string, err error)
+	DownloadBytes(root string, indexer string) ([]byte, error)
+}
+
+// 切分并上传 data，返回的 manifest 没有文件名，可以直接交给 downloadBytes
+func uploadBytes(ctx context.Context, data []byte) (Manifest, error) {
+	prevCtx := runCtx
+	runCtx = ctx
+	defer func() { runCtx = prevCtx }()
+
+	if len(data) == 0 {
+		return Manifest{}, fmt.Errorf("数据为空")
+	}
+	if fragmentSize <= 0 {
+		return Manifest{}, fmt.Errorf("分片大小必须大于 0: %d", fragmentSize)
+	}
+	if fragmentHashAlgo == "" {
+		fragmentHashAlgo = "sha256"
+	}
+	if n := (int64(len(data)) + fragmentSize - 1) / fragmentSize; maxFragments > 0 && n > int64(maxFragments) {
+		return Manifest{}, fmt.Errorf("数据会切成 %d 个分片，超过 --max-fragments %d", n, maxFragments)
+	}
+
+	var frags []Fragment
+	var roots []string
+	for off := int64(0); off < int64(len(data)); off += fragmentSize {
+		chunk := data[off:min(off+fragmentSize, int64(len(data)))]
+		index := len(frags)
+		sum, err := fragmentDigest(fragmentHashAlgo, chunk)
+		if err != nil {
+			return Manifest{}, err
+		}
+		root, err := withFragmentRetry("上传", index, int64(len(chunk)), func() (string, error) {
+			return uploadChunk(chunk)
+		}, nil)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("上传分片 %d 失败: %w", index+1, err)
+		}
+		metrics.addBytes("上传", int64(len(chunk)))
+		frags = append(frags, Fragment{Index: index, Offset: off, Size: int64(len(chunk)), Hash: sum})
+		roots = append(roots, root)
+	}
+
+	var originMD5 string
+	if !noVerify {
+		sum := md5.Sum(data)
+		originMD5 = hex.EncodeToString(sum[:])
+	}
+	m := buildManifest("", originMD5, frags, roots)
+	m.FileName = ""
+	if noVerify {
+		m.HashAlgo = ""
+	}
+	return *m, nil
+}
+
+// 按 manifest 下载全部分片并校验，返回拼接后的数据。
+// 纠删码、压缩和分段上传的 manifest 请用 download 子命令恢复到文件
+func downloadBytes(ctx context.Context, m Manifest) ([]byte, error) {
+	prevCtx := runCtx
+	runCtx = ctx
+	defer func() { runCtx = prevCtx }()
+
+	if m.Erasure != nil {
+		return nil, fmt.Errorf("downloadBytes 不支持纠删码 manifest")
+	}
+	frags := append([]ManifestFragment(nil), m.Fragments...)
+	sort.Slice(frags, func(i, j int) bool { return frags[i].Index < frags[j].Index })
+
+	// file_size 来自 manifest，不能直接按它预分配：损坏或恶意的 manifest 会让这里一次申请巨大的内存
+	out := make([]byte, 0, min(max(m.FileSize, 0), maxBytesPrealloc))
+	for _, frag := range frags {
+		if frag.Codec != "" || len(frag.Subs) > 0 {
+			return nil, fmt.Errorf("分片 %d 经过压缩或分段上传，downloadBytes 不支持", frag.Index+1)
+		}
+		if frag.Offset != int64(len(out)) {
+			return nil, fmt.Errorf("分片 %d 的 offset %d 与已拼接的 %d bytes 不连续", frag.Index+1, frag.Offset, len(out))
+		}
+		if frag.Size < 0 || frag.Offset+frag.Size > m.FileSize {
+			return nil, fmt.Errorf("分片 %d（offset %d，%d bytes）超出文件大小 %d bytes", frag.Index+1, frag.Offset, frag.Size, m.FileSize)
+		}
+		if frag.Zero {
+			out = append(out, make([]byte, frag.Size)...)
+			continue
+		}
+		data, err := withFragmentRetry("下载", frag.Index, frag.uploadedSize(), func() ([]byte, error) {
+			b, err := downloadChunk(frag)
+			if err != nil {
+				return nil, err
+			}
+			return b, verifyFragmentBytes(&m, frag, b)
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("下载分片 %d 失败: %w", frag.Index+1, err)
+		}
+		metrics.addBytes("下载", frag.storedSize())
+		out = append(out, data[:frag.Size]...) // --pad-last 补的零不要
+	}
+
+	if int64(len(out)) != m.FileSize {
+		return nil, fmt.Errorf("分片只覆盖了 %d bytes，manifest 记录文件大小 %d bytes", len(out), m.FileSize)
+	}
+	if m.HashAlgo == "md5" && m.OriginHash != "" {
+		sum := md5.Sum(out)
+		if got := hex.EncodeToString(sum[:]); got != m.OriginHash {
+			return nil, verifyErrorf("MD5 不一致: 原始 %s，恢复 %s", m.OriginHash, got)
+		}
+	}
+	return out, nil
+}
+
+func verifyFragmentBytes(m *Manifest, frag ManifestFragment, data []byte) error {
+	if int64(len(data)) != frag.storedSize() {
+		return fmt.Errorf("分片 %d 大小不符: 期望 %d bytes，实际 %d bytes", frag.Index+1, frag.storedSize(), len(data))
+	}
+	if m.FragmentHashAlgo == "" || frag.Hash == "" {
+		return nil
+	}
+	sum, err := fragmentDigest(m.FragmentHashAlgo, data)
+	if err != nil {
+		return err
+	}
+	if sum != frag.Hash {
+		dumpBadFragment(frag, m.FragmentHashAlgo, sum, bytes.NewReader(data))
+		return fmt.Errorf("分片 %d %s 校验失败: 期望 %s，实际 %s", frag.Index+1, m.FragmentHashAlgo, frag.Hash, sum)
+	}
+	return nil
+}
+
+func uploadChunk(chunk []byte) (string, error) {
+	if bs, ok := storage.(bytesStorage); ok {
+		return bs.UploadBytes(chunk)
+	}
+	f, err := os.CreateTemp("", "0g-mem-*.dat")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(chunk)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return storage.Upload(f.Name())
+}
+
+// 当前 indexer 找不到时回退到上传时的 indexer，同 downloadStoredRoot
+func downloadChunk(frag ManifestFragment) ([]byte, error) {
+	data, err := downloadChunkFrom(frag.Root, indexerURL)
+	if err != nil && isNotFound(err) && dlNode == "" && frag.Indexer != "" && frag.Indexer != indexerURL {
+		data, err = downloadChunkFrom(frag.Root, frag.Indexer)
+	}
+	return data, err
+}
+
+func downloadChunkFrom(root, indexer string) ([]byte, error) {
+	if bs, ok := storage.(bytesStorage); ok {
+		return bs.DownloadBytes(root, indexer)
+	}
+	tmpPath, err := storage.Download(root, indexer)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+	return os.ReadFile(tmpPath)
+}
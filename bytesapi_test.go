@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// memStorage 实现 bytesStorage：uploadBytes / downloadBytes 往返全程不写临时文件
+func TestBytesRoundTripInMemory(t *testing.T) {
+	setupTest(t)
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	storage = newMemStorage()
+	fragmentSize = 1000
+	data := make([]byte, 3500)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+
+	m, err := uploadBytes(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Fragments) != 4 || m.FileName != "" {
+		t.Fatalf("manifest 有 %d 个分片、文件名 %q", len(m.Fragments), m.FileName)
+	}
+	got, err := downloadBytes(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(data) {
+		t.Fatal("downloadBytes 返回的数据与上传的不一致")
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Fatalf("内存后端不应写临时文件: %v", entries)
+	}
+}
+
+// manifest 的 file_size 被改成巨大的值：不按它预分配内存，并报告分片覆盖不到记录的大小
+func TestDownloadBytesRejectsInflatedFileSize(t *testing.T) {
+	setupTest(t)
+	storage = newMemStorage()
+	fragmentSize = 1000
+	m, err := uploadBytes(context.Background(), make([]byte, 2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.FileSize = 1 << 50
+	if _, err := downloadBytes(context.Background(), m); err == nil || !strings.Contains(err.Error(), "manifest 记录文件大小") {
+		t.Fatalf("file_size 与分片不符时应报错，实际 %v", err)
+	}
+
+	m.FileSize = 1500
+	if _, err := downloadBytes(context.Background(), m); err == nil || !strings.Contains(err.Error(), "超出文件大小") {
+		t.Fatalf("分片超出 file_size 时应报错，实际 %v", err)
+	}
+}
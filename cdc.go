@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// --cdc：按内容定义分片边界（Gear 滚动哈希），文件中间插入或删除字节时只影响附近的分片，
+// 后面分片的哈希和 root 不变，同一文件的不同版本之间可以大量复用。
+// 平均分片大小取 --fragment-size，最小 1/4，最大 4 倍
+var cdcMode bool
+
+// 固定种子生成的 Gear 表，保证同样的内容在任何机器上切出同样的边界
+var gearTable = func() [256]uint64 {
+	var t [256]uint64
+	x := uint64(0x0123456789abcdef)
+	for i := range t {
+		// splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		t[i] = z ^ (z >> 31)
+	}
+	return t
+}()
+
+// 计算文件的分片边界，格式和 --offsets 相同（从 0 开始，以文件大小结束）
+func cdcBounds(path string, avgSize int64) ([]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return cdcBoundsReader(bufio.NewReaderSize(ctxReader{runCtx, f}, 1<<20), avgSize)
+}
+
+func cdcBoundsReader(r io.ByteReader, avgSize int64) ([]int64, error) {
+	minSize, maxSize := max(avgSize/4, 1), avgSize*4
+	// 取哈希的高位判断边界，平均每 2^n 字节命中一次
+	n := bits.Len64(uint64(avgSize)) - 1
+	mask := ^uint64(0) << (64 - n)
+	if n == 0 {
+		mask = 0
+	}
+
+	bounds := []int64{0}
+	var pos, start int64
+	var h uint64
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		pos++
+		h = h<<1 + gearTable[b]
+		size := pos - start
+		if size < minSize {
+			continue
+		}
+		if h&mask == 0 || size >= maxSize {
+			bounds = append(bounds, pos)
+			start, h = pos, 0
+		}
+	}
+	if pos > start {
+		bounds = append(bounds, pos)
+	}
+	return bounds, nil
+}
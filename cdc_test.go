@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// v2 在 v1 开头附近插入 10 个字节：--cdc 时后面绝大多数分片的哈希和 root 不变，固定大小切分时几乎全变
+func TestCDCInsertKeepsDownstreamFragments(t *testing.T) {
+	v1 := make([]byte, 256<<10)
+	rand.New(rand.NewSource(1)).Read(v1)
+	v2 := append(append(append([]byte(nil), v1[:100]...), []byte("0123456789")...), v1[100:]...)
+
+	shared := func(cdc bool) (float64, int) {
+		dir := setupTest(t)
+		cdcMode = cdc
+		roots := map[string]bool{}
+		var m2 *Manifest
+		for i, data := range [][]byte{v1, v2} {
+			path := filepath.Join(dir, []string{"v1.bin", "v2.bin"}[i])
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			manifestPath = ""
+			fragmentSize = 4096 // --cdc 会把 fragmentSize 改成最大分片（传给 SDK），每次重新设置
+			m := uploadTestFile(t, path)
+			if i == 0 {
+				for _, frag := range m.Fragments {
+					roots[frag.Hash+frag.Root] = true
+				}
+			}
+			m2 = m
+		}
+		same := 0
+		for _, frag := range m2.Fragments {
+			if roots[frag.Hash+frag.Root] {
+				same++
+			}
+		}
+		return float64(same) / float64(len(m2.Fragments)), len(m2.Fragments)
+	}
+
+	cdcShare, n := shared(true)
+	if n < 16 {
+		t.Fatalf("--cdc 只切出 %d 个分片，测试数据不够", n)
+	}
+	if cdcShare < 0.8 {
+		t.Fatalf("--cdc 时只有 %.0f%% 的分片不变，期望至少 80%%", cdcShare*100)
+	}
+	fixedShare, _ := shared(false)
+	if fixedShare > 0.1 {
+		t.Fatalf("固定大小切分时 %.0f%% 的分片不变，插入字节后应几乎全部改变", fixedShare*100)
+	}
+}
+
+// manifest 记录可变的分片长度，首尾相接覆盖整个文件
+func TestCDCManifestRecordsVariableLengths(t *testing.T) {
+	dir := setupTest(t)
+	cdcMode = true
+	const avg = 4096
+	fragmentSize = avg
+	data := make([]byte, 128<<10)
+	rand.New(rand.NewSource(2)).Read(data)
+	path := filepath.Join(dir, "data.bin")
+	os.WriteFile(path, data, 0644)
+	m := uploadTestFile(t, path)
+
+	if m.Chunking != "cdc-gear" {
+		t.Fatalf("manifest chunking = %q", m.Chunking)
+	}
+	sizes := map[int64]bool{}
+	var offset int64
+	for _, frag := range m.Fragments {
+		if frag.Offset != offset || frag.Size < avg/4 && frag.Offset+frag.Size != m.FileSize || frag.Size > avg*4 {
+			t.Fatalf("分片 %d: offset %d size %d 不符合边界约束", frag.Index, frag.Offset, frag.Size)
+		}
+		sizes[frag.Size] = true
+		offset += frag.Size
+	}
+	if offset != int64(len(data)) || len(sizes) < 2 {
+		t.Fatalf("分片覆盖 %d bytes（文件 %d），%d 种不同长度", offset, len(data), len(sizes))
+	}
+	assertFileContent(t, path+".restored", data)
+}
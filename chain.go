@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Flow 合约地址来自存储节点的 zgs_getStatus
+func flowAddress() (string, error) {
+	node, err := firstStorageNode()
+	if err != nil {
+		return "", err
+	}
+	var status struct {
+		NetworkIdentity struct {
+			FlowAddress string `json:"flowAddress"`
+		} `json:"networkIdentity"`
+	}
+	if err := rpcCall(node, "zgs_getStatus", []interface{}{}, &status); err != nil {
+		return "", err
+	}
+	flow := status.NetworkIdentity.FlowAddress
+	if !common.IsHexAddress(flow) {
+		return "", fmt.Errorf("存储节点 %s 没有返回 Flow 合约地址", node)
+	}
+	return flow, nil
+}
+
+// 从 Flow.market() 找到 Market 合约读单价
+func marketPrice(flow string) (*big.Int, error) {
+	ret, err := ethCall(flow, "market()")
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) < 32 {
+		return nil, fmt.Errorf("Flow.market() 返回值无效")
+	}
+	market := "0x" + hex.EncodeToString(ret[12:32])
+	if ret, err = ethCall(market, "pricePerSector()"); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(ret), nil
+}
+
+func firstStorageNode() (string, error) {
+	var nodes struct {
+		Trusted []struct {
+			URL string `json:"url"`
+		} `json:"trusted"`
+	}
+	if err := rpcCall(indexerURL, "indexer_getShardedNodes", []interface{}{}, &nodes); err != nil {
+		return "", err
+	}
+	if len(nodes.Trusted) == 0 {
+		return "", fmt.Errorf("indexer 没有返回存储节点")
+	}
+	return nodes.Trusted[0].URL, nil
+}
+
+// 无参数的只读合约调用
+func ethCall(to string, method string) ([]byte, error) {
+	data := "0x" + hex.EncodeToString(crypto.Keccak256([]byte(method))[:4])
+	var ret string
+	call := map[string]string{"to": to, "data": data}
+	if err := rpcCall(rpcURL, "eth_call", []interface{}{call, "latest"}, &ret); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimPrefix(ret, "0x"))
+}
+
+// JSON-RPC 的 0x 十六进制数量
+func parseQuantity(s string) (*big.Int, error) {
+	v, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("无效的数值: %q", s)
+	}
+	return v, nil
+}
+
+// wei 显示为 0G（1e18 wei），保留 6 位小数
+func formatWei(v *big.Int) string {
+	f := new(big.Float).Quo(new(big.Float).SetInt(v), big.NewFloat(1e18))
+	return f.Text('f', 6) + " 0G"
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 上传过程中每完成一个分片就写一次 checkpoint（<file>.0gresume），
+// 中断后加 --resume 重跑可以跳过已经上传且确认存储的分片。全部完成并写出 manifest 后删除。
+type Checkpoint struct {
+	FileSize         int64                   `json:"file_size"`
+	FragmentSize     int64                   `json:"fragment_size"`
+	Erasure          string                  `json:"erasure,omitempty"`
+	FragmentHashAlgo string                  `json:"fragment_hash_algo"`
+	Compress         string                  `json:"compress,omitempty"`
+	Completed        map[int]checkpointEntry `json:"completed"` // 分片 Index -> 上传结果
+
+	path string
+	mu   sync.Mutex
+}
+
+type checkpointEntry struct {
+	Root string        `json:"root"` // 分段上传时为空
+	Hash string        `json:"hash"`
+	Subs []SubFragment `json:"subs,omitempty"` // --fragment-retry-different-size 分段上传的各段
+}
+
+// 分片对应的存储 root：整片一个，分段上传时每段一个
+func (e checkpointEntry) roots() []string {
+	if len(e.Subs) == 0 {
+		return []string{e.Root}
+	}
+	roots := make([]string, len(e.Subs))
+	for i, sub := range e.Subs {
+		roots[i] = sub.Root
+	}
+	return roots
+}
+
+var (
+	resumeUpload   bool   // --resume
+	resumeManifest string // --resume-manifest：checkpoint 丢失时用（部分写出的）manifest 代替
+)
+
+// 打开 checkpoint；resume 为 false 或旧 checkpoint 和本次参数不一致时从头开始
+func openCheckpoint(path string, src string, resume bool) (*Checkpoint, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	fresh := &Checkpoint{
+		FileSize:         info.Size(),
+		FragmentSize:     fragmentSize,
+		Erasure:          erasureSpec,
+		FragmentHashAlgo: fragmentHashAlgo,
+		Compress:         compressCodec,
+		Completed:        map[int]checkpointEntry{},
+		path:             path,
+	}
+	if !resume {
+		return fresh, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Printf("没有找到 checkpoint %s，从头上传\n", path)
+		return fresh, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var old Checkpoint
+	if err := json.Unmarshal(data, &old); err != nil {
+		logrus.Warnf("checkpoint 损坏，从头上传: %v", err)
+		return fresh, nil
+	}
+	if old.FileSize != fresh.FileSize || old.FragmentSize != fresh.FragmentSize ||
+		old.Erasure != fresh.Erasure || old.FragmentHashAlgo != fresh.FragmentHashAlgo || old.Compress != fresh.Compress {
+		logrus.Warn("checkpoint 与当前文件或参数不一致，从头上传")
+		return fresh, nil
+	}
+	if old.Completed == nil {
+		old.Completed = map[int]checkpointEntry{}
+	}
+	old.path = path
+	fmt.Printf("从 checkpoint 恢复: 已完成 %d 个分片\n", len(old.Completed))
+	return &old, nil
+}
+
+// 用 manifest 中已有 root 的分片重建 checkpoint。哈希和存储状态的校验照常在 pending 里做，
+// 所以 manifest 里过期或来自别的文件的条目不会被误用
+func checkpointFromManifest(manifestFile string, name string, path string, src string) (*Checkpoint, error) {
+	c, err := openCheckpoint(path, src, false)
+	if err != nil {
+		return nil, err
+	}
+	m, err := loadManifestFor(manifestFile, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("读取 --resume-manifest 失败: %w", err)
+	}
+	if (m.Erasure != nil) != (erasureSpec != "") || m.FragmentHashAlgo != fragmentHashAlgo || m.Compress != c.manifestCompress() ||
+		m.FileSize != c.FileSize || (m.Erasure == nil && m.FragmentSize != fragmentSize) {
+		return nil, fmt.Errorf("%s 与当前文件或参数不一致（文件大小、分片大小、纠删码、分片哈希算法或压缩方式不同）", manifestFile)
+	}
+	for _, frag := range m.Fragments {
+		if frag.Root != "" || len(frag.Subs) > 0 {
+			c.Completed[frag.Index] = checkpointEntry{Root: frag.Root, Hash: frag.Hash, Subs: frag.Subs}
+		}
+	}
+	fmt.Printf("从 manifest %s 恢复: 已有 %d 个分片的 root\n", manifestFile, len(c.Completed))
+	return c, nil
+}
+
+// manifest 里不压缩时不写 compress 字段
+func (c *Checkpoint) manifestCompress() string {
+	if c.Compress == "none" {
+		return ""
+	}
+	return c.Compress
+}
+
+// 返回仍需上传的分片，并把可以跳过的分片 root 填进 roots（分段上传的分片各段记回 subUploads）。
+// checkpoint 里的分片必须哈希和本次切分一致，且存储节点确认已完整存储（分段的每一段都要），才会跳过。
+func (c *Checkpoint) pending(frags []Fragment, roots []string) []Fragment {
+	var todo []Fragment
+	for _, frag := range frags {
+		e, ok := c.Completed[frag.Index]
+		if !ok {
+			todo = append(todo, frag)
+			continue
+		}
+		if e.Hash != frag.Hash {
+			logrus.Warnf("分片 %d 内容与 checkpoint 不一致，重新上传", frag.Index+1)
+			todo = append(todo, frag)
+			continue
+		}
+
+		stored, err := allStored(e.roots())
+		if err != nil {
+			logrus.Warnf("查询分片 %d 存储状态失败，重新上传: %v", frag.Index+1, err)
+			todo = append(todo, frag)
+			continue
+		}
+		if !stored {
+			fmt.Printf("分片 %d 已提交但尚未完整存储，重新上传\n", frag.Index+1)
+			todo = append(todo, frag)
+			continue
+		}
+
+		if len(e.Subs) > 0 {
+			subUploads.set(frag.Index, e.Subs)
+			fmt.Printf("分片 %d 的 %d 段已确认存储，跳过\n", frag.Index+1, len(e.Subs))
+			continue
+		}
+		roots[frag.Index] = e.Root
+		fmt.Printf("分片 %d 已确认存储，跳过（root = %s）\n", frag.Index+1, e.Root)
+	}
+	return todo
+}
+
+func allStored(roots []string) (bool, error) {
+	for _, root := range roots {
+		if stored, err := fragmentStored(root); err != nil || !stored {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// 记录一个上传完成的分片；分段上传时 root 为空，subs 为各段
+func (c *Checkpoint) record(frag Fragment, root string, subs []SubFragment) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Completed[frag.Index] = checkpointEntry{Root: root, Hash: frag.Hash, Subs: subs}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data, 0644)
+}
+
+func (c *Checkpoint) remove() {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		logrus.Warnf("删除 checkpoint 失败: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 本地文件名包含 fail 的分片上传失败
+type failOnStorage struct {
+	*countingStorage
+	fail string
+}
+
+func (s failOnStorage) Upload(path string) (string, error) {
+	if strings.Contains(filepath.Base(path), s.fail) {
+		return "", errors.New("connection reset")
+	}
+	return s.countingStorage.Upload(path)
+}
+
+// checkpoint 里记录了全部分片，但存储状态查询报告分片 2 没有完整存储：--resume 只重新上传这一个
+func TestResumeReuploadsFragmentNotStored(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 3000, 4)
+	m := uploadTestFile(t, path)
+
+	// 模拟上一次运行在写 manifest 之前中断，留下了全部分片的 checkpoint
+	ckptPath := filepath.Join(dir, "data.bin.0gresume")
+	ckpt, err := openCheckpoint(ckptPath, path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frag := range m.Fragments {
+		ckpt.Completed[frag.Index] = checkpointEntry{Root: frag.Root, Hash: frag.Hash}
+	}
+	saved, _ := json.Marshal(ckpt)
+	os.WriteFile(ckptPath, saved, 0644)
+
+	lost := m.Fragments[1].Root
+	indexerURL = newFakeNodeServer(t, func(root string) *nodeFileInfo {
+		return &nodeFileInfo{Finalized: root != lost}
+	}).URL
+	s := useCountingStorage()
+	resumeUpload = true
+	m2 := uploadTestFile(t, path)
+
+	if s.uploadCount() != 1 || s.uploads[0] != lost {
+		t.Fatalf("期望只重新上传分片 2（%s），实际上传 %v", lost, s.uploads)
+	}
+	for i, frag := range m2.Fragments {
+		if frag.Root != m.Fragments[i].Root {
+			t.Fatalf("分片 %d 的 root 变了: %s -> %s", i, m.Fragments[i].Root, frag.Root)
+		}
+	}
+	assertFileContent(t, path+".restored", data)
+	if _, err := os.Stat(ckptPath); !os.IsNotExist(err) {
+		t.Fatal("上传完成后 checkpoint 应被删除")
+	}
+}
+
+// 上一次运行中断后只剩部分 manifest、没有 .0gresume：--resume-manifest 只上传缺失的分片，
+// 以及内容与 manifest 记录的哈希不一致的分片
+func TestResumeManifestUploadsOnlyMissing(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	fragmentRetries = 0
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 4000, 2)
+	filePath = path
+	manifestPath = path + ".manifest.json"
+	store := testStore()
+	storage = failOnStorage{countingStorage: useCountingStorage(), fail: "fragment_003"}
+	if err := run(); err == nil {
+		t.Fatal("分片 4 上传失败时 run 应返回错误")
+	}
+	partial := path + ".manifest.partial.json"
+	pm, err := loadManifest(partial)
+	if err != nil {
+		t.Fatalf("没有写出部分 manifest: %v", err)
+	}
+	var done []int
+	for _, frag := range pm.Fragments {
+		if frag.Root != "" {
+			done = append(done, frag.Index)
+		}
+	}
+	if len(done) != 3 {
+		t.Fatalf("部分 manifest 中已完成的分片 %v，期望 3 个", done)
+	}
+	os.Remove(path + ".0gresume") // checkpoint 丢了，只剩部分 manifest
+
+	// 分片 2 的内容在两次运行之间变了，manifest 里记录的哈希不能再信任
+	data[1500] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	newRootCmd()
+	indexerURL = newFakeNodeServer(t, func(root string) *nodeFileInfo { return &nodeFileInfo{Finalized: true} }).URL
+	fragmentSize = 1000
+	filePath, manifestPath, resumeManifest = path, path+".manifest.json", partial
+	storage = store
+	s := useCountingStorage()
+	if err := run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.uploadCount() != 2 {
+		t.Fatalf("期望只上传分片 2 和 4，实际上传 %d 个", s.uploadCount())
+	}
+	m, err := loadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Fragments[0].Root != pm.Fragments[0].Root || m.Fragments[2].Root != pm.Fragments[2].Root {
+		t.Fatal("未变化的分片应沿用部分 manifest 中的 root")
+	}
+	if m.Fragments[1].Root == pm.Fragments[1].Root {
+		t.Fatal("内容变化的分片 2 应重新上传")
+	}
+	assertFileContent(t, path+".restored", data)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// --compress：上传前逐个压缩分片，manifest 记录每个分片实际使用的编码，下载后先解压再校验分片哈希。
+// root 对应压缩后的数据，分片哈希、offset、size 仍按原始数据记录。
+var (
+	compressCodec = "zstd"
+	compressSet   bool // 命令行显式给了 --compress；没给时不支持压缩的模式直接按 none 处理，不报冲突
+)
+
+const (
+	autoSampleSize = 1 << 20 // --compress auto 时每个分片取样的字节数
+	autoMinSaving  = 0.10    // 取样压缩后至少省 10% 才值得压缩
+)
+
+type codec struct {
+	ext       string
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+	newReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var codecs = map[string]codec{
+	"gzip": {
+		ext:       ".gz",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+	"zstd": {
+		ext: ".zst",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+	},
+	"lz4": {
+		ext:       ".lz4",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) { return lz4.NewWriter(w), nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(lz4.NewReader(r)), nil },
+	},
+}
+
+// 默认的 zstd 只在支持压缩的流程里生效：incompatible 的模式下没有显式指定 --compress 时改为 none
+func defaultCompressOff(incompatible bool) {
+	if incompatible && !compressSet {
+		compressCodec = "none"
+	}
+}
+
+func validateCompress(name string) error {
+	if name == "none" || name == "auto" {
+		return nil
+	}
+	if _, ok := codecs[name]; ok {
+		return nil
+	}
+	names := []string{"none", "auto"}
+	for n := range codecs {
+		names = append(names, n)
+	}
+	sort.Strings(names[2:])
+	return fmt.Errorf("不支持的 --compress: %s（可选 %s）", name, strings.Join(names, " / "))
+}
+
+// 按 --compress 压缩每个分片，原分片文件替换为压缩文件；auto 时不值得压缩的分片保持原样（Codec 为空）
+func compressFragments(frags []Fragment) error {
+	var before, after int64
+	for i := range frags {
+		frag := &frags[i]
+		if frag.Zero {
+			continue // 不上传，也就不用压缩
+		}
+		name := compressCodec
+		if name == "auto" {
+			var err error
+			if name, err = chooseCodec(frag.Path); err != nil {
+				return fmt.Errorf("分片 %d 取样失败: %w", frag.Index+1, err)
+			}
+		}
+		before += frag.Size
+		if name == "none" {
+			after += frag.Size
+			fmt.Printf("分片 %d 不压缩（取样压缩率不足 %.0f%%）\n", frag.Index+1, autoMinSaving*100)
+			continue
+		}
+
+		dst := frag.Path + codecs[name].ext
+		n, err := compressFile(codecs[name], frag.Path, dst)
+		if err != nil {
+			os.Remove(dst)
+			return fmt.Errorf("压缩分片 %d 失败: %w", frag.Index+1, err)
+		}
+		os.Remove(frag.Path) // 只上传压缩文件，不再需要原分片
+		frag.Path, frag.Codec, frag.CompressedSize = dst, name, n
+		after += n
+		fmt.Printf("分片 %d 已用 %s 压缩: %d -> %d bytes\n", frag.Index+1, name, frag.Size, n)
+	}
+	if before > 0 {
+		fmt.Printf("压缩完成: %d -> %d bytes（%.1f%%）\n", before, after, float64(after)*100/float64(before))
+	}
+	return nil
+}
+
+func compressFile(c codec, src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	w, err := c.newWriter(out)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.CopyBuffer(w, ctxReader{runCtx, in}, make([]byte, ioBufferSize)); err != nil {
+		w.Close()
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	info, err := out.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), out.Close()
+}
+
+// --compress auto：取分片开头一段用 zstd 试压，省下的空间不足 autoMinSaving（已压缩/加密的数据）就不压缩
+func chooseCodec(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var cw countingWriter
+	w, err := codecs["zstd"].newWriter(&cw)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(w, io.LimitReader(f, autoSampleSize))
+	if err != nil {
+		w.Close()
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if n == 0 || float64(cw.n) > float64(n)*(1-autoMinSaving) {
+		return "none", nil
+	}
+	return "zstd", nil
+}
+
+type countingWriter struct{ n int64 }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// 下载到的压缩分片解压到新的临时文件，返回解压后的路径；压缩文件删除
+func decompressFragment(frag ManifestFragment, path string) (string, error) {
+	defer os.Remove(path)
+	c, ok := codecs[frag.Codec]
+	if !ok {
+		return "", fmt.Errorf("分片 %d 使用了不支持的压缩编码 %q", frag.Index+1, frag.Codec)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	r, err := c.newReader(ctxReader{runCtx, in})
+	if err != nil {
+		return "", fmt.Errorf("分片 %d 解压失败: %w", frag.Index+1, err)
+	}
+	defer r.Close()
+
+	out, err := os.CreateTemp("", "0g-download-*.dat")
+	if err != nil {
+		return "", err
+	}
+	// 多读 1 字节用于发现解压结果比记录的大小长
+	n, err := io.CopyBuffer(out, io.LimitReader(r, frag.storedSize()+1), make([]byte, ioBufferSize))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n != frag.storedSize() {
+		err = fmt.Errorf("分片 %d 解压后大小不符: 期望 %d bytes，实际 %d bytes", frag.Index+1, frag.storedSize(), n)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
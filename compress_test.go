@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 每种编码都能上传后恢复出原文件，manifest 按分片记录实际使用的编码
+func TestCompressRoundTrip(t *testing.T) {
+	for _, name := range []string{"gzip", "zstd", "lz4", "none"} {
+		dir := setupTest(t)
+		fragmentSize = 1000
+		compressCodec = name
+		path := filepath.Join(dir, "data.bin")
+		data := writeTestFile(t, path, 3500, 1)
+		m := uploadTestFile(t, path)
+
+		want := name
+		if name == "none" {
+			want = ""
+		}
+		for _, frag := range m.Fragments {
+			if frag.Codec != want {
+				t.Fatalf("--compress %s: 分片 %d 记录的编码是 %q", name, frag.Index, frag.Codec)
+			}
+		}
+		dlManifests = []string{manifestPath}
+		dlOutput = filepath.Join(dir, "restored.bin")
+		if err := restoreFromManifest(); err != nil {
+			t.Fatalf("--compress %s: 恢复失败: %v", name, err)
+		}
+		assertFileContent(t, dlOutput, data)
+	}
+}
+
+// --compress auto：可压缩的分片用 zstd，随机数据（模拟已压缩 / 加密的内容）保持原样，混合的 manifest 照样恢复
+func TestCompressAutoMixedCodecs(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 4096
+	compressCodec = "auto"
+	path := filepath.Join(dir, "data.bin")
+	data := make([]byte, 8192)
+	rand.Read(data[4096:]) // 分片 0 保持全零，压缩率很高
+	os.WriteFile(path, data, 0644)
+	m := uploadTestFile(t, path)
+
+	if m.Fragments[0].Codec != "zstd" || m.Fragments[1].Codec != "" {
+		t.Fatalf("auto 应只压缩分片 0，实际编码 %q / %q", m.Fragments[0].Codec, m.Fragments[1].Codec)
+	}
+	dlManifests = []string{manifestPath}
+	dlOutput = filepath.Join(dir, "restored.bin")
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dlOutput, data)
+}
+
+// 默认 zstd；不支持压缩的模式没有显式指定时按 none 处理，显式指定才报冲突
+func TestCompressDefault(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 2500, 2)
+	if m := uploadTestFile(t, path); m.Fragments[0].Codec != "zstd" {
+		t.Fatalf("默认应使用 zstd，实际 %q", m.Fragments[0].Codec)
+	}
+
+	setupTest(t)
+	fragmentSize, erasureSpec = 1000, "2:3"
+	manifestPath = ""
+	if m := uploadTestFile(t, path); m.Fragments[0].Codec != "" {
+		t.Fatalf("--erasure 时默认不应压缩，实际 %q", m.Fragments[0].Codec)
+	}
+
+	setupTest(t)
+	fragmentSize, erasureSpec = 1000, "2:3"
+	compressCodec, compressSet = "zstd", true
+	filePath, manifestPath = path, ""
+	if err := run(); err == nil || exitCode(err) != ExitConfig {
+		t.Fatalf("显式 --compress zstd 和 --erasure 同时使用应报参数错误，实际 %v", err)
+	}
+}
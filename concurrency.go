@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxAutoConcurrency = 8   // 自动调节的并发上限
+	autoGainThreshold  = 1.1 // 吞吐至少提升 10% 才继续加并发
+)
+
+type uploadFunc func(frag Fragment) (string, error)
+
+// --fail-fast：有分片失败后不再派发新的分片（已经在传的会传完）。默认全部尝试一遍，
+// 成功的分片都记进 roots / checkpoint，失败的一次性报出来
+var failFast bool
+
+// 单个分片的上传结果。Done 为 false 表示 --fail-fast 停止派发后没有尝试
+type uploadResult struct {
+	Root string
+	Err  error
+	Done bool
+}
+
+// 各阶段的 worker 数：单独指定的优先，否则沿用 --concurrency
+func uploadWorkers() int {
+	if uploadConcurrency > 0 {
+		return uploadConcurrency
+	}
+	return max(concurrency, 1)
+}
+
+func downloadWorkers() int {
+	if downloadConcurrency > 0 {
+		return downloadConcurrency
+	}
+	return max(concurrency, 1)
+}
+
+// 上传 frags，把结果写进 roots（roots[i] 是 Index 为 i 的分片的 root），返回最终使用的并发数。
+// frags 可以只是全部分片的一部分（例如断点续传时剩下的），roots 按全部分片数分配
+func uploadFragments(frags []Fragment, roots []string, upload uploadFunc) (int, error) {
+	if !concurrencyAuto {
+		workers := uploadWorkers()
+		metrics.setConcurrency(workers)
+		return workers, collectResults(frags, runPool(frags, workers, upload), roots)
+	}
+
+	// 自动模式只调节并发数：分片的重试由 upload 里的 withFragmentRetry 负责（--retries / --max-total-retries），
+	// 这里失败就是最终失败，和固定并发一样最后由 collectResults 汇总
+	ctl := newAutoController(maxAutoConcurrency)
+	results := make([]uploadResult, len(frags))
+	for next := 0; next < len(frags); {
+		// 每轮上传 cur 个分片，正好每个 worker 一个，用这一轮的耗时算吞吐
+		n := min(ctl.cur, len(frags)-next)
+		metrics.setConcurrency(n)
+
+		start := time.Now()
+		round := runPool(frags[next:next+n], n, upload)
+		elapsed := time.Since(start)
+		copy(results[next:], round)
+		next += n
+
+		var bytes int64
+		failed := 0
+		for i, r := range round {
+			if !r.Done || r.Err != nil {
+				failed++
+				continue
+			}
+			bytes += frags[next-n+i].Size
+		}
+		if failFast && failed > 0 {
+			break // 之后的分片不再派发，collectResults 报出跳过了多少个
+		}
+
+		prev := ctl.cur
+		ctl.observe(float64(bytes)/elapsed.Seconds(), failed, n)
+		if ctl.cur != prev {
+			fmt.Printf("自动并发: %d -> %d\n", prev, ctl.cur)
+		}
+	}
+	return ctl.cur, collectResults(frags, results, roots)
+}
+
+// 用固定 worker 数上传 frags，返回的 results[i] 对应 frags[i]；每个 worker 只写自己拿到的下标
+func runPool(frags []Fragment, workers int, upload uploadFunc) []uploadResult {
+	results := make([]uploadResult, len(frags))
+	jobs := make(chan int)
+	var anyFailed atomic.Bool
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				root, err := upload(frags[i])
+				results[i] = uploadResult{Root: root, Err: err, Done: true}
+				if err != nil {
+					anyFailed.Store(true)
+				}
+			}
+		}()
+	}
+	for i := range frags {
+		if failFast && anyFailed.Load() {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// 成功的分片写进 roots（按分片 Index），失败和未尝试的分片汇总成一个错误
+func collectResults(frags []Fragment, results []uploadResult, roots []string) error {
+	var failed []string
+	var firstErr error
+	var skipped int
+	for i, r := range results {
+		switch {
+		case !r.Done:
+			skipped++
+		case r.Err != nil:
+			failed = append(failed, fmt.Sprintf("%d", frags[i].Index+1))
+			if firstErr == nil {
+				firstErr = r.Err
+			}
+		default:
+			roots[frags[i].Index] = r.Root
+		}
+	}
+	switch {
+	case len(failed) == 0:
+		return nil
+	case len(failed) == 1 && skipped == 0:
+		return firstErr
+	case skipped > 0:
+		return fmt.Errorf("分片 %s 上传失败，--fail-fast 跳过了其余 %d 个分片: %w", strings.Join(failed, ", "), skipped, firstErr)
+	default:
+		return fmt.Errorf("%d 个分片上传失败（分片 %s）: %w", len(failed), strings.Join(failed, ", "), firstErr)
+	}
+}
+
+// autoController 从 1 个 worker 开始，吞吐明显提升就加 1；
+// 吞吐进入平台期就回到最佳值并停止增加。出现失败则减半，之后连续 autoRecoverRounds 轮没有失败，
+// 说明错误已经过去，以当时的吞吐为基准重新向上试探
+type autoController struct {
+	cur      int
+	limit    int
+	best     int
+	bestRate float64
+	settled  bool
+
+	backedOff   bool // 因失败减半后还没有恢复
+	cleanRounds int  // 减半后连续没有失败的轮数
+}
+
+const autoRecoverRounds = 2
+
+func newAutoController(limit int) *autoController {
+	return &autoController{cur: 1, limit: limit, best: 1}
+}
+
+// 每轮结束后调用：rate 为本轮吞吐（bytes/s），failed/total 为本轮失败数和总数
+func (a *autoController) observe(rate float64, failed, total int) {
+	if failed > 0 {
+		a.settled, a.backedOff, a.cleanRounds = true, true, 0
+		a.cur = max(a.cur/2, 1)
+		a.best, a.bestRate = a.cur, 0
+		return
+	}
+
+	// 最后一轮可能不满 cur 个分片，吞吐没有参考意义
+	if total < a.cur {
+		return
+	}
+
+	if a.backedOff {
+		if a.cleanRounds++; a.cleanRounds < autoRecoverRounds {
+			return
+		}
+		a.backedOff, a.settled = false, false
+		a.best, a.bestRate = a.cur, rate
+		if a.cur < a.limit {
+			a.cur++
+		}
+		return
+	}
+	if a.settled {
+		return
+	}
+
+	if rate > a.bestRate*autoGainThreshold {
+		a.best, a.bestRate = a.cur, rate
+		if a.cur < a.limit {
+			a.cur++
+		} else {
+			a.settled = true
+		}
+		return
+	}
+
+	// 平台期：回到吞吐最好的并发数，不再增加
+	a.cur = a.best
+	a.settled = true
+}
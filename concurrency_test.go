@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 模拟吞吐在 3 个 worker 处饱和的传输：每个 worker 100 bytes/s，超过 3 个不再提升
+func saturatingRate(workers int) float64 {
+	return float64(min(workers, 3)) * 100
+}
+
+func TestAutoControllerConvergesOnSaturation(t *testing.T) {
+	ctl := newAutoController(maxAutoConcurrency)
+	var seen []int
+	for round := 0; round < 10; round++ {
+		seen = append(seen, ctl.cur)
+		ctl.observe(saturatingRate(ctl.cur), 0, ctl.cur)
+	}
+	if ctl.cur != 3 || !ctl.settled {
+		t.Fatalf("收敛到 %d（settled=%v），期望停在 3，历史 %v", ctl.cur, ctl.settled, seen)
+	}
+	for _, n := range seen {
+		if n > 4 {
+			t.Fatalf("吞吐饱和后仍在增加并发: %v", seen)
+		}
+	}
+}
+
+func TestAutoControllerBacksOffAndRecovers(t *testing.T) {
+	ctl := newAutoController(maxAutoConcurrency)
+	for ctl.cur < 4 {
+		ctl.observe(float64(ctl.cur)*100, 0, ctl.cur)
+	}
+	ctl.observe(0, 1, ctl.cur) // 出错：减半
+	if ctl.cur != 2 {
+		t.Fatalf("失败后并发 %d，期望减半为 2", ctl.cur)
+	}
+
+	// 错误停止后连续几轮成功，应重新开始增加
+	for i := 0; i < autoRecoverRounds; i++ {
+		ctl.observe(float64(ctl.cur)*100, 0, ctl.cur)
+	}
+	if ctl.cur != 3 {
+		t.Fatalf("错误停止后并发 %d，期望恢复增加到 3", ctl.cur)
+	}
+	for i := 0; i < 5; i++ {
+		ctl.observe(float64(ctl.cur)*100, 0, ctl.cur)
+	}
+	if ctl.cur < 5 {
+		t.Fatalf("吞吐持续提升时并发只到 %d", ctl.cur)
+	}
+}
+
+func TestAutoControllerKeepsBackingOffWhileFailing(t *testing.T) {
+	ctl := newAutoController(maxAutoConcurrency)
+	ctl.cur = 8
+	for i := 0; i < 5; i++ {
+		ctl.observe(100, 1, ctl.cur)
+	}
+	if ctl.cur != 1 {
+		t.Fatalf("持续失败时并发 %d，期望降到 1", ctl.cur)
+	}
+}
+
+// 自动模式走真实的上传循环：每批结束后按耗时算吞吐，最终并发不超过上限，且所有分片都有 root
+func TestUploadFragmentsAuto(t *testing.T) {
+	setupTest(t)
+	concurrencyAuto = true
+	frags := make([]Fragment, 12)
+	for i := range frags {
+		frags[i] = Fragment{Index: i, Size: 100}
+	}
+	roots := make([]string, len(frags))
+	used, err := uploadFragments(frags, roots, func(frag Fragment) (string, error) {
+		return "0x" + string(rune('a'+frag.Index)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used < 1 || used > maxAutoConcurrency {
+		t.Fatalf("最终并发 %d 超出范围", used)
+	}
+	for i, r := range roots {
+		if r == "" {
+			t.Fatalf("分片 %d 没有 root", i)
+		}
+	}
+}
+
+// --concurrency-auto --fail-fast：第一个分片失败后不再派发后面的分片，也不在重试层之外再试一次；
+// 错误说明跳过了多少个分片
+func TestUploadFragmentsAutoFailFast(t *testing.T) {
+	setupTest(t)
+	concurrencyAuto, failFast = true, true
+	frags := make([]Fragment, 8)
+	for i := range frags {
+		frags[i] = Fragment{Index: i, Size: 100}
+	}
+	var mu sync.Mutex
+	var attempts []int
+	roots := make([]string, len(frags))
+	_, err := uploadFragments(frags, roots, func(frag Fragment) (string, error) {
+		mu.Lock()
+		attempts = append(attempts, frag.Index)
+		mu.Unlock()
+		if frag.Index == 0 { // 自动模式第一轮只有这一个分片
+			return "", errors.New("connection reset")
+		}
+		return fmt.Sprintf("0x%02d", frag.Index), nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "--fail-fast 跳过了其余 7 个分片") {
+		t.Fatalf("错误应说明跳过的分片数，得到 %v", err)
+	}
+	if fmt.Sprint(attempts) != "[0]" {
+		t.Fatalf("失败后不应再上传任何分片，也不应重试，实际上传了 %v", attempts)
+	}
+	for i, r := range roots {
+		if r != "" {
+			t.Fatalf("roots[%d] = %q，没有分片上传成功", i, r)
+		}
+	}
+}
+
+// 分别记录上传和下载同时进行的最大数量
+type peakStorage struct {
+	fakeStorage
+	mu             sync.Mutex
+	up, down       int
+	peakUp, peakDn int
+}
+
+func (s *peakStorage) track(n *int, peak *int, d int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*n += d
+	*peak = max(*peak, *n)
+}
+
+func (s *peakStorage) Upload(path string) (string, error) {
+	s.track(&s.up, &s.peakUp, 1)
+	defer s.track(&s.up, &s.peakUp, -1)
+	time.Sleep(30 * time.Millisecond)
+	return s.fakeStorage.Upload(path)
+}
+
+func (s *peakStorage) Download(root string, indexer string) (string, error) {
+	s.track(&s.down, &s.peakDn, 1)
+	defer s.track(&s.down, &s.peakDn, -1)
+	time.Sleep(30 * time.Millisecond)
+	return s.fakeStorage.Download(root, indexer)
+}
+
+func TestPerPhaseConcurrency(t *testing.T) {
+	dir := setupTest(t)
+	s := &peakStorage{fakeStorage: testStore()}
+	storage = s
+	fragmentSize = 1000
+	concurrency, uploadConcurrency, downloadConcurrency = 1, 4, 2
+	sparseRestore = true // 并行下载走 --download-concurrency 的 worker 池
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 8000, 0)
+	uploadTestFile(t, path)
+
+	if s.peakUp != 4 || s.peakDn != 2 {
+		t.Fatalf("上传最多 %d 个、下载最多 %d 个同时进行，期望 4 / 2", s.peakUp, s.peakDn)
+	}
+}
+
+func TestPhaseWorkersFallBackToConcurrency(t *testing.T) {
+	newRootCmd()
+	concurrency = 3
+	if uploadWorkers() != 3 || downloadWorkers() != 3 {
+		t.Fatalf("没有单独指定时应沿用 --concurrency: %d / %d", uploadWorkers(), downloadWorkers())
+	}
+	uploadConcurrency = 5
+	if uploadWorkers() != 5 || downloadWorkers() != 3 {
+		t.Fatalf("--upload-concurrency 只影响上传: %d / %d", uploadWorkers(), downloadWorkers())
+	}
+}
+
+// 并发上传、不 --fail-fast 时成功和失败交错完成：roots 只在成功分片的下标上有值，错误列出全部失败分片。
+// 用 -race 运行时同时检查结果收集没有数据竞争
+func TestUploadFragmentsMixedResultsConcurrent(t *testing.T) {
+	setupTest(t)
+	concurrency = 4
+	frags := make([]Fragment, 16)
+	for i := range frags {
+		frags[i] = Fragment{Index: i, Size: 100}
+	}
+	roots := make([]string, len(frags))
+	_, err := uploadFragments(frags, roots, func(frag Fragment) (string, error) {
+		time.Sleep(time.Duration((frag.Index*7)%5) * time.Millisecond) // 打乱完成顺序
+		if frag.Index%3 == 1 {
+			return "", errors.New("connection reset")
+		}
+		return fmt.Sprintf("0x%02d", frag.Index), nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "5 个分片上传失败（分片 2, 5, 8, 11, 14）") {
+		t.Fatalf("错误应按分片顺序列出全部失败分片，得到 %v", err)
+	}
+	for i, r := range roots {
+		want := fmt.Sprintf("0x%02d", i)
+		if i%3 == 1 {
+			want = ""
+		}
+		if r != want {
+			t.Fatalf("roots[%d] = %q，期望 %q", i, r, want)
+		}
+	}
+}
+
+// --manifest - 时上传部分失败，部分 manifest 不写到标准输出，改写到 <输入>.partial.json，其中成功的分片有 root
+func TestPartialManifestWithStdoutManifest(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	fragmentRetries = 0
+	concurrency = 3
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 6000, 6)
+	filePath, manifestPath = path, stdoutManifest
+	storage = failOnStorage{countingStorage: useCountingStorage(), fail: "fragment_002"}
+	var runErr error
+	out := captureStdout(t, func() { runErr = run() })
+	if runErr == nil {
+		t.Fatal("分片 3 上传失败时 run 应返回错误")
+	}
+	if strings.Contains(out, "\"fragments\"") {
+		t.Fatalf("部分 manifest 不应写到标准输出:\n%s", out)
+	}
+	pm, err := loadManifest(path + ".partial.json")
+	if err != nil {
+		t.Fatalf("没有写出部分 manifest: %v", err)
+	}
+	for _, frag := range pm.Fragments {
+		if (frag.Root == "") != (frag.Index == 2) {
+			t.Fatalf("分片 %d 的 root %q 与上传结果不符", frag.Index+1, frag.Root)
+		}
+	}
+	if _, err := os.Stat(stdoutManifest + ".partial.json"); err == nil {
+		t.Fatal("不应写出名为 -.partial.json 的文件")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var contentAddressed bool // --content-addressed：本地分片按内容 sha256 命名
+
+// 把切好的分片改名为 <sha256>.frag 放进 dir。内容相同的分片（包括多文件模式下不同文件之间）
+// 只保留一份磁盘文件，Fragment.Path 都指向它；顺序仍由 Index 决定
+func contentAddress(frags []Fragment, dir string) error {
+	for i := range frags {
+		// 分片哈希本来就是 sha256 时直接复用，省一次读取
+		sum := frags[i].Hash
+		if fragmentHashAlgo != "sha256" || sum == "" {
+			var err error
+			if sum, err = fileFragmentDigest("sha256", frags[i].Path); err != nil {
+				return err
+			}
+		}
+
+		dst := filepath.Join(dir, sum+".frag")
+		if _, err := os.Stat(dst); err == nil {
+			if err := os.Remove(frags[i].Path); err != nil {
+				return err
+			}
+		} else if err := os.Rename(frags[i].Path, dst); err != nil {
+			return fmt.Errorf("分片 %d 改名失败: %w", frags[i].Index+1, err)
+		}
+		frags[i].Path = dst
+		frags[i].ContentHash = sum
+	}
+	return nil
+}
+
+// 统计去重后实际的磁盘文件数，用于提示
+func distinctFragmentFiles(frags []Fragment) int {
+	seen := map[string]bool{}
+	for _, frag := range frags {
+		seen[frag.Path] = true
+	}
+	return len(seen)
+}
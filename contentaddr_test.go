@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 写一个由若干 1000 bytes 块组成的文件，blocks[i] 是第 i 块用的 seed，seed 相同的块内容相同
+func writeBlockFile(t *testing.T, path string, blocks ...byte) []byte {
+	t.Helper()
+	var data []byte
+	for _, seed := range blocks {
+		block := make([]byte, 1000)
+		for i := range block {
+			block[i] = byte(i*7) ^ seed
+		}
+		data = append(data, block...)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestContentAddressSharesIdenticalFragments(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "data.bin")
+	writeBlockFile(t, path, 1, 2, 1, 2, 3)
+	frags, err := splitFile(path, dir, 1000, "sha256", io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := contentAddress(frags, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := distinctFragmentFiles(frags); n != 3 {
+		t.Fatalf("去重后 %d 个本地文件，期望 3", n)
+	}
+	if frags[0].Path != frags[2].Path || frags[1].Path != frags[3].Path || frags[0].Path == frags[1].Path {
+		t.Fatalf("内容相同的分片应指向同一个文件: %v", []string{frags[0].Path, frags[1].Path, frags[2].Path, frags[3].Path})
+	}
+	for i, frag := range frags {
+		if frag.Index != i {
+			t.Fatalf("位置 %d 上是分片 %d，顺序被打乱", i, frag.Index)
+		}
+		if filepath.Base(frag.Path) != frag.ContentHash+".frag" {
+			t.Fatalf("分片 %d 文件名 %s 不是 <sha256>.frag", i, filepath.Base(frag.Path))
+		}
+		if _, err := os.Stat(frag.Path); err != nil {
+			t.Fatalf("分片 %d 的文件不存在: %v", i, err)
+		}
+	}
+	entries, err := filepath.Glob(filepath.Join(dir, "fragment_*"))
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("按序号命名的分片文件没有清理: %v", entries)
+	}
+}
+
+// manifest 按 Index 记录每个分片的 content_hash，恢复时顺序不变
+func TestContentAddressedManifestPreservesOrder(t *testing.T) {
+	dir := setupTest(t)
+	contentAddressed = true
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	want := writeBlockFile(t, path, 1, 2, 1, 1)
+	m := uploadTestFile(t, path)
+
+	if len(m.Fragments) != 4 {
+		t.Fatalf("manifest 有 %d 个分片，期望 4", len(m.Fragments))
+	}
+	hashes := make([]string, len(m.Fragments))
+	for i, frag := range m.Fragments {
+		if frag.Index != i || frag.ContentHash == "" {
+			t.Fatalf("manifest 位置 %d: index %d, content_hash %q", i, frag.Index, frag.ContentHash)
+		}
+		hashes[i] = frag.ContentHash
+	}
+	if hashes[0] != hashes[2] || hashes[0] != hashes[3] || hashes[0] == hashes[1] {
+		t.Fatalf("content_hash 与分片内容不对应: %v", hashes)
+	}
+	assertFileContent(t, path+".restored", want)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	runTimeout         time.Duration // --timeout：整个运行的总时长上限
+	perFragmentTimeout time.Duration // --per-fragment-timeout：单个分片一次上传/下载的时长上限
+	assumedThroughput  int64         // --assumed-throughput 解析后的 bytes/s，0 表示超时不随分片大小变化
+	throughputSpec     string        // --assumed-throughput 原始值，例如 10M/s
+	fragmentRetries    int           // 单个分片失败（含超时）后的重试次数
+	maxTotalRetries    int64         // --max-total-retries：整个运行所有分片共享的重试预算，0 表示不限制
+	retriesUsed        atomic.Int64
+
+	runCtx    = context.Background() // 带 --timeout 截止时间、Ctrl-C 时取消的整体 context
+	cancelRun = func() {}
+)
+
+const (
+	defaultUploadTimeout   = 30 * time.Minute // 未设置 --per-fragment-timeout 时传给 SDK 的超时
+	defaultDownloadTimeout = 20 * time.Minute
+	defaultTimeoutBase     = time.Minute // --assumed-throughput 而没有 --per-fragment-timeout 时的基础时长
+)
+
+// 单个分片一次传输的时长上限。设置了 --assumed-throughput 时为 基础时长 + size / 吞吐，
+// 基础时长取 --per-fragment-timeout（未设置时 1m），小分片不会因为固定超时太长而迟迟不重试，
+// 大分片也不会还没传完就超时。size 小于 0 表示大小未知，不按大小放宽；返回 0 表示不限制
+func fragmentTimeout(size int64) time.Duration {
+	if assumedThroughput <= 0 || size < 0 {
+		return perFragmentTimeout
+	}
+	base := perFragmentTimeout
+	if base <= 0 {
+		base = defaultTimeoutBase
+	}
+	return base + time.Duration(float64(size)/float64(assumedThroughput)*float64(time.Second))
+}
+
+// 本地文件的大小，读不到时返回 -1（按大小未知处理）
+func fileSizeOrUnknown(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+func parseAssumedThroughput() error {
+	if throughputSpec == "" {
+		return nil
+	}
+	v, err := parseByteSize(strings.TrimSuffix(strings.TrimSuffix(throughputSpec, "/s"), "/S"))
+	if err != nil {
+		return fmt.Errorf("--assumed-throughput: %w", err)
+	}
+	assumedThroughput = v
+	return nil
+}
+
+// 建立整体 context：Ctrl-C / SIGTERM 时取消，设置了 --timeout 时再加上截止时间。
+// 第一次中断后恢复默认信号处理，还卡在不可取消的调用里时再按一次 Ctrl-C 可以强制退出
+func setupRunContext() {
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	cancel := stopSignals
+	if runTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, runTimeout)
+		cancel = func() {
+			cancelTimeout()
+			stopSignals()
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		stopSignals()
+	}()
+	runCtx, cancelRun = ctx, cancel
+}
+
+// 从全局重试预算里取一次；预算用完返回 false
+func takeRetry() bool {
+	if n := retriesUsed.Add(1); maxTotalRetries > 0 && n > maxTotalRetries {
+		retriesUsed.Add(-1)
+		return false
+	}
+	return true
+}
+
+// 运行结束时汇报消耗的重试次数
+func printRetrySummary() {
+	used := retriesUsed.Load()
+	switch {
+	case maxTotalRetries > 0:
+		fmt.Printf("\n共重试 %d 次（预算 %d）\n", used, maxTotalRetries)
+	case used > 0:
+		fmt.Printf("\n共重试 %d 次\n", used)
+	}
+}
+
+// 放弃时说明是被中断还是超过了 --timeout
+func runCtxReason() string {
+	if runCtx.Err() == context.DeadlineExceeded {
+		return fmt.Sprintf("已超过 --timeout %s", runTimeout)
+	}
+	return "运行已被中断"
+}
+
+// 每次 Read 前检查 context，让整文件哈希之类的长时间读取可以及时取消
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+type ctxReaderAt struct {
+	ctx context.Context
+	r   io.ReaderAt
+}
+
+func (r ctxReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.ReadAt(p, off)
+}
+
+// 传给 SDK 命令的 --timeout：和 fragmentTimeout(size) 一致，让 SDK 自己也在同一时间放弃；
+// 按大小缩放却不知道大小时（下载）沿用 SDK 默认值，由 callWithDeadline 负责按时放弃
+func sdkTimeout(def time.Duration, size int64) string {
+	if assumedThroughput > 0 && size < 0 {
+		return def.String()
+	}
+	if t := fragmentTimeout(size); t > 0 {
+		return t.String()
+	}
+	return def.String()
+}
+
+// 对单个分片执行 fn，失败或超过 fragmentTimeout(size) 时重试，最多重试 --retries 次；
+// 整体 --timeout 到期后不再重试。上传超时后要等进行中的调用结束才重试（见 callWithDeadline）；
+// late 不为 nil 时，放弃的调用之后才成功返回的结果交给它清理（例如删除临时文件）。
+// size 是这次传输的字节数，不知道时传 -1。T 是 fn 的结果（root、临时文件路径或下载到内存的数据）
+func withFragmentRetry[T any](phase string, index int, size int64, fn func() (T, error), late func(T)) (T, error) {
+	var zero T
+	timeout := fragmentTimeout(size)
+	var lastErr error
+	for attempt := 0; attempt <= fragmentRetries; attempt++ {
+		if attempt > 0 {
+			if !takeRetry() {
+				metrics.fragmentFailed(phase)
+				return zero, fmt.Errorf("已用完 --max-total-retries %d 次重试预算，放弃%s分片 %d: %w", maxTotalRetries, phase, index+1, lastErr)
+			}
+			logrus.Warnf("%s分片 %d 失败，第 %d 次重试: %v", phase, index+1, attempt, lastErr)
+			metrics.retried(phase)
+		}
+		start := time.Now()
+		res, err := callWithDeadline(fn, late, timeout, phase == "上传")
+		if err == nil {
+			metrics.fragmentDone(phase, time.Since(start))
+			return res, nil
+		}
+		lastErr = err
+		if runCtx.Err() != nil {
+			metrics.fragmentFailed(phase)
+			return zero, fmt.Errorf("%s，放弃%s分片 %d: %w", runCtxReason(), phase, index+1, err)
+		}
+	}
+	metrics.fragmentFailed(phase)
+	return zero, lastErr
+}
+
+// SDK 命令不接受 context，进行中的调用无法打断，只能在 goroutine 里跑并在截止时间到达时先返回。
+// 上传（wait 为 true）不能这样：后台的调用还在提交时重试会再提交一份重复的分片，所以超时后先等这次调用结束
+// （SDK 自身的 --timeout 与截止时间相同，通常随即就会返回），成功就直接用它的结果，失败才交给上层重试。
+// 整体 context 取消（Ctrl-C / --timeout）时不再等待；放弃的调用之后才成功返回的结果交给 late 清理
+func callWithDeadline[T any](fn func() (T, error), late func(T), timeout time.Duration, wait bool) (T, error) {
+	var zero T
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	type result struct {
+		res T
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		res, err := fn()
+		ch <- result{res, err}
+	}()
+	abandon := func() {
+		go func() {
+			if r := <-ch; r.err == nil && late != nil {
+				late(r.res)
+			}
+		}()
+	}
+
+	select {
+	case r := <-ch:
+		return r.res, r.err
+	case <-runCtx.Done():
+		abandon()
+		return zero, fmt.Errorf("分片传输被取消: %w", runCtx.Err())
+	case <-deadline:
+		if !wait {
+			abandon()
+			return zero, fmt.Errorf("分片传输超时（%s）", timeout)
+		}
+	}
+
+	logrus.Warnf("分片传输超过 %s，等进行中的调用结束后再决定是否重试", timeout)
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return zero, fmt.Errorf("分片传输超时（%s）: %w", timeout, r.err)
+		}
+		logrus.Warnf("分片传输超过 %s 后才完成，直接使用这次的结果", timeout)
+		return r.res, nil
+	case <-runCtx.Done():
+		abandon()
+		return zero, fmt.Errorf("分片传输超时: %w", runCtx.Err())
+	}
+}
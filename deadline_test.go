@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 指定分片第一次上传卡住 delay 后才返回（fail 为 true 时像 SDK 自己超时那样返回错误），之后正常
+type stallStorage struct {
+	*countingStorage
+	stall    string
+	delay    time.Duration
+	fail     bool
+	inFlight atomic.Int32 // 同一分片同时进行的上传数
+	maxBoth  atomic.Int32
+	once     sync.Once
+}
+
+func (s *stallStorage) Upload(path string) (string, error) {
+	if !strings.Contains(filepath.Base(path), s.stall) {
+		return s.countingStorage.Upload(path)
+	}
+	if n := s.inFlight.Add(1); n > s.maxBoth.Load() {
+		s.maxBoth.Store(n)
+	}
+	defer s.inFlight.Add(-1)
+	stalled := false
+	s.once.Do(func() { stalled = true })
+	if stalled {
+		time.Sleep(s.delay)
+		if s.fail {
+			return "", fmt.Errorf("upload timeout")
+		}
+	}
+	return s.countingStorage.Upload(path)
+}
+
+// 分片 2 超过 --per-fragment-timeout：等卡住的调用返回错误后重试，其他分片照常上传，同一分片不会同时提交两次
+func TestPerFragmentTimeoutRetries(t *testing.T) {
+	dir := setupTest(t)
+	s := &stallStorage{countingStorage: useCountingStorage(), stall: "fragment_001", delay: 300 * time.Millisecond, fail: true}
+	storage = s
+	fragmentSize, perFragmentTimeout, fragmentRetries, uploadConcurrency = 1000, 100*time.Millisecond, 2, 3
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 3000, 0)
+	m := uploadTestFile(t, path)
+
+	if s.uploads[len(s.uploads)-1] != m.Fragments[1].Root {
+		t.Fatalf("分片 2 卡住时其他分片应先完成，实际完成顺序 %v", s.uploads)
+	}
+	if got := retriesUsed.Load(); got != 1 {
+		t.Fatalf("期望分片 2 重试 1 次，实际重试 %d 次", got)
+	}
+	if s.uploadCount() != 3 {
+		t.Fatalf("期望 3 次成功上传，实际 %d 次", s.uploadCount())
+	}
+	if s.maxBoth.Load() != 1 {
+		t.Fatal("超时后没等卡住的调用结束就重试，同一分片同时上传了两次")
+	}
+	assertFileContent(t, path+".restored", data)
+}
+
+// 超时后卡住的调用最终成功：直接用它的结果，不再重复上传
+func TestPerFragmentTimeoutLateSuccessNotReuploaded(t *testing.T) {
+	dir := setupTest(t)
+	s := &stallStorage{countingStorage: useCountingStorage(), stall: "fragment_001", delay: 300 * time.Millisecond}
+	storage = s
+	fragmentSize, perFragmentTimeout, fragmentRetries = 1000, 100*time.Millisecond, 2
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 3000, 0)
+	uploadTestFile(t, path)
+
+	if retriesUsed.Load() != 0 || s.uploadCount() != 3 {
+		t.Fatalf("超时后才成功的上传不应重试: 重试 %d 次，上传 %d 次", retriesUsed.Load(), s.uploadCount())
+	}
+}
+
+// 下载没有重复提交的问题，超时后直接放弃这次调用去重试
+func TestCallWithDeadlineAbandonsDownload(t *testing.T) {
+	setupTest(t)
+	release := make(chan struct{})
+	cleaned := make(chan string, 1)
+	start := time.Now()
+	_, err := callWithDeadline(func() (string, error) {
+		<-release
+		return "late.tmp", nil
+	}, func(p string) { cleaned <- p }, 50*time.Millisecond, false)
+	if err == nil || time.Since(start) > time.Second {
+		t.Fatalf("下载超时应立即返回错误，实际 %v（%s）", err, time.Since(start))
+	}
+	close(release)
+	if p := <-cleaned; p != "late.tmp" {
+		t.Fatalf("迟到的结果没有交给 late 清理: %q", p)
+	}
+}
+
+// 每次上传都失败：单个分片可以重试 10 次，但全局预算只有 3 次，用完后剩余失败直接终止
+func TestMaxTotalRetriesAbortsRun(t *testing.T) {
+	dir := setupTest(t)
+	var attempts atomic.Int32
+	storage = attemptStorage{fakeStorage: testStore(), attempts: &attempts}
+	fragmentSize = 1000
+	fragmentRetries = 10
+	maxTotalRetries = 3
+	filePath = filepath.Join(dir, "data.bin")
+	writeTestFile(t, filePath, 4000, 0)
+
+	err := run()
+	if err == nil || exitCode(err) != ExitUpload || !strings.Contains(err.Error(), "--max-total-retries 3") {
+		t.Fatalf("预算用完后应以上传错误终止，实际 %v", err)
+	}
+	if n := retriesUsed.Load(); n != 3 {
+		t.Fatalf("消耗了 %d 次重试，期望正好用完预算 3 次", n)
+	}
+	if n := attempts.Load(); n > 4+3 {
+		t.Fatalf("上传尝试了 %d 次，超过 4 个分片各一次加 3 次重试", n)
+	}
+	out := captureStdout(t, printRetrySummary)
+	if !strings.Contains(out, "共重试 3 次（预算 3）") {
+		t.Fatalf("汇总中没有重试次数: %q", out)
+	}
+}
+
+// 上传总是失败，并记录尝试次数
+type attemptStorage struct {
+	fakeStorage
+	attempts *atomic.Int32
+}
+
+func (s attemptStorage) Upload(path string) (string, error) {
+	s.attempts.Add(1)
+	return "", fmt.Errorf("connection refused")
+}
+
+// --assumed-throughput：超时 = 基础时长 + 大小 / 吞吐，随分片大小增长，最小不低于基础时长
+func TestFragmentTimeoutScalesWithSize(t *testing.T) {
+	setupTest(t)
+	perFragmentTimeout = 2 * time.Minute
+	if got := fragmentTimeout(1 << 30); got != 2*time.Minute {
+		t.Fatalf("未设置 --assumed-throughput 时应是固定超时，实际 %s", got)
+	}
+
+	throughputSpec = "10M/s"
+	if err := parseAssumedThroughput(); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		size int64
+		want time.Duration
+	}{
+		{0, 2 * time.Minute},
+		{1 << 10, 2*time.Minute + 97656*time.Nanosecond}, // 1KB / 10MB/s
+		{10 << 20, 2*time.Minute + time.Second},
+		{4 << 30, 2*time.Minute + 409600*time.Millisecond},
+		{-1, 2 * time.Minute}, // 大小未知：不按大小放宽
+	}
+	for _, c := range cases {
+		if got := fragmentTimeout(c.size); got.Round(time.Microsecond) != c.want.Round(time.Microsecond) {
+			t.Fatalf("%d bytes 的超时是 %s，期望 %s", c.size, got, c.want)
+		}
+	}
+	if small, large := fragmentTimeout(1000), fragmentTimeout(1<<30); small < perFragmentTimeout || large <= small {
+		t.Fatalf("超时应随大小增长且不低于基础时长: %s / %s", small, large)
+	}
+
+	perFragmentTimeout = 0 // 没有 --per-fragment-timeout 时基础时长取 1m
+	if got := fragmentTimeout(20 << 20); got != defaultTimeoutBase+2*time.Second {
+		t.Fatalf("默认基础时长下 20MB 的超时是 %s，期望 %s", got, defaultTimeoutBase+2*time.Second)
+	}
+	if got := sdkTimeout(defaultUploadTimeout, 20<<20); got != (defaultTimeoutBase + 2*time.Second).String() {
+		t.Fatalf("传给 SDK 的 --timeout 应与分片超时一致，实际 %s", got)
+	}
+
+	throughputSpec = "fast"
+	if err := parseAssumedThroughput(); err == nil {
+		t.Fatal("无效的 --assumed-throughput 应报错")
+	}
+}
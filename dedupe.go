@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// --dedupe-across-manifests：在本地记录 "上传数据的 sha256 -> root"，之前任何一次运行上传过的
+// 同样内容的分片直接复用 root，不再上传。每个分片上传成功后立即写回，中途中断也不会丢记录。
+// 后端支持查询时，复用前先确认 root 仍可下载
+var (
+	dedupeAcross    bool
+	dedupeStorePath string
+)
+
+type dedupeEntry struct {
+	Root    string `json:"root"`
+	Size    int64  `json:"size"`
+	Indexer string `json:"indexer,omitempty"`
+	Time    string `json:"time"`
+}
+
+type dedupeStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+var dedupe = &dedupeStore{}
+
+func defaultDedupeStorePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "0g-split-upload", "dedupe-store.json")
+}
+
+func (s *dedupeStore) file() string {
+	if dedupeStorePath != "" {
+		return dedupeStorePath
+	}
+	return defaultDedupeStorePath()
+}
+
+// 每次都重新读文件，其他同时运行的进程写入的记录也能用上
+func (s *dedupeStore) load() map[string]dedupeEntry {
+	entries := map[string]dedupeEntry{}
+	data, err := os.ReadFile(s.file())
+	if err != nil {
+		return entries
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		logrus.Warnf("去重记录 %s 损坏，忽略: %v", s.file(), err)
+		return map[string]dedupeEntry{}
+	}
+	return entries
+}
+
+// 上传数据（压缩后的、含 --pad-last 补零）的 sha256；分片哈希本来就是对同样数据算的 sha256 时直接用
+func uploadDataSHA256(frag Fragment) (string, error) {
+	if fragmentHashAlgo == "sha256" && frag.Codec == "" && frag.Hash != "" {
+		return frag.Hash, nil
+	}
+	return fileFragmentDigest("sha256", frag.Path)
+}
+
+// 找到记录且 root 仍可用时返回 root
+func (s *dedupeStore) lookup(frag Fragment) (string, bool) {
+	if !dedupeAcross {
+		return "", false
+	}
+	sum, err := uploadDataSHA256(frag)
+	if err != nil {
+		logrus.Warnf("分片 %d 计算 sha256 失败，不做去重: %v", frag.Index+1, err)
+		return "", false
+	}
+	s.mu.Lock()
+	e, ok := s.load()[sum]
+	s.mu.Unlock()
+	if !ok || e.Root == "" {
+		return "", false
+	}
+	if checker, ok := storage.(availabilityChecker); ok {
+		if live, err := checker.Available(e.Root); err != nil || !live {
+			logrus.Warnf("分片 %d 的去重记录 root %s 已不可下载，重新上传", frag.Index+1, e.Root)
+			return "", false
+		}
+	}
+	return e.Root, true
+}
+
+func (s *dedupeStore) record(frag Fragment, root string) {
+	if !dedupeAcross || root == "" {
+		return
+	}
+	sum, err := uploadDataSHA256(frag)
+	if err != nil {
+		logrus.Warnf("分片 %d 计算 sha256 失败，不写去重记录: %v", frag.Index+1, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := s.load()
+	entries[sum] = dedupeEntry{Root: root, Size: fileSizeOrUnknown(frag.Path), Indexer: indexerURL, Time: time.Now().UTC().Format(time.RFC3339)}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(s.file()), 0755); err == nil {
+			err = writeFileAtomic(s.file(), data, 0644)
+		}
+	}
+	if err != nil {
+		logrus.Warnf("写去重记录失败: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// --dedupe-across-manifests：第二次运行遇到之前上传过的分片内容，直接复用记录里的 root，不再上传
+func TestDedupeAcrossRunsReusesRoot(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	dedupeAcross = true
+	dedupeStorePath = filepath.Join(dir, "dedupe-store.json")
+
+	first := filepath.Join(dir, "first.bin")
+	data := writeTestFile(t, first, 3000, 1)
+	counter := useCountingStorage()
+	m1 := uploadTestFile(t, first)
+	if n := counter.uploadCount(); n != 3 {
+		t.Fatalf("第一次运行应上传 3 个分片，实际 %d 个", n)
+	}
+	if _, err := os.Stat(dedupeStorePath); err != nil {
+		t.Fatalf("上传后应写出去重记录: %v", err)
+	}
+
+	// 第二个文件：前两个分片和第一个文件相同，最后一个不同
+	second := filepath.Join(dir, "second.bin")
+	data2 := append(append([]byte(nil), data[:2000]...), writeTestFile(t, filepath.Join(dir, "tail.bin"), 1000, 9)...)
+	if err := os.WriteFile(second, data2, 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifestPath = ""
+	counter = useCountingStorage()
+	m2 := uploadTestFile(t, second)
+	if n := counter.uploadCount(); n != 1 {
+		t.Fatalf("第二次运行只应上传内容不同的 1 个分片，实际上传 %d 个", n)
+	}
+	for i := 0; i < 2; i++ {
+		if m2.Fragments[i].Root != m1.Fragments[i].Root {
+			t.Fatalf("分片 %d 应复用第一次运行的 root %s，实际 %s", i+1, m1.Fragments[i].Root, m2.Fragments[i].Root)
+		}
+	}
+	if m2.Fragments[2].Root == m1.Fragments[2].Root {
+		t.Fatal("内容不同的分片不应复用 root")
+	}
+	out := filepath.Join(dir, "out.bin")
+	if err := restoreFile(m2, out); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, out, data2)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "重新生成 testdata 下的 golden 文件")
+
+// DeterministicConfig 描述一次可复现的运行
+type DeterministicConfig struct {
+	File         string // 源文件
+	FragmentSize int64
+	FragmentHash string // 默认 sha256
+	StoreDir     string // fakeStorage 存放分片的目录
+	Hooks        Hooks
+}
+
+// RunDeterministic 用 fakeStorage 跑完整的上传 + 恢复流程并返回写出的 manifest。
+// root 由内容决定、indexer 固定为 fake://indexer，manifest 可以直接和提交在仓库里的 golden 文件比较
+func RunDeterministic(cfg DeterministicConfig) (*Manifest, error) {
+	prevStorage, prevIndexer, prevHooks := storage, indexerURL, hooks
+	defer func() { storage, indexerURL, hooks = prevStorage, prevIndexer, prevHooks }()
+	hooks = cfg.Hooks
+
+	storage = fakeStorage{dir: cfg.StoreDir}
+	indexerURL = "fake://indexer"
+	filePath, dirPath, appendTo = cfg.File, "", ""
+	manifestPath = cfg.File + ".manifest.json"
+	fragmentSize = cfg.FragmentSize
+	compressCodec = "none" // 压缩结果随编码库版本变化，golden 只覆盖不压缩的流程
+	fragmentHashAlgo = cfg.FragmentHash
+	if fragmentHashAlgo == "" {
+		fragmentHashAlgo = "sha256"
+	}
+	if maxFragments == 0 {
+		maxFragments = DefaultMaxFragments
+	}
+	if ioBufferSize <= 0 {
+		ioBufferSize = 4 << 20
+	}
+
+	if err := run(); err != nil {
+		return nil, err
+	}
+	return loadManifest(manifestPath)
+}
+
+// 与提交在 testdata 里的 manifest 比较；root 由内容决定、文件名不含目录，只有 file_root 需要归一化。
+// 行为有意改变时用 go test -run TestRunDeterministicGolden -update 重新生成
+func TestRunDeterministicGolden(t *testing.T) {
+	dir := setupTest(t)
+	src := filepath.Join(dir, "golden.bin")
+	writeTestFile(t, src, 10_000, 0x5a)
+
+	m, err := RunDeterministic(DeterministicConfig{File: src, FragmentSize: 4096, StoreDir: testStore().dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// file_root 是 keccak256 Merkle 根，单独校验后归一化，golden 只记录它是否存在
+	if err := verifyFileRoot(m); err != nil || m.FileRoot == "" {
+		t.Fatalf("file_root 校验失败: %q %v", m.FileRoot, err)
+	}
+	m.FileRoot = "<file_root>"
+	got, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	golden := filepath.Join("testdata", "golden.manifest.json")
+	if *updateGolden {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("manifest 与 %s 不一致:\n%s", golden, got)
+	}
+}
+
+// 同样的输入跑两次，manifest 完全相同
+func TestRunDeterministicReproducible(t *testing.T) {
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		dir := setupTest(t)
+		src := filepath.Join(dir, "same.bin")
+		writeTestFile(t, src, 9000, 1)
+		m, err := RunDeterministic(DeterministicConfig{File: src, FragmentSize: 2048, StoreDir: testStore().dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(m)
+		outputs = append(outputs, string(data))
+	}
+	if outputs[0] != outputs[1] {
+		t.Fatalf("两次运行的 manifest 不同:\n%s\n%s", outputs[0], outputs[1])
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 上传一个测试文件，返回 manifest 路径和原始内容；之后的 download 测试在此基础上进行
+func uploadForDownload(t *testing.T, size int, fragSize int64) (string, []byte) {
+	t.Helper()
+	dir := setupTest(t)
+	src := filepath.Join(dir, "src.bin")
+	data := writeTestFile(t, src, size, 3)
+	fragmentSize = fragSize
+	uploadTestFile(t, src)
+	return manifestPath, data
+}
+
+func TestDownloadRangeAcrossFragmentBoundary(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3500, 1000)
+	counter := useCountingStorage()
+
+	dlManifests = []string{manifest}
+	dlOutput = filepath.Join(t.TempDir(), "range.bin")
+	dlRange = "900-2100" // 跨过分片 0/1 和 1/2 的边界
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dlOutput, data[900:2101])
+	if n := counter.downloadCount(); n != 3 {
+		t.Fatalf("下载了 %d 个分片，期望只下载重叠的 3 个", n)
+	}
+}
+
+func TestDownloadRangeInsideOneFragment(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3500, 1000)
+	counter := useCountingStorage()
+
+	dlManifests = []string{manifest}
+	dlOutput = filepath.Join(t.TempDir(), "range.bin")
+	dlRange = "3100-" // end 省略表示到文件末尾
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dlOutput, data[3100:])
+	if n := counter.downloadCount(); n != 1 {
+		t.Fatalf("下载了 %d 个分片，期望 1 个", n)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	for _, tc := range []struct {
+		in         string
+		start, end int64
+		ok         bool
+	}{
+		{"0-99", 0, 99, true},
+		{"10-", 10, 99, true},
+		{"50-40", 0, 0, false},
+		{"0-100", 0, 0, false},
+		{"abc", 0, 0, false},
+	} {
+		start, end, err := parseRange(tc.in, 100)
+		if (err == nil) != tc.ok || (tc.ok && (start != tc.start || end != tc.end)) {
+			t.Errorf("parseRange(%q) = %d, %d, %v", tc.in, start, end, err)
+		}
+	}
+}
+
+// download 给出与 manifest 不一致的 --fragment-size：默认以 manifest 为准照常恢复，--strict 时报错
+func TestDownloadConflictingFragmentSize(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3500, 1000)
+	for _, strict := range []bool{false, true} {
+		c := newDownloadCmd()
+		if err := c.Flags().Set("fragment-size", "1500"); err != nil {
+			t.Fatal(err)
+		}
+		dlManifests = []string{manifest}
+		dlOutput = filepath.Join(t.TempDir(), "out.bin")
+		dlStrict = strict
+		err := c.RunE(c, nil)
+		if !strict {
+			if err != nil {
+				t.Fatalf("非 --strict 时应以 manifest 为准: %v", err)
+			}
+			assertFileContent(t, dlOutput, data)
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "--fragment-size 1500 与 manifest 记录的 1000 不一致（--strict）") {
+			t.Fatalf("--strict 时应报布局不一致，实际 %v", err)
+		}
+	}
+}
+
+// download --output-dir：每个分片单独写成 fragment_NNN.dat，内容与 manifest 记录的分片哈希一致，不合并
+func TestDownloadOutputDir(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3500, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dlManifests = []string{manifest}
+	dlOutputDir = filepath.Join(t.TempDir(), "frags")
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dlOutputDir, "*"))
+	if len(files) != len(m.Fragments) {
+		t.Fatalf("输出目录有 %d 个文件，期望 %d 个: %v", len(files), len(m.Fragments), files)
+	}
+	for _, frag := range m.Fragments {
+		path := filepath.Join(dlOutputDir, fragmentFileName(frag.Index, len(m.Fragments)))
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum, err := fragmentDigest(m.FragmentHashAlgo, got)
+		if err != nil || sum != frag.Hash {
+			t.Fatalf("%s 的 %s %s，manifest 记录 %s", filepath.Base(path), m.FragmentHashAlgo, sum, frag.Hash)
+		}
+		if string(got) != string(data[frag.Offset:frag.Offset+frag.Size]) {
+			t.Fatalf("%s 内容与源文件对应区间不一致", filepath.Base(path))
+		}
+	}
+}
+
+// download --root --expected-hash：不用 manifest，按带外给出的 sha256 核对单个 root
+func TestDownloadRootExpectedHash(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "frag.dat")
+	data := writeTestFile(t, path, 1500, 6)
+	root, err := storage.Upload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, _ := fragmentDigest("sha256", data)
+
+	dlRoot = root
+	dlExpectedHash = "0x" + strings.ToUpper(sum) // 大小写和 0x 前缀都不影响比较
+	dlOutput = filepath.Join(dir, "ok.dat")
+	if err := restoreFromManifest(); err != nil {
+		t.Fatalf("sha256 一致时应成功: %v", err)
+	}
+	assertFileContent(t, dlOutput, data)
+
+	dlExpectedHash = strings.Repeat("0", 64)
+	dlOutput = filepath.Join(dir, "bad.dat")
+	err = restoreFromManifest()
+	if err == nil || exitCode(err) != ExitVerify || !strings.Contains(err.Error(), "sha256 不一致") {
+		t.Fatalf("sha256 不一致时应以校验错误退出，实际 %v", err)
+	}
+	if _, err := os.Stat(dlOutput); !os.IsNotExist(err) {
+		t.Fatal("校验失败时不应写出输出文件")
+	}
+}
+
+// download --resume：输出里分片 0、1 完好、分片 2 被改坏，只从分片 2 的边界开始重新下载
+func TestDownloadResumeRedownloadsFromCorruptFragment(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3500, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dlManifests = []string{manifest}
+	dlOutput = filepath.Join(t.TempDir(), "out.bin")
+	partial := append([]byte(nil), data[:3000]...)
+	partial[2500] ^= 0xff
+	if err := os.WriteFile(dlOutput, partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+	counter := useCountingStorage()
+	dlResume = true
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dlOutput, data)
+	want := []string{m.Fragments[2].Root, m.Fragments[3].Root}
+	if strings.Join(counter.downloads, ",") != strings.Join(want, ",") {
+		t.Fatalf("下载了 %v，期望只下载分片 3、4: %v", counter.downloads, want)
+	}
+
+	// 输出已经完整时不再下载任何分片
+	counter.downloads = nil
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	if n := counter.downloadCount(); n != 0 {
+		t.Fatalf("输出已完整时仍下载了 %d 个分片", n)
+	}
+}
+
+// --resume-quick：输出文件被截断在分片边界（或边界后多出半个分片），只凭 manifest 和输出长度从下一个分片继续
+func TestDownloadResumeQuickFromFragmentBoundary(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3500, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dlManifests = []string{manifest}
+	dlResumeQuick = true
+	for _, written := range []int{2000, 2300} {
+		dlOutput = filepath.Join(t.TempDir(), "out.bin")
+		if err := os.WriteFile(dlOutput, data[:written], 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(dlOutput + ".0gresume"); err == nil {
+			t.Fatal("不应依赖额外的进度文件")
+		}
+		counter := useCountingStorage()
+		if err := restoreFromManifest(); err != nil {
+			t.Fatal(err)
+		}
+		assertFileContent(t, dlOutput, data)
+		want := []string{m.Fragments[2].Root, m.Fragments[3].Root}
+		if strings.Join(counter.downloads, ",") != strings.Join(want, ",") {
+			t.Fatalf("输出长度 %d：下载了 %v，期望从分片 3 继续: %v", written, counter.downloads, want)
+		}
+	}
+}
+
+// --strict-order：恢复出的文件里分片 1、2 被互换位置，逐分片位置校验应报告这两个分片
+func TestStrictOrderDetectsMisplacedFragment(t *testing.T) {
+	manifest, data := uploadForDownload(t, 4000, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dlManifests = []string{manifest}
+	dlOutput = filepath.Join(t.TempDir(), "out.bin")
+	strictOrder = true
+	if err := restoreFromManifest(); err != nil {
+		t.Fatalf("正确恢复时位置校验不应失败: %v", err)
+	}
+
+	swapped := append([]byte(nil), data...)
+	copy(swapped[1000:2000], data[2000:3000])
+	copy(swapped[2000:3000], data[1000:2000])
+	os.WriteFile(dlOutput, swapped, 0644)
+	out := captureStdout(t, func() { err = checkFragmentPlacement(m, dlOutput) })
+	if err == nil || exitCode(err) != ExitVerify || !strings.Contains(err.Error(), "2/4 个分片") {
+		t.Fatalf("期望 2 个分片位置校验失败，实际 %v", err)
+	}
+	if !strings.Contains(out, "分片 02 位置校验失败") || !strings.Contains(out, "分片 03 位置校验失败") {
+		t.Fatalf("应报告分片 02、03:\n%s", out)
+	}
+}
+
+// manifest 里分片 3 被错写成分片 2 的 root：下载前就报 manifest 损坏，不下载任何分片
+func TestDownloadRejectsDuplicateRoots(t *testing.T) {
+	manifest, _ := uploadForDownload(t, 3500, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Fragments[2].Root = m.Fragments[1].Root
+	setFileRoot(m)
+	if err := saveManifest(manifest, m); err != nil {
+		t.Fatal(err)
+	}
+	counter := useCountingStorage()
+	dlManifests = []string{manifest}
+	dlOutput = filepath.Join(t.TempDir(), "out.bin")
+	err = restoreFromManifest()
+	if err == nil || !strings.Contains(err.Error(), "manifest 已损坏") {
+		t.Fatalf("重复 root 的 manifest 应在下载前报错，实际 %v", err)
+	}
+	if n := counter.downloadCount(); n != 0 {
+		t.Fatalf("报错前已下载了 %d 个分片", n)
+	}
+}
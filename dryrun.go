@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strings"
+
+	"github.com/0gfoundation/0g-storage-client/contract"
+	"github.com/0gfoundation/0g-storage-client/core"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/sirupsen/logrus"
+)
+
+var dryRun bool // --dry-run：只切分并列出将要执行的上传，不调用 SDK
+
+// dry-run 时为一个分片构造的 submission 交易：只打印，不签名也不广播
+type submissionTx struct {
+	From  string
+	To    string   // Flow 合约
+	Data  []byte   // Flow.submit(submission) 的 calldata
+	Value *big.Int // 存储费，随交易转给 Flow 合约
+	Gas   uint64   // eth_estimateGas 的结果
+}
+
+// 构造交易需要的链上信息，每次 dry-run 只查一次
+type txContext struct {
+	from  common.Address
+	flow  string
+	price *big.Int // Market 合约的 pricePerSector
+}
+
+func newTxContext() (*txContext, error) {
+	prv, err := crypto.HexToECDSA(strings.TrimPrefix(privateKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("私钥无效: %w", err)
+	}
+	flow, err := flowAddress()
+	if err != nil {
+		return nil, err
+	}
+	price, err := marketPrice(flow)
+	if err != nil {
+		return nil, err
+	}
+	return &txContext{from: crypto.PubkeyToAddress(prv.PublicKey), flow: flow, price: price}, nil
+}
+
+// 和 SDK upload 命令一样用 core.NewFlow 生成 submission 并按 Flow 合约 ABI 编码，gas 用 eth_estimateGas 估算
+func buildSubmissionTx(tc *txContext, frag Fragment) (*submissionTx, error) {
+	data, err := core.Open(frag.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+	sub, err := core.NewFlow(data, []byte(submissionTag())).CreateSubmission(tc.from)
+	if err != nil {
+		return nil, fmt.Errorf("生成 submission 失败: %w", err)
+	}
+	flowABI, err := contract.FlowMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	calldata, err := flowABI.Pack("submit", *sub)
+	if err != nil {
+		return nil, fmt.Errorf("编码 submit 调用失败: %w", err)
+	}
+
+	tx := &submissionTx{From: tc.from.Hex(), To: tc.flow, Data: calldata, Value: sub.Fee(tc.price)}
+	call := map[string]string{
+		"from":  tx.From,
+		"to":    tx.To,
+		"data":  "0x" + hex.EncodeToString(calldata),
+		"value": "0x" + tx.Value.Text(16),
+	}
+	var raw string
+	if err := rpcCall(rpcURL, "eth_estimateGas", []interface{}{call}, &raw); err != nil {
+		return nil, fmt.Errorf("估算 gas 失败: %w", err)
+	}
+	gas, err := parseQuantity(raw)
+	if err != nil {
+		return nil, err
+	}
+	tx.Gas = gas.Uint64()
+	return tx, nil
+}
+
+// 列出每个分片将要交给 SDK 的上传参数，以及那次上传会发出的 submission 交易。
+// 交易只构造不签名，更不广播；查不到链上信息（例如离线）时只列参数
+func printDryRun(frags []Fragment) {
+	fmt.Printf("\n=== dry-run：以下 %d 次上传不会执行，也不会发送任何交易 ===\n", len(frags))
+	tc, err := newTxContext()
+	if err != nil {
+		logrus.Warnf("无法构造 submission 交易，只列出 SDK 参数: %v", err)
+	}
+	var total int64
+	txs := 0
+	for _, frag := range frags {
+		fmt.Printf("\n分片 %02d: %s，offset %d，%d bytes（存储 %d bytes）\n",
+			frag.Index+1, filepath.Base(frag.Path), frag.Offset, frag.Size, frag.Size+frag.Padding)
+		if frag.Zero {
+			fmt.Println("  全零分片，不上传")
+			continue
+		}
+		fmt.Print(formatSDKArgs(sdkUploadArgs(frag.Path)))
+		total += frag.Size + frag.Padding
+		txs++
+		if tc == nil {
+			continue
+		}
+		tx, err := buildSubmissionTx(tc, frag)
+		if err != nil {
+			logrus.Warnf("分片 %d 构造 submission 交易失败: %v", frag.Index+1, err)
+			continue
+		}
+		fmt.Printf("  交易 from %s\n  交易 to   %s\n  交易 value %s\n  交易 gas  %d\n  交易 data 0x%s\n",
+			tx.From, tx.To, formatWei(tx.Value), tx.Gas, hex.EncodeToString(tx.Data))
+	}
+	fmt.Printf("\n共 %d 笔 submission 交易（每个上传的分片一笔），存储 %d bytes\n", txs, total)
+}
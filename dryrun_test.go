@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	fakeFlow   = "0x1111111111111111111111111111111111111111"
+	fakeMarket = "0x2222222222222222222222222222222222222222"
+	fakePrice  = 1000 // 每个扇区的单价（wei）
+	fakeGas    = 0x30d40
+	fakeGasPx  = 7 // eth_gasPrice（wei）
+)
+
+// 假的 RPC / indexer / 存储节点（同一个地址），记录收到的每个方法；任何发送交易的调用都直接报错
+type fakeChain struct {
+	*httptest.Server
+	mu      sync.Mutex
+	methods []string
+	calls   []map[string]string // eth_estimateGas 收到的交易
+	balance *big.Int            // eth_getBalance 返回的余额
+	nonce   uint64              // eth_getTransactionCount 返回的值，每次查询后加一（模拟每次提交都发出一笔交易）
+}
+
+func newFakeChain(t *testing.T) *fakeChain {
+	t.Helper()
+	c := &fakeChain{balance: new(big.Int)}
+	selector := func(method string) string { return "0x" + hex.EncodeToString(crypto.Keccak256([]byte(method))[:4]) }
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		c.methods = append(c.methods, req.Method)
+		c.mu.Unlock()
+
+		var call map[string]string
+		if len(req.Params) > 0 {
+			json.Unmarshal(req.Params[0], &call)
+		}
+		var result interface{}
+		switch {
+		case req.Method == "indexer_getShardedNodes":
+			result = map[string]interface{}{"trusted": []map[string]string{{"url": c.URL}}}
+		case req.Method == "zgs_getStatus":
+			result = map[string]interface{}{"networkIdentity": map[string]string{"flowAddress": fakeFlow}}
+		case req.Method == "eth_call" && call["data"] == selector("market()"):
+			result = "0x" + strings.Repeat("0", 24) + fakeMarket[2:]
+		case req.Method == "eth_call" && call["data"] == selector("pricePerSector()"):
+			result = fmt.Sprintf("0x%064x", fakePrice)
+		case req.Method == "eth_gasPrice":
+			result = fmt.Sprintf("0x%x", fakeGasPx)
+		case req.Method == "eth_getBalance":
+			c.mu.Lock()
+			result = "0x" + c.balance.Text(16)
+			c.mu.Unlock()
+		case req.Method == "eth_getTransactionCount":
+			c.mu.Lock()
+			result = fmt.Sprintf("0x%x", c.nonce)
+			c.nonce++
+			c.mu.Unlock()
+		case req.Method == "eth_estimateGas":
+			c.mu.Lock()
+			c.calls = append(c.calls, call)
+			c.mu.Unlock()
+			result = fmt.Sprintf("0x%x", fakeGas)
+		default:
+			http.Error(w, "unexpected method "+req.Method, http.StatusBadRequest)
+			return
+		}
+		data, _ := json.Marshal(result)
+		json.NewEncoder(w).Encode(rpcResponse{Result: data})
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *fakeChain) setBalance(wei int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.balance = big.NewInt(wei)
+}
+
+func (c *fakeChain) sent() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var sent []string
+	for _, m := range c.methods {
+		if strings.HasPrefix(m, "eth_send") {
+			sent = append(sent, m)
+		}
+	}
+	return sent
+}
+
+// 用测试私钥（假签名者）构造 submission 交易：字段来自 Flow 合约、Market 单价和 eth_estimateGas
+func TestBuildSubmissionTx(t *testing.T) {
+	dir := setupTest(t)
+	chain := newFakeChain(t)
+	rpcURL, indexerURL = chain.URL, chain.URL
+	privateKey = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	path := filepath.Join(dir, "frag.dat")
+	writeTestFile(t, path, 1000, 0)
+
+	tc, err := newTxContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := buildSubmissionTx(tc, Fragment{Path: path, Size: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prv, _ := crypto.HexToECDSA(privateKey[2:])
+	if want := crypto.PubkeyToAddress(prv.PublicKey).Hex(); tx.From != want {
+		t.Fatalf("from %s，期望签名者地址 %s", tx.From, want)
+	}
+	if tx.To != fakeFlow {
+		t.Fatalf("to %s，期望 Flow 合约 %s", tx.To, fakeFlow)
+	}
+	// 1000 bytes 占 4 个 256 字节扇区
+	if tx.Value.Cmp(big.NewInt(4*fakePrice)) != 0 {
+		t.Fatalf("value %s，期望 4 个扇区的存储费 %d", tx.Value, 4*fakePrice)
+	}
+	if tx.Gas != fakeGas {
+		t.Fatalf("gas %d，期望 eth_estimateGas 返回的 %d", tx.Gas, fakeGas)
+	}
+	if len(tx.Data) <= 4 || len(chain.calls) != 1 || chain.calls[0]["data"] != "0x"+hex.EncodeToString(tx.Data) {
+		t.Fatalf("calldata 没有交给 eth_estimateGas 估算: %v", chain.calls)
+	}
+	if sent := chain.sent(); len(sent) > 0 {
+		t.Fatalf("构造交易时不应发送: %v", sent)
+	}
+}
+
+// --dry-run 打印每个分片的交易，但既不调用 SDK 上传，也不向节点发送任何交易
+func TestDryRunPrintsTxsWithoutBroadcast(t *testing.T) {
+	dir := setupTest(t)
+	chain := newFakeChain(t)
+	rpcURL, indexerURL = chain.URL, chain.URL
+	privateKey = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	s := useCountingStorage()
+	dryRun = true
+	fragmentSize = 1000
+	filePath = filepath.Join(dir, "data.bin")
+	writeTestFile(t, filePath, 2500, 0)
+
+	var err error
+	out := captureStdout(t, func() { err = run() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out, "交易 to   "+fakeFlow); n != 3 {
+		t.Fatalf("期望打印 3 笔发往 Flow 合约的交易，实际 %d 笔:\n%s", n, out)
+	}
+	for _, want := range []string{fmt.Sprintf("交易 gas  %d", fakeGas), "共 3 笔 submission 交易"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("输出中缺少 %q:\n%s", want, out)
+		}
+	}
+	if s.uploadCount() != 0 {
+		t.Fatalf("dry-run 不应上传，实际上传 %d 个分片", s.uploadCount())
+	}
+	if sent := chain.sent(); len(sent) > 0 {
+		t.Fatalf("dry-run 不应发送交易: %v", sent)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// --dump-bad-fragments：下载的分片哈希校验失败时，重试前把收到的数据原样存到该目录，
+// 旁边的 .json 记录期望和实际哈希，用来排查存储节点的数据损坏。每次失败单独一个文件，重试不会覆盖
+var dumpBadDir string
+
+type badFragmentInfo struct {
+	Index    int    `json:"index"` // 从 0 开始，同 manifest
+	Root     string `json:"root"`
+	Algo     string `json:"algo"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Size     int64  `json:"size"`
+	Time     string `json:"time"`
+}
+
+// 转储失败只告警，不影响原来的校验错误和重试
+func dumpBadFragment(frag ManifestFragment, algo, actual string, src io.Reader) {
+	if dumpBadDir == "" {
+		return
+	}
+	path, err := writeBadFragment(frag, algo, actual, src)
+	if err != nil {
+		logrus.Warnf("转储校验失败的分片 %d 失败: %v", frag.Index+1, err)
+		return
+	}
+	fmt.Printf("分片 %d 校验失败的数据已保存到 %s\n", frag.Index+1, path)
+}
+
+func writeBadFragment(frag ManifestFragment, algo, actual string, src io.Reader) (string, error) {
+	if err := os.MkdirAll(dumpBadDir, 0755); err != nil {
+		return "", err
+	}
+	out, err := os.CreateTemp(dumpBadDir, fmt.Sprintf("fragment-%02d-*.bad", frag.Index+1))
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(out, src)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+
+	info, err := json.MarshalIndent(badFragmentInfo{
+		Index:    frag.Index,
+		Root:     frag.Root,
+		Algo:     algo,
+		Expected: frag.Hash,
+		Actual:   actual,
+		Size:     n,
+		Time:     time.Now().UTC().Format(time.RFC3339),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(out.Name()+".json", info, 0644); err != nil {
+		return "", err
+	}
+	return out.Name(), nil
+}
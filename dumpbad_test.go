@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 存储里的分片 2 被损坏：每次校验失败（含重试）都转储一份收到的数据，旁边的 .json 记录期望和实际哈希
+func TestDumpBadFragments(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	compressCodec = "none" // 压缩时损坏的数据在解压阶段就失败，到不了哈希校验
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 3000, 2)
+	m := uploadTestFile(t, path)
+	bad := []byte(strings.Repeat("x", int(m.Fragments[1].uploadedSize())))
+	os.WriteFile(filepath.Join(testStore().dir, m.Fragments[1].Root), bad, 0644)
+
+	fragmentRetries = 1
+	dumpBadDir = filepath.Join(t.TempDir(), "bad")
+	dlManifests = []string{manifestPath}
+	dlOutput = filepath.Join(dir, "out.bin")
+	if err := restoreFromManifest(); err == nil {
+		t.Fatal("分片损坏时下载应失败")
+	}
+
+	dumps, _ := filepath.Glob(filepath.Join(dumpBadDir, "fragment-02-*.bad"))
+	if len(dumps) != 2 {
+		t.Fatalf("第一次下载和 1 次重试应各转储一份，实际 %v", dumps)
+	}
+	for _, dump := range dumps {
+		var info badFragmentInfo
+		data, err := os.ReadFile(dump + ".json")
+		if err != nil || json.Unmarshal(data, &info) != nil {
+			t.Fatalf("%s 缺少有效的 .json: %v", dump, err)
+		}
+		if info.Index != 1 || info.Root != m.Fragments[1].Root || info.Expected != m.Fragments[1].Hash || info.Actual == info.Expected {
+			t.Fatalf("转储信息不对: %+v", info)
+		}
+		got, _ := os.ReadFile(dump)
+		if sum, _ := fragmentDigest(info.Algo, got); sum != info.Actual {
+			t.Fatalf("转储数据的 %s %s 与记录的实际哈希 %s 不一致", info.Algo, sum, info.Actual)
+		}
+	}
+}
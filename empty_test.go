@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 空文件：manifest 没有分片，download 子命令能从它恢复出空文件
+func TestEmptyFileRoundTrip(t *testing.T) {
+	for _, erasure := range []string{"", "2:3"} {
+		t.Run("erasure="+erasure, func(t *testing.T) {
+			dir := setupTest(t)
+			erasureSpec = erasure
+			path := filepath.Join(dir, "empty.bin")
+			writeTestFile(t, path, 0, 0)
+			m := uploadTestFile(t, path)
+			if m.FileSize != 0 || len(m.Fragments) != 0 || m.Erasure != nil {
+				t.Fatalf("空文件的 manifest 不对: size %d，%d 个分片，erasure %+v", m.FileSize, len(m.Fragments), m.Erasure)
+			}
+			if m.OriginHash != md5Hex(nil) {
+				t.Fatalf("空文件 MD5 %s", m.OriginHash)
+			}
+
+			mpath := manifestPath
+			newRootCmd()
+			storage = fakeStorage{dir: filepath.Join(dir, "store")}
+			dlManifests = []string{mpath}
+			dlOutput = filepath.Join(dir, "out.bin")
+			os.WriteFile(dlOutput, []byte("stale"), 0644)
+			if err := runDownload(); err != nil {
+				t.Fatal(err)
+			}
+			assertFileContent(t, dlOutput, nil)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var envName string // --env：预置的网络环境
+
+type endpoints struct {
+	RPC     string
+	Indexer string
+}
+
+// 公开网络的默认地址；私有部署用 --env custom 并显式给出 --rpc / --indexer
+var knownEnvs = map[string]endpoints{
+	"mainnet": {RPC: "https://evmrpc.0g.ai", Indexer: "https://indexer-storage-turbo.0g.ai"},
+	"testnet": {RPC: "https://evmrpc-testnet.0g.ai", Indexer: "https://indexer-storage-testnet-turbo.0g.ai"},
+}
+
+// 不指定 --env 时 --rpc / --indexer 的默认值也取自这个预置环境，
+// 保证默认的 RPC 和 indexer 属于同一个网络，并且和 --env mainnet 完全一致
+const defaultEnv = "mainnet"
+
+// 按 --env 填充 RPC / indexer，命令行显式给出的 --rpc / --indexer 优先。
+// 两者是根命令的持久参数，从根命令的 PersistentFlags() 判断是否给出，子命令里调用也一样
+func applyEnv(c *cobra.Command) error {
+	if envName == "" {
+		return nil
+	}
+	flags := c.Root().PersistentFlags()
+	rpcSet, indexerSet := flags.Changed("rpc"), flags.Changed("indexer")
+
+	if envName == "custom" {
+		if !rpcSet || !indexerSet {
+			return fmt.Errorf("--env custom 需要同时指定 --rpc 和 --indexer")
+		}
+		return nil
+	}
+
+	ep, ok := knownEnvs[envName]
+	if !ok {
+		names := make([]string, 0, len(knownEnvs))
+		for name := range knownEnvs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("未知的 --env: %q（可选 %s 或 custom）", envName, strings.Join(names, " / "))
+	}
+	if !rpcSet {
+		rpcURL = ep.RPC
+	}
+	if !indexerSet {
+		indexerURL = ep.Indexer
+	}
+	return nil
+}
+
+// 检查 --rpc / --indexer 是合法的 http(s) 地址并去掉末尾的 /，
+// 否则 SDK 会报出很难看懂的错误
+func normalizeEndpoints() error {
+	var err error
+	if rpcURL, err = normalizeEndpoint("--rpc", rpcURL); err != nil {
+		return err
+	}
+	indexerURL, err = normalizeEndpoint("--indexer", indexerURL)
+	return err
+}
+
+func normalizeEndpoint(flag string, raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%s 不能为空", flag)
+	}
+	if !strings.Contains(raw, "://") {
+		return "", fmt.Errorf("%s 缺少协议: %q，应写成 https://%s", flag, raw, raw)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("%s 不是合法的 URL: %w", flag, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%s 只支持 http / https，实际为 %q", flag, u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%s 缺少主机名: %q", flag, raw)
+	}
+	return strings.TrimRight(raw, "/"), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvPresets(t *testing.T) {
+	root := newRootCmd()
+	if rpcURL != knownEnvs["mainnet"].RPC || indexerURL != knownEnvs["mainnet"].Indexer {
+		t.Fatalf("默认地址 %s / %s 应和 --env mainnet 一致", rpcURL, indexerURL)
+	}
+
+	envName = "testnet"
+	if err := applyEnv(root); err != nil {
+		t.Fatal(err)
+	}
+	if rpcURL != "https://evmrpc-testnet.0g.ai" || indexerURL != "https://indexer-storage-testnet-turbo.0g.ai" {
+		t.Fatalf("--env testnet 填充的地址不对: %s / %s", rpcURL, indexerURL)
+	}
+
+	// 显式给出的 --indexer 优先，--rpc 仍取预置值
+	root = newRootCmd()
+	envName = "testnet"
+	if err := root.PersistentFlags().Set("indexer", "https://my-indexer.example"); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(root); err != nil {
+		t.Fatal(err)
+	}
+	if rpcURL != "https://evmrpc-testnet.0g.ai" || indexerURL != "https://my-indexer.example" {
+		t.Fatalf("显式 --indexer 应覆盖预置值: %s / %s", rpcURL, indexerURL)
+	}
+}
+
+func TestEnvCustomAndUnknown(t *testing.T) {
+	root := newRootCmd()
+	envName = "custom"
+	if err := root.PersistentFlags().Set("rpc", "https://rpc.internal"); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(root); err == nil || !strings.Contains(err.Error(), "同时指定") {
+		t.Fatalf("--env custom 只给 --rpc 应报错，实际 %v", err)
+	}
+	if err := root.PersistentFlags().Set("indexer", "https://indexer.internal"); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(root); err != nil || rpcURL != "https://rpc.internal" || indexerURL != "https://indexer.internal" {
+		t.Fatalf("--env custom: %v，%s / %s", err, rpcURL, indexerURL)
+	}
+
+	root = newRootCmd()
+	envName = "devnet"
+	if err := applyEnv(root); err == nil || !strings.Contains(err.Error(), "mainnet / testnet") {
+		t.Fatalf("未知环境应列出可选值，实际 %v", err)
+	}
+}
+
+func TestNormalizeEndpoint(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"https://evmrpc.0g.ai", "https://evmrpc.0g.ai"},
+		{"http://127.0.0.1:8545", "http://127.0.0.1:8545"},
+		{"https://indexer.example/", "https://indexer.example"},
+		{"  https://indexer.example/api//  ", "https://indexer.example/api"},
+	} {
+		got, err := normalizeEndpoint("--rpc", tc.in)
+		if err != nil || got != tc.want {
+			t.Fatalf("normalizeEndpoint(%q) = %q, %v，期望 %q", tc.in, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct{ in, want string }{
+		{"evmrpc.0g.ai", "缺少协议"},
+		{"ftp://indexer.example", "只支持 http / https"},
+		{"https://", "缺少主机名"},
+		{"", "不能为空"},
+	} {
+		_, err := normalizeEndpoint("--indexer", tc.in)
+		if err == nil || !strings.Contains(err.Error(), "--indexer") || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("normalizeEndpoint(%q) 应报出 --indexer %s，实际 %v", tc.in, tc.want, err)
+		}
+	}
+}
+
+func TestNormalizeEndpointsNamesWrongFlag(t *testing.T) {
+	newRootCmd()
+	rpcURL, indexerURL = "https://evmrpc.0g.ai/", "indexer.example"
+	err := normalizeEndpoints()
+	if err == nil || !strings.HasPrefix(err.Error(), "--indexer") {
+		t.Fatalf("应指出 --indexer 有误，实际 %v", err)
+	}
+	if rpcURL != "https://evmrpc.0g.ai" {
+		t.Fatalf("--rpc 末尾的 / 没有去掉: %q", rpcURL)
+	}
+}
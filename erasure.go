@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/sirupsen/logrus"
+)
+
+var erasureSpec string // --erasure k:m，m 个分片里任意 k 个即可恢复
+
+// ErasureInfo 记录 Reed-Solomon 编码参数，download 据此重建
+type ErasureInfo struct {
+	DataShards   int   `json:"data_shards"`
+	ParityShards int   `json:"parity_shards"`
+	ShardSize    int64 `json:"shard_size"`
+}
+
+// 解析 k:m，返回数据分片数 k 和总分片数 m
+func parseErasure(spec string) (int, int, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("--erasure 格式错误: %q，应为 k:m", spec)
+	}
+	k, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("--erasure 格式错误: %q，应为 k:m", spec)
+	}
+	if k < 1 || m <= k || m > 256 {
+		return 0, 0, fmt.Errorf("--erasure 参数无效: 需要 1 <= k < m <= 256，实际 %d:%d", k, m)
+	}
+	return k, m, nil
+}
+
+// 把文件编码成 m 个等大的分片：前 k 个是数据（最后一个补零），后 m-k 个是校验。
+// 读到的原始数据同时写入 origin
+func splitErasure(src string, dstDir string, k, m int, hashAlgo string, origin io.Writer) ([]Fragment, *ErasureInfo, error) {
+	enc, err := reedsolomon.NewStream(k, m-k)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	shardSize := (info.Size() + int64(k) - 1) / int64(k)
+
+	paths := make([]string, m)
+	for i := range paths {
+		paths[i] = filepath.Join(dstDir, fragmentFileName(i, m))
+	}
+
+	// 1. 数据分片
+	dataFiles, err := createFiles(paths[:k])
+	if err != nil {
+		return nil, nil, err
+	}
+	err = enc.Split(io.TeeReader(ctxReader{runCtx, f}, origin), asWriters(dataFiles), info.Size())
+	closeFiles(dataFiles)
+	if err != nil {
+		return nil, nil, fmt.Errorf("切分数据分片失败: %w", err)
+	}
+
+	// 2. 校验分片
+	dataIn, err := openFiles(paths[:k])
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeFiles(dataIn)
+	parityFiles, err := createFiles(paths[k:])
+	if err != nil {
+		return nil, nil, err
+	}
+	err = enc.Encode(asReaders(dataIn), asWriters(parityFiles))
+	closeFiles(parityFiles)
+	if err != nil {
+		return nil, nil, fmt.Errorf("计算校验分片失败: %w", err)
+	}
+
+	frags := make([]Fragment, m)
+	for i := range frags {
+		sum, err := fileFragmentDigest(hashAlgo, paths[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		frags[i] = Fragment{Index: i, Path: paths[i], Size: shardSize, Parity: i >= k, Hash: sum}
+		if i < k {
+			frags[i].Offset = int64(i) * shardSize
+		}
+	}
+	return frags, &ErasureInfo{DataShards: k, ParityShards: m - k, ShardSize: shardSize}, nil
+}
+
+// 下载任意 k 个可用分片，缺失的数据分片用校验分片重建，再拼回原始文件
+func downloadErasure(m *Manifest, outputPath string) error {
+	e := m.Erasure
+	enc, err := reedsolomon.NewStream(e.DataShards, e.ParityShards)
+	if err != nil {
+		return err
+	}
+
+	total := e.DataShards + e.ParityShards
+	paths := make([]string, total)
+	got := 0
+	for _, frag := range m.Fragments {
+		if got == e.DataShards {
+			break
+		}
+		fmt.Printf("[%d/%d] 正在下载 root: %s\n", frag.Index+1, total, frag.Root)
+		tmpPath, err := downloadFragment(m, frag)
+		if err != nil {
+			logrus.Warnf("分片 %d 下载失败，尝试其他分片: %v", frag.Index+1, err)
+			continue
+		}
+		defer os.Remove(tmpPath)
+		paths[frag.Index] = tmpPath
+		got++
+	}
+	if got < e.DataShards {
+		return fmt.Errorf("可用分片不足: 需要 %d 个，只下载到 %d 个", e.DataShards, got)
+	}
+	return joinErasure(m, enc, paths, outputPath)
+}
+
+// paths 按分片序号排列，至少 k 个非空；缺失的数据分片用校验分片重建后拼回原始文件
+func joinErasure(m *Manifest, enc reedsolomon.StreamEncoder, paths []string, outputPath string) error {
+	e := m.Erasure
+	// 补齐缺失的数据分片（校验分片缺了不影响拼接，不用重建）
+	var missing []int
+	for i := 0; i < e.DataShards; i++ {
+		if paths[i] == "" {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Printf("有 %d 个数据分片缺失，使用校验分片重建\n", len(missing))
+		err := reconstructShards(enc, paths, missing)
+		for _, i := range missing {
+			if paths[i] != "" {
+				defer os.Remove(paths[i])
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("重建分片失败: %w", err)
+		}
+	}
+
+	dataIn, err := openFiles(paths[:e.DataShards])
+	if err != nil {
+		return err
+	}
+	defer closeFiles(dataIn)
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return enc.Join(out, asReaders(dataIn), m.FileSize)
+}
+
+// 重建 missing 中的分片，写到新的临时文件并填回 paths
+func reconstructShards(enc reedsolomon.StreamEncoder, paths []string, missing []int) error {
+	valid := make([]io.Reader, len(paths))
+	for i, p := range paths {
+		if p == "" {
+			continue
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		valid[i] = f
+	}
+
+	fill := make([]io.Writer, len(paths))
+	for _, i := range missing {
+		f, err := os.CreateTemp("", "0g-rebuild-*.dat")
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		paths[i] = f.Name()
+		fill[i] = f
+	}
+	return enc.Reconstruct(valid, fill)
+}
+
+func createFiles(paths []string) ([]*os.File, error) {
+	files := make([]*os.File, 0, len(paths))
+	for _, p := range paths {
+		f, err := os.Create(p)
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func openFiles(paths []string) ([]*os.File, error) {
+	files := make([]*os.File, 0, len(paths))
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+func asWriters(files []*os.File) []io.Writer {
+	ws := make([]io.Writer, len(files))
+	for i, f := range files {
+		ws[i] = f
+	}
+	return ws
+}
+
+func asReaders(files []*os.File) []io.Reader {
+	rs := make([]io.Reader, len(files))
+	for i, f := range files {
+		rs[i] = f
+	}
+	return rs
+}
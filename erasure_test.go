@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --erasure k:m 上传后删掉 m-k 个分片的 root，仍然能恢复；再多删一个就应报可用分片不足
+func TestErasureRoundTripWithLostFragments(t *testing.T) {
+	dir := setupTest(t)
+	erasureSpec = "2:3"
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 5001, 3)
+	m := uploadTestFile(t, path)
+	if m.Erasure == nil || len(m.Fragments) != 3 {
+		t.Fatalf("期望 3 个纠删码分片，实际 %d 个，erasure %+v", len(m.Fragments), m.Erasure)
+	}
+
+	store := testStore().dir
+	if err := os.Remove(filepath.Join(store, m.Fragments[0].Root)); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "restored.bin")
+	if err := restoreFile(m, out); err != nil {
+		t.Fatalf("丢失 1 个分片后恢复失败: %v", err)
+	}
+	assertFileContent(t, out, data)
+	if err := verifyMD5(out, m.OriginHash); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(store, m.Fragments[2].Root)); err != nil {
+		t.Fatal(err)
+	}
+	err := restoreFile(m, out)
+	if err == nil || !strings.Contains(err.Error(), "可用分片不足") {
+		t.Fatalf("期望可用分片不足，实际 %v", err)
+	}
+}
+
+// reconstruct：分片 2 在存储网络上已不可用，只下载剩下的 k=3 个分片就恢复出原文件
+func TestReconstructFromAvailableShards(t *testing.T) {
+	dir := setupTest(t)
+	erasureSpec = "3:4"
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 7000, 5)
+	m := uploadTestFile(t, path)
+	store := testStore().dir
+	os.Remove(filepath.Join(store, m.Fragments[1].Root))
+
+	counter := useCountingStorage()
+	out := filepath.Join(dir, "reconstructed.bin")
+	var err error
+	log := captureStdout(t, func() { err = reconstructErasure(m, out) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, out, data)
+	if n := counter.downloadCount(); n != 3 {
+		t.Fatalf("应只下载 k=3 个分片，实际下载 %d 个", n)
+	}
+	if !strings.Contains(log, "使用的分片: 1, 3, 4") || !strings.Contains(log, "缺失的分片: 2") {
+		t.Fatalf("没有报告使用 / 缺失的分片:\n%s", log)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 进程退出码，脚本可以据此区分失败阶段
+const (
+	ExitConfig   = 2 // 参数 / 配置 / 输入文件错误
+	ExitUpload   = 3 // 切分或上传阶段失败
+	ExitDownload = 4 // 下载 / 合并阶段失败
+	ExitVerify   = 5 // 校验失败（文件不完整）
+	ExitHook     = 6 // --on-success 钩子执行失败
+)
+
+const exitCodeHelp = `退出码:
+  0  成功
+  2  参数 / 配置错误
+  3  上传失败
+  4  下载失败
+  5  校验失败
+  6  --on-success 钩子执行失败`
+
+// ExitError 给错误打上所属阶段，main 里据此决定退出码
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+func (e *ExitError) Unwrap() error { return e.Err }
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *ExitError
+	if errors.As(err, &e) {
+		return err // 已经分类过，保留最早的阶段
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+func configError(err error) error   { return withExitCode(ExitConfig, err) }
+func uploadError(err error) error   { return withExitCode(ExitUpload, err) }
+func downloadError(err error) error { return withExitCode(ExitDownload, err) }
+func verifyError(err error) error   { return withExitCode(ExitVerify, err) }
+
+func verifyErrorf(format string, args ...interface{}) error {
+	return verifyError(fmt.Errorf(format, args...))
+}
+
+// 子命令返回的错误都已分类；没分类的来自 cobra 自身（未知 flag、缺少必填参数等）
+func exitCode(err error) int {
+	var e *ExitError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ExitConfig
+}
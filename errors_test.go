@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 上传总是失败的存储
+type failingStorage struct{ fakeStorage }
+
+func (failingStorage) Upload(path string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+// 每条失败路径返回的错误都带上对应的退出码；只检查 exitCode，不真正退出进程
+func TestExitCodes(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want int
+		run  func(t *testing.T) error
+	}{
+		{"config", ExitConfig, func(t *testing.T) error {
+			setupTest(t)
+			filePath = filepath.Join(t.TempDir(), "missing.bin")
+			return run()
+		}},
+		{"upload", ExitUpload, func(t *testing.T) error {
+			dir := setupTest(t)
+			storage = failingStorage{testStore()}
+			filePath = filepath.Join(dir, "src.bin")
+			writeTestFile(t, filePath, 100, 0)
+			return run()
+		}},
+		{"download", ExitDownload, func(t *testing.T) error {
+			manifest, _ := uploadForDownload(t, 3000, 1000)
+			os.RemoveAll(testStore().dir) // 上传过的分片全部丢失
+			dlManifests = []string{manifest}
+			dlOutput = filepath.Join(t.TempDir(), "out.bin")
+			return restoreFromManifest()
+		}},
+		{"verify", ExitVerify, func(t *testing.T) error {
+			manifest, _ := uploadForDownload(t, 3000, 1000)
+			m, err := loadManifest(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.OriginHash = strings.Repeat("0", len(m.OriginHash)) // manifest 记录的整文件哈希被改动
+			if err := saveManifest(manifest, m); err != nil {
+				t.Fatal(err)
+			}
+			dlManifests = []string{manifest}
+			dlOutput = filepath.Join(t.TempDir(), "out.bin")
+			return restoreFromManifest()
+		}},
+		{"hook", ExitHook, func(t *testing.T) error {
+			setupTest(t)
+			onSuccessHook = "exit 7"
+			return runRestoreHooks("x", nil)
+		}},
+		{"unclassified", ExitConfig, func(t *testing.T) error {
+			return fmt.Errorf("unknown flag: --bogus")
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.run(t)
+			if err == nil {
+				t.Fatal("期望失败")
+			}
+			if got := exitCode(err); got != tc.want {
+				t.Fatalf("退出码 %d，期望 %d: %v", got, tc.want, err)
+			}
+		})
+	}
+}
+
+// 已经分类的错误再包一层时保留最早的阶段
+func TestWithExitCodeKeepsFirstClass(t *testing.T) {
+	err := uploadError(verifyError(errors.New("x")))
+	if exitCode(err) != ExitVerify {
+		t.Fatalf("退出码 %d，期望 %d", exitCode(err), ExitVerify)
+	}
+	if withExitCode(ExitUpload, nil) != nil {
+		t.Fatal("nil 错误应保持 nil")
+	}
+}
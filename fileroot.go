@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// file_root：把各分片的 root 按 Index 顺序组成一棵 keccak256 Merkle 树，根记在 manifest 里。
+// 下载时用 manifest 里的分片 root 重新计算并比较，任何一个 root 被改动都会发现，和整文件哈希互相独立。
+//
+//	叶子: keccak256(分片各段 root 依次拼接)；全零分片没有 root，叶子是 keccak256(空)
+//	内部节点: keccak256(左 || 右)，奇数个时最后一个直接升到上一层
+func combinedFileRoot(frags []ManifestFragment) (string, error) {
+	if len(frags) == 0 {
+		return "", fmt.Errorf("manifest 没有分片")
+	}
+	level := make([][]byte, 0, len(frags))
+	for _, frag := range frags {
+		var roots [][]byte
+		for _, p := range frag.pieces() {
+			if p.Root == "" {
+				return "", fmt.Errorf("分片 %d 没有 root", frag.Index+1)
+			}
+			b, err := hex.DecodeString(strings.TrimPrefix(p.Root, "0x"))
+			if err != nil {
+				return "", fmt.Errorf("分片 %d 的 root %q 格式无效", frag.Index+1, p.Root)
+			}
+			roots = append(roots, b)
+		}
+		level = append(level, crypto.Keccak256(roots...))
+	}
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i+1 < len(level); i += 2 {
+			next = append(next, crypto.Keccak256(level[i], level[i+1]))
+		}
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		level = next
+	}
+	return "0x" + hex.EncodeToString(level[0]), nil
+}
+
+// 写 manifest（和签名）之前记下 file_root；有分片没有 root（例如只上传了一部分）时不记
+func setFileRoot(m *Manifest) {
+	root, err := combinedFileRoot(m.Fragments)
+	if err != nil {
+		m.FileRoot = ""
+		return
+	}
+	m.FileRoot = root
+}
+
+// 按 manifest 里的分片 root 重新计算 file_root 并比较；老 manifest 没有 file_root 时跳过
+func verifyFileRoot(m *Manifest) error {
+	if m.FileRoot == "" {
+		return nil
+	}
+	root, err := combinedFileRoot(m.Fragments)
+	if err != nil {
+		return fmt.Errorf("manifest %s 无法计算 file_root: %w", m.FileName, err)
+	}
+	if !strings.EqualFold(root, m.FileRoot) {
+		return fmt.Errorf("manifest %s 的 file_root 校验失败: 记录 %s，按分片 root 重新计算为 %s，分片 root 被改动过", m.FileName, m.FileRoot, root)
+	}
+	fmt.Printf("manifest %s 的 file_root 校验通过: %s\n", m.FileName, root)
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCombinedFileRootDependsOnEveryRoot(t *testing.T) {
+	frags := []ManifestFragment{
+		{Index: 0, Root: "0x" + strings.Repeat("1", 64)},
+		{Index: 1, Root: "0x" + strings.Repeat("2", 64)},
+		{Index: 2, Root: "0x" + strings.Repeat("3", 64)},
+	}
+	base, err := combinedFileRoot(frags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range frags {
+		tampered := append([]ManifestFragment(nil), frags...)
+		tampered[i].Root = "0x" + strings.Repeat("4", 64)
+		root, err := combinedFileRoot(tampered)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root == base {
+			t.Fatalf("改动分片 %d 的 root 后 file_root 没有变化", i+1)
+		}
+	}
+}
+
+// manifest 中分片 2 的 root 被改动但 file_root 没变：下载前 file_root 校验失败，不下载任何分片
+func TestDownloadRejectsTamperedFragmentRoot(t *testing.T) {
+	manifest, _ := uploadForDownload(t, 3500, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FileRoot == "" {
+		t.Fatal("上传应记录 file_root")
+	}
+	m.Fragments[1].Root = "0x" + strings.Repeat("ab", 32)
+	if err := saveManifest(manifest, m); err != nil {
+		t.Fatal(err)
+	}
+	counter := useCountingStorage()
+	dlManifests = []string{manifest}
+	dlOutput = filepath.Join(t.TempDir(), "out.bin")
+	err = restoreFromManifest()
+	if err == nil || exitCode(err) != ExitVerify || !strings.Contains(err.Error(), "file_root 校验失败") {
+		t.Fatalf("分片 root 被改动时应报 file_root 校验失败，实际 %v", err)
+	}
+	if n := counter.downloadCount(); n != 0 {
+		t.Fatalf("校验失败前已下载了 %d 个分片", n)
+	}
+}
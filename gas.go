@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/spf13/cobra"
+)
+
+// --gas-price / --gas-price-bump：链上拥堵时提高出价加快确认（单位 wei）。
+// SDK 的 upload 命令只发 legacy 交易、只接受 --gas-price，没有 EIP-1559 的 maxPriorityFeePerGas，
+// 所以 --gas-price-bump 不是小费，而是直接加到 gas price 上：只给它时以节点当前的 eth_gasPrice 为基础
+var (
+	gasPrice     int64
+	gasPriceBump int64
+
+	effectiveGasPrice *big.Int // 实际传给 SDK 的 --gas-price；nil 表示由 SDK 自己决定
+)
+
+// 这几个是根命令的持久参数，从根命令的 PersistentFlags() 判断是否给出，子命令里调用也一样。
+// --priority-fee 是 --gas-price-bump 改名前的写法，保留为弃用的别名
+func validateGasFlags(c *cobra.Command) error {
+	flags := c.Root().PersistentFlags()
+	for _, f := range []struct {
+		name string
+		v    int64
+	}{{"gas-price", gasPrice}, {"gas-price-bump", gasPriceBump}, {"priority-fee", gasPriceBump}} {
+		if flags.Changed(f.name) && f.v <= 0 {
+			return fmt.Errorf("--%s 必须大于 0: %d", f.name, f.v)
+		}
+	}
+	return nil
+}
+
+// 上传前确定实际的 gas price，只在第一次调用时查询节点
+func resolveGasPrice() error {
+	if effectiveGasPrice != nil || (gasPrice <= 0 && gasPriceBump <= 0) {
+		return nil
+	}
+	base := big.NewInt(gasPrice)
+	if gasPrice <= 0 {
+		var raw string
+		if err := rpcCall(rpcURL, "eth_gasPrice", []interface{}{}, &raw); err != nil {
+			return fmt.Errorf("--gas-price-bump 需要查询当前 gas price: %w", err)
+		}
+		v, err := parseQuantity(raw)
+		if err != nil {
+			return err
+		}
+		base = v
+	}
+	effectiveGasPrice = new(big.Int).Add(base, big.NewInt(max(gasPriceBump, 0)))
+	fmt.Printf("交易 gas 设置: %s\n", gasSummary())
+	return nil
+}
+
+// 给 SDK 的额外参数
+func sdkGasArgs() []string {
+	if effectiveGasPrice == nil {
+		return nil
+	}
+	return []string{"--gas-price", effectiveGasPrice.String()}
+}
+
+func gasSummary() string {
+	switch {
+	case effectiveGasPrice == nil:
+		return "由 SDK 决定"
+	case gasPriceBump <= 0:
+		return fmt.Sprintf("gas price %s wei（--gas-price）", effectiveGasPrice)
+	case gasPrice <= 0:
+		return fmt.Sprintf("gas price %s wei（节点当前 gas price + --gas-price-bump %d）", effectiveGasPrice, gasPriceBump)
+	default:
+		return fmt.Sprintf("gas price %s wei（--gas-price %d + --gas-price-bump %d）", effectiveGasPrice, gasPrice, gasPriceBump)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// --gas-price / --gas-price-bump 最终以 --gas-price 传给 SDK：只给 bump 时以节点当前 gas price 为基础
+func TestGasOverridesReachSDKArgs(t *testing.T) {
+	setupPreflight(t)
+	if err := resolveGasPrice(); err != nil || sdkGasArgs() != nil || gasSummary() != "由 SDK 决定" {
+		t.Fatalf("没有覆盖时不应传 --gas-price: %v %v", sdkGasArgs(), err)
+	}
+
+	for _, tc := range []struct {
+		price, bump int64
+		want        string
+		summary     string
+	}{
+		{price: 100, want: "100", summary: "gas price 100 wei（--gas-price）"},
+		{bump: 5, want: "12", summary: "节点当前 gas price + --gas-price-bump 5"},
+		{price: 100, bump: 5, want: "105", summary: "--gas-price 100 + --gas-price-bump 5"},
+	} {
+		effectiveGasPrice = nil
+		gasPrice, gasPriceBump = tc.price, tc.bump
+		if err := resolveGasPrice(); err != nil {
+			t.Fatal(err)
+		}
+		args := sdkArgMap(sdkUploadArgs("fragment_000.dat"))
+		if args["--gas-price"] != tc.want || !strings.Contains(gasSummary(), tc.summary) {
+			t.Fatalf("--gas-price %d --gas-price-bump %d: SDK --gas-price %q，汇总 %q", tc.price, tc.bump, args["--gas-price"], gasSummary())
+		}
+	}
+
+	for _, name := range []string{"gas-price-bump", "priority-fee"} {
+		c := newRootCmd()
+		if err := c.PersistentFlags().Set(name, "-1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := validateGasFlags(c); err == nil {
+			t.Fatalf("--%s 不是正数时应报错", name)
+		}
+	}
+
+	// 弃用的 --priority-fee 仍然生效，等同 --gas-price-bump
+	c := newRootCmd()
+	if err := c.PersistentFlags().Set("priority-fee", "5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateGasFlags(c); err != nil || gasPriceBump != 5 {
+		t.Fatalf("--priority-fee 5: bump %d, %v", gasPriceBump, err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// 本工具创建的临时目录里都放一个标记文件，gc 只删同时满足前缀、标记和时间条件的目录，
+// 不会误删别的程序恰好同名的目录
+const tempMarker = ".0g-split-upload"
+
+var tempPrefixes = []string{"0g-split-", "0g-tar-", "0g-bundle-", "audit_"}
+
+var (
+	gcOnStart   bool
+	gcOlderThan time.Duration
+	gcDryRun    bool
+)
+
+// 创建带标记文件的临时目录（取代 os.MkdirTemp）
+func makeTempDir(pattern string) (string, error) {
+	dir, err := os.MkdirTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, tempMarker), []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+func newGCCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "gc",
+		Short: "清理崩溃的运行遗留在系统临时目录里的分片目录",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			removed, err := sweepTempDirs(os.TempDir(), gcOlderThan, gcDryRun)
+			if err != nil {
+				return configError(err)
+			}
+			fmt.Printf("共清理 %d 个临时目录\n", len(removed))
+			return nil
+		},
+	}
+	c.Flags().BoolVar(&gcDryRun, "dry-run", false, "只列出会被删除的目录")
+	return c
+}
+
+// 删除 root 下本工具留下、且修改时间早于 olderThan 的临时目录，返回删除（或 dryRun 时将删除）的路径
+func sweepTempDirs(root string, olderThan time.Duration, dryRun bool) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+
+	var removed []string
+	for _, e := range entries {
+		if !e.IsDir() || !hasTempPrefix(e.Name()) {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		marker, err := os.Stat(filepath.Join(dir, tempMarker))
+		if err != nil || marker.ModTime().After(cutoff) || ownerAlive(filepath.Join(dir, tempMarker)) {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("将删除: %s（创建于 %s）\n", dir, marker.ModTime().Format(time.RFC3339))
+		} else {
+			if err := os.RemoveAll(dir); err != nil {
+				logrus.Warnf("删除 %s 失败: %v", dir, err)
+				continue
+			}
+			fmt.Printf("已删除: %s\n", dir)
+		}
+		removed = append(removed, dir)
+	}
+	return removed, nil
+}
+
+// 标记文件里记录的进程还在运行（超长任务），不删
+func ownerAlive(marker string) bool {
+	data, err := os.ReadFile(marker)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	return processAlive(pid)
+}
+
+func hasTempPrefix(name string) bool {
+	for _, p := range tempPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
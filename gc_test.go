@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// 只删同时满足：工具前缀、有标记文件、足够旧、创建它的进程已退出
+func TestSweepTempDirsOnlyStaleToolDirs(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+
+	sleeper := exec.Command("sleep", "30")
+	if err := sleeper.Start(); err != nil {
+		t.Skipf("无法启动子进程: %v", err)
+	}
+	defer sleeper.Process.Kill()
+
+	dead := exec.Command("true")
+	if err := dead.Run(); err != nil {
+		t.Skipf("无法运行子进程: %v", err)
+	}
+
+	mk := func(name string, marker bool, pid int, mtime time.Time) {
+		dir := filepath.Join(root, name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if !marker {
+			return
+		}
+		path := filepath.Join(dir, tempMarker)
+		os.WriteFile(path, []byte(fmt.Sprintf("%d\n", pid)), 0644)
+		os.Chtimes(path, mtime, mtime)
+	}
+	mk("0g-split-stale", true, dead.Process.Pid, old)
+	mk("audit_stale", true, dead.Process.Pid, old)
+	mk("0g-tar-fresh", true, dead.Process.Pid, time.Now())
+	mk("0g-split-nomarker", false, 0, old)
+	mk("other-stale", true, dead.Process.Pid, old)
+	mk("0g-split-running", true, sleeper.Process.Pid, old)
+
+	removed, err := sweepTempDirs(root, 24*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range removed {
+		names = append(names, filepath.Base(p))
+	}
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[0g-split-stale audit_stale]" {
+		t.Fatalf("删除了 %v，期望只删 0g-split-stale 和 audit_stale", names)
+	}
+	for _, name := range []string{"0g-tar-fresh", "0g-split-nomarker", "other-stale", "0g-split-running"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Fatalf("%s 不应被删除: %v", name, err)
+		}
+	}
+}
+
+func TestProcessAlive(t *testing.T) {
+	if !processAlive(os.Getpid()) {
+		t.Fatal("当前进程应被判为存活")
+	}
+	dead := exec.Command("true")
+	if err := dead.Run(); err != nil {
+		t.Skipf("无法运行子进程: %v", err)
+	}
+	if processAlive(dead.Process.Pid) {
+		t.Fatal("已退出的进程不应被判为存活")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// 单个分片的校验算法，和整文件 MD5 相互独立：
+// 分片用 crc32 / xxhash 可以很快地逐片校验，整文件仍然保留强哈希
+var fragmentHashAlgo string
+
+func newFragmentHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	case "xxhash":
+		return xxhash.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("不支持的分片哈希算法: %q（可选 crc32 / xxhash / sha256）", algo)
+	}
+}
+
+func fragmentDigest(algo string, data []byte) (string, error) {
+	h, err := newFragmentHasher(algo)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileFragmentDigest(algo string, path string) (string, error) {
+	h, err := newFragmentHasher(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, ctxReader{runCtx, f}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 下载后按 manifest 记录的算法校验分片；老 manifest 没有分片哈希时跳过
+func verifyFragmentFile(m *Manifest, frag ManifestFragment, path string) error {
+	if m.FragmentHashAlgo == "" || frag.Hash == "" {
+		return nil
+	}
+	sum, err := fileFragmentDigest(m.FragmentHashAlgo, path)
+	if err != nil {
+		return err
+	}
+	if sum != frag.Hash {
+		if f, err := os.Open(path); err == nil {
+			dumpBadFragment(frag, m.FragmentHashAlgo, sum, f)
+			f.Close()
+		}
+		return fmt.Errorf("分片 %d %s 校验失败: 期望 %s，实际 %s", frag.Index+1, m.FragmentHashAlgo, frag.Hash, sum)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 每种 --fragment-hash 算法都记录进 manifest，恢复时按记录的算法校验通过；分片内容被改动时校验失败
+func TestFragmentHashAlgoRoundTrip(t *testing.T) {
+	for _, algo := range []string{"crc32", "xxhash", "sha256"} {
+		t.Run(algo, func(t *testing.T) {
+			dir := setupTest(t)
+			fragmentSize = 1000
+			fragmentHashAlgo = algo
+			path := filepath.Join(dir, "data.bin")
+			data := writeTestFile(t, path, 2500, 9)
+			m := uploadTestFile(t, path)
+			if m.FragmentHashAlgo != algo || m.HashAlgo != "md5" {
+				t.Fatalf("manifest 记录的算法: 分片 %q，整文件 %q", m.FragmentHashAlgo, m.HashAlgo)
+			}
+			for _, frag := range m.Fragments {
+				want, _ := fragmentDigest(algo, data[frag.Offset:frag.Offset+frag.Size])
+				if frag.Hash != want {
+					t.Fatalf("分片 %d 哈希 %s，期望 %s", frag.Index, frag.Hash, want)
+				}
+			}
+
+			fragmentHashAlgo = "sha256" // 下载只看 manifest 记录的算法
+			out := filepath.Join(dir, "out.bin")
+			if err := restoreFile(m, out); err != nil {
+				t.Fatal(err)
+			}
+			assertFileContent(t, out, data)
+
+			bad := filepath.Join(dir, "bad.dat")
+			corrupted := append([]byte{}, data[:1000]...)
+			corrupted[10] ^= 0xff
+			os.WriteFile(bad, corrupted, 0644)
+			err := verifyFragmentFile(m, m.Fragments[0], bad)
+			if err == nil || !strings.Contains(err.Error(), algo+" 校验失败") {
+				t.Fatalf("期望 %s 校验失败，实际 %v", algo, err)
+			}
+		})
+	}
+}
+
+// 4GB 的稀疏文件整文件 MD5 要读好几秒；中途取消后应在一次读取内返回 context.Canceled
+func TestFileMD5Cancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(4 << 30); err != nil {
+		f.Close()
+		t.Skipf("无法创建稀疏文件: %v", err)
+	}
+	f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = fileMD5(ctx, path)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("取消后应返回 context.Canceled，实际 %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("取消后 %s 才返回", d)
+	}
+}
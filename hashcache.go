@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 同一个大文件反复运行时不用每次都重新算整文件哈希。
+// 以 路径+大小+mtime 为 key，任何一项变化都视为失效。
+type hashCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // UnixNano
+	Algo    string `json:"algo"`
+	Hash    string `json:"hash"`
+}
+
+func hashCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "0g-split-upload", "hash-cache.json")
+}
+
+func loadHashCache(path string) map[string]hashCacheEntry {
+	cache := map[string]hashCacheEntry{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		logrus.Warnf("哈希缓存损坏，忽略: %v", err)
+		return map[string]hashCacheEntry{}
+	}
+	return cache
+}
+
+func saveHashCache(path string, cache map[string]hashCacheEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// 带缓存的整文件 MD5；缓存读写失败不影响结果，只是退化成直接计算
+func cachedFileMD5(path string) (string, error) {
+	if sum, ok := lookupCachedMD5(path); ok {
+		return sum, nil
+	}
+	sum, err := fileMD5(runCtx, path)
+	if err != nil {
+		return "", err
+	}
+	storeCachedMD5(path, sum)
+	return sum, nil
+}
+
+func lookupCachedMD5(path string) (string, bool) {
+	abs, info, err := hashCacheKey(path)
+	if err != nil {
+		return "", false
+	}
+	e, ok := loadHashCache(hashCachePath())[abs]
+	if ok && e.Algo == "md5" && e.Size == info.Size() && e.ModTime == info.ModTime().UnixNano() {
+		logrus.Debugf("哈希缓存命中: %s", abs)
+		return e.Hash, true
+	}
+	return "", false
+}
+
+// 记录已经算好的整文件 MD5（例如切分时顺带算出的），下次同一文件可以直接用
+func storeCachedMD5(path string, sum string) {
+	abs, info, err := hashCacheKey(path)
+	if err != nil {
+		return
+	}
+	cachePath := hashCachePath()
+	cache := loadHashCache(cachePath)
+	cache[abs] = hashCacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Algo: "md5", Hash: sum}
+	if err := saveHashCache(cachePath, cache); err != nil {
+		logrus.Warnf("写哈希缓存失败: %v", err)
+	}
+}
+
+func hashCacheKey(path string) (string, os.FileInfo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", nil, err
+	}
+	return abs, info, nil
+}
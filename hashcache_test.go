@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// 内容换了但大小、mtime 不变时缓存命中（说明没有重新读文件），mtime 一变就重新计算
+func TestCachedFileMD5(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "big.bin")
+	first := writeTestFile(t, path, 5000, 1)
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := cachedFileMD5(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != md5Hex(first) {
+		t.Fatalf("MD5 %s，期望 %s", sum, md5Hex(first))
+	}
+
+	second := writeTestFile(t, path, 5000, 2)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if sum, _ := cachedFileMD5(path); sum != md5Hex(first) {
+		t.Fatalf("大小和 mtime 没变应命中缓存，实际重新计算得到 %s", sum)
+	}
+
+	if err := os.Chtimes(path, mtime, mtime.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if sum, _ := cachedFileMD5(path); sum != md5Hex(second) {
+		t.Fatalf("mtime 变化后应重新计算为 %s，实际 %s", md5Hex(second), sum)
+	}
+}
+
+func TestLookupCachedMD5SizeChange(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "f.bin")
+	data := writeTestFile(t, path, 100, 0)
+	storeCachedMD5(path, md5Hex(data))
+	if _, ok := lookupCachedMD5(path); !ok {
+		t.Fatal("刚写入的缓存没有命中")
+	}
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, 50); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, info.ModTime(), info.ModTime())
+	if _, ok := lookupCachedMD5(path); ok {
+		t.Fatal("文件大小变化后缓存仍然命中")
+	}
+}
+
+// 上传时整文件 MD5 命中缓存就不再边切分边计算；--paranoid 时总是重新计算
+func TestNewOriginHashUsesCache(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "src.bin")
+	data := writeTestFile(t, path, 3000, 0)
+	storeCachedMD5(path, "cached-md5")
+
+	w, sum := newOriginHash(path)
+	if w != io.Discard || sum() != "cached-md5" {
+		t.Fatalf("缓存命中时应直接返回缓存的 MD5，实际 %q", sum())
+	}
+
+	paranoid = true
+	w, sum = newOriginHash(path)
+	w.Write(data)
+	if sum() != md5Hex(data) {
+		t.Fatalf("--paranoid 时应重新计算，实际 %q", sum())
+	}
+}
+
+// --paranoid：切分时算出的 MD5 和二次读取不一致（文件在两次读取之间被改写）时报校验错误
+func TestParanoidDetectsChangeBetweenReads(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "live.log")
+	first := writeTestFile(t, path, 4000, 0)
+	filePath, paranoid = path, true
+	if err := checkOriginMD5(md5Hex(first)); err != nil {
+		t.Fatalf("文件没变时不应报错: %v", err)
+	}
+
+	writeTestFile(t, path, 2000, 0) // 模拟日志被截断
+	err := checkOriginMD5(md5Hex(first))
+	if err == nil || exitCode(err) != ExitVerify || !strings.Contains(err.Error(), "切分期间发生变化") {
+		t.Fatalf("期望检测到源文件变化，实际 %v", err)
+	}
+}
+
+// --no-verify：不计算整文件 MD5（也不写哈希缓存），manifest 没有整文件哈希，运行照常成功
+func TestNoVerifySkipsHashing(t *testing.T) {
+	dir := setupTest(t)
+	noVerify, fragmentSize = true, 1000
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 2500, 0)
+	var m *Manifest
+	out := captureStdout(t, func() { m = uploadTestFile(t, path) })
+
+	if m.HashAlgo != "" || m.OriginHash != "" {
+		t.Fatalf("--no-verify 时 manifest 不应有整文件哈希: %q %q", m.HashAlgo, m.OriginHash)
+	}
+	if strings.Contains(out, "原始文件 MD5") || strings.Contains(out, "恢复文件 MD5") || !strings.Contains(out, "跳过 MD5 校验") {
+		t.Fatalf("--no-verify 时不应计算 MD5:\n%s", out)
+	}
+	if _, err := os.Stat(hashCachePath()); !os.IsNotExist(err) {
+		t.Fatalf("--no-verify 时不应写哈希缓存: %v", err)
+	}
+	assertFileContent(t, path+".restored", data)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	onSuccessHook string // 恢复并校验成功后执行的命令
+	onFailureHook string // 恢复或校验失败后执行的命令
+)
+
+// 恢复流程结束后按结果执行 --on-success / --on-failure。
+// 成功钩子失败会让整体以 ExitHook 退出；失败钩子只记录日志，保留原来的错误
+func runRestoreHooks(restoredPath string, restoreErr error) error {
+	if restoreErr == nil {
+		if onSuccessHook == "" {
+			return nil
+		}
+		if err := runHook("on-success", onSuccessHook, restoredPath, nil); err != nil {
+			return withExitCode(ExitHook, err)
+		}
+		return nil
+	}
+
+	if onFailureHook != "" {
+		if err := runHook("on-failure", onFailureHook, restoredPath, restoreErr); err != nil {
+			logrus.Warn(err)
+		}
+	}
+	return restoreErr
+}
+
+// 通过 sh -c 执行钩子命令，恢复文件路径放在 OG_RESTORED_PATH，失败原因放在 OG_RESTORE_ERROR
+func runHook(name string, command string, restoredPath string, restoreErr error) error {
+	c := exec.Command("sh", "-c", command)
+	c.Env = append(os.Environ(), "OG_RESTORED_PATH="+restoredPath)
+	if restoreErr != nil {
+		c.Env = append(c.Env, "OG_RESTORE_ERROR="+restoreErr.Error())
+	}
+
+	fmt.Printf("执行 --%s: %s\n", name, command)
+	out, err := c.CombinedOutput()
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if line != "" {
+			logrus.Infof("[%s] %s", name, line)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("--%s 执行失败: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 恢复校验通过后执行 --on-success，恢复文件路径在 OG_RESTORED_PATH 里
+func TestOnSuccessHookGetsRestoredPath(t *testing.T) {
+	dir := setupTest(t)
+	record := filepath.Join(dir, "hook.out")
+	onSuccessHook = `printf '%s' "$OG_RESTORED_PATH" > ` + record
+	onFailureHook = "touch " + filepath.Join(dir, "failure-ran")
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 1500, 0)
+	uploadTestFile(t, path)
+
+	got, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("--on-success 没有执行: %v", err)
+	}
+	if string(got) != path+".restored" {
+		t.Fatalf("OG_RESTORED_PATH = %q，期望 %q", got, path+".restored")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "failure-ran")); err == nil {
+		t.Fatal("成功时不应执行 --on-failure")
+	}
+}
+
+// 失败时执行 --on-failure，失败原因在 OG_RESTORE_ERROR 里，整体仍返回原来的错误
+func TestOnFailureHookKeepsOriginalError(t *testing.T) {
+	dir := setupTest(t)
+	record := filepath.Join(dir, "hook.out")
+	onFailureHook = `printf '%s' "$OG_RESTORE_ERROR" > ` + record + `; exit 3`
+	cause := verifyErrorf("MD5 不一致")
+	err := runRestoreHooks(filepath.Join(dir, "x.restored"), cause)
+	if !errors.Is(err, cause) || exitCode(err) != ExitVerify {
+		t.Fatalf("期望保留原来的校验错误，实际 %v（退出码 %d）", err, exitCode(err))
+	}
+	got, _ := os.ReadFile(record)
+	if !strings.Contains(string(got), "MD5 不一致") {
+		t.Fatalf("OG_RESTORE_ERROR = %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	proxyURL           string        // 访问 indexer / RPC 使用的代理
+	insecureSkipVerify bool          // 跳过 TLS 证书校验，仅用于调试
+	httpTimeout        time.Duration // 单个 HTTP 请求超时，0 表示不限制
+)
+
+// 按 flags 构造 HTTP client
+func newHTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("--proxy 地址无效: %q", proxyURL)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	if insecureSkipVerify {
+		logrus.Warn("已关闭 TLS 证书校验（--insecure-skip-verify），请勿在生产环境使用")
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	return &http.Client{Transport: transport, Timeout: httpTimeout}, nil
+}
+
+// 本程序自己发出的 RPC / indexer / 存储节点请求和 webhook 都用 endpointClient（见 rpcCall）。
+// 不替换 http.DefaultClient：SDK 的上传/下载命令不接受外部 client；
+// SDK 需要代理时按惯例设置 HTTPS_PROXY 环境变量
+var endpointClient = http.DefaultClient
+
+func setupHTTPClient() error {
+	client, err := newHTTPClient()
+	if err != nil {
+		return err
+	}
+	endpointClient = client
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 代理给经过的请求加上 X-Via-Proxy 头再转发；目标服务只接受带这个头的请求，
+// 能成功说明 rpcCall 用的是按 --proxy 构造的 client
+func TestRPCCallUsesConfiguredProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Via-Proxy") != "1" {
+			http.Error(w, "direct access", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":"ok"}`)
+	}))
+	defer target.Close()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequest(r.Method, r.URL.String(), r.Body)
+		req.Header = r.Header.Clone()
+		req.Header.Set("X-Via-Proxy", "1")
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+
+	setupTest(t)
+	if err := rpcCall(target.URL, "test_ping", nil, nil); err == nil {
+		t.Fatal("不经过代理时目标服务应拒绝")
+	}
+
+	proxyURL = proxy.URL
+	if err := setupHTTPClient(); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if err := rpcCall(target.URL, "test_ping", nil, &got); err != nil || got != "ok" {
+		t.Fatalf("经过代理调用失败: %q %v", got, err)
+	}
+	if http.DefaultClient == endpointClient || http.DefaultClient.Transport != nil {
+		t.Fatal("不应替换进程级的 http.DefaultClient")
+	}
+}
+
+func TestNewHTTPClientRejectsBadProxy(t *testing.T) {
+	setupTest(t)
+	proxyURL = "127.0.0.1:8080" // 缺少 scheme
+	if _, err := newHTTPClient(); err == nil || !strings.Contains(err.Error(), "--proxy") {
+		t.Fatalf("期望 --proxy 地址无效，实际 %v", err)
+	}
+}
+
+func TestNewHTTPClientKeepsTLSVerificationByDefault(t *testing.T) {
+	setupTest(t)
+	client, err := newHTTPClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := client.Transport.(*http.Transport)
+	if tr.TLSClientConfig != nil && tr.TLSClientConfig.InsecureSkipVerify {
+		t.Fatal("默认不能跳过 TLS 校验")
+	}
+}
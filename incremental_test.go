@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 文件追加数据后 --since-manifest 只上传新增部分，新 manifest 包含旧分片和新分片并能恢复整个文件；
+// 已覆盖的部分被改写时拒绝增量上传
+func TestSinceManifestUploadsOnlyAppended(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	path := filepath.Join(dir, "app.log")
+	data := writeTestFile(t, path, 4300, 3)
+	os.WriteFile(path, data[:2500], 0644)
+	old := uploadTestFile(t, path)
+	oldManifest := manifestPath
+
+	os.WriteFile(path, data, 0644)
+	counter := useCountingStorage()
+	sinceManifest, manifestPath = oldManifest, filepath.Join(dir, "app.log.v2.json")
+	m := uploadTestFile(t, path)
+
+	if n := counter.uploadCount(); n != 2 {
+		t.Fatalf("新增 1800 bytes 应只上传 2 个分片，实际上传 %d 个", n)
+	}
+	if len(m.Fragments) != 5 || m.FileSize != 4300 {
+		t.Fatalf("新 manifest 有 %d 个分片、%d bytes，期望 5 个、4300 bytes", len(m.Fragments), m.FileSize)
+	}
+	for i, frag := range old.Fragments {
+		if m.Fragments[i].Root != frag.Root {
+			t.Fatalf("旧分片 %d 的 root 变了", i)
+		}
+	}
+	if m.Fragments[3].Offset != 2500 || m.Fragments[4].Size != 800 {
+		t.Fatalf("新分片的位置不对: %+v / %+v", m.Fragments[3], m.Fragments[4])
+	}
+	assertFileContent(t, path+".restored", data)
+
+	data[100] ^= 0xff
+	os.WriteFile(path, data, 0644)
+	filePath, manifestPath = path, filepath.Join(dir, "app.log.v3.json")
+	if err := run(); err == nil {
+		t.Fatal("已上传的部分被改写时应拒绝增量上传")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+)
+
+var indexCSVPath string // --index-csv：额外写一份 index→root 的 CSV，给表格工具用
+
+// 每个分片一行: index,offset,length,hash,root（分段上传的分片 root 列是空格分隔的各段 root）。
+// SDK 上传命令只输出 root、不返回交易哈希，所以没有 tx 列
+func writeIndexCSV(path string, m *Manifest) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"index", "offset", "length", "hash", "root"})
+	for _, frag := range m.Fragments {
+		w.Write([]string{
+			strconv.Itoa(frag.Index),
+			strconv.FormatInt(frag.Offset, 10),
+			strconv.FormatInt(frag.Size, 10),
+			frag.Hash,
+			strings.Join(pieceRoots(frag), " "),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0644)
+}
+
+func pieceRoots(frag ManifestFragment) []string {
+	var roots []string
+	for _, p := range frag.pieces() {
+		roots = append(roots, p.Root)
+	}
+	return roots
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestIndexCSVOneRowPerFragment(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	indexCSVPath = filepath.Join(dir, "index.csv")
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 3500, 0)
+	m := uploadTestFile(t, path)
+
+	f, err := os.Open(indexCSVPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("encoding/csv 解析失败: %v", err)
+	}
+	if len(rows) != len(m.Fragments)+1 {
+		t.Fatalf("CSV 有 %d 行，期望表头 + %d 个分片", len(rows), len(m.Fragments))
+	}
+	header := []string{"index", "offset", "length", "hash", "root"}
+	if len(rows[0]) != len(header) {
+		t.Fatalf("表头 %v，期望 %v", rows[0], header)
+	}
+	for i, h := range header {
+		if rows[0][i] != h {
+			t.Fatalf("表头 %v，期望 %v", rows[0], header)
+		}
+	}
+	for i, frag := range m.Fragments {
+		want := []string{strconv.Itoa(frag.Index), strconv.FormatInt(frag.Offset, 10), strconv.FormatInt(frag.Size, 10), frag.Hash, frag.Root}
+		for j := range want {
+			if rows[i+1][j] != want[j] {
+				t.Fatalf("第 %d 行 %v，期望 %v", i+1, rows[i+1], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// 只有上传时用的 indexer 能找到数据，其他 indexer 一律返回 not found
+type indexerStorage struct {
+	fakeStorage
+	serving string
+	mu      sync.Mutex
+	asked   map[string][]string // root -> 依次询问过的 indexer
+}
+
+func (s *indexerStorage) Download(root string, indexer string) (string, error) {
+	s.mu.Lock()
+	s.asked[root] = append(s.asked[root], indexer)
+	s.mu.Unlock()
+	if indexer != s.serving {
+		return "", fmt.Errorf("root %s not found", root)
+	}
+	return s.fakeStorage.Download(root, indexer)
+}
+
+func TestDownloadNotFoundFallsBackToUploadIndexer(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	indexerURL = "fake://upload-indexer"
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 2500, 0)
+	m := uploadTestFile(t, path)
+	for _, frag := range m.Fragments {
+		if frag.Indexer != "fake://upload-indexer" {
+			t.Fatalf("分片 %d 没有记录上传时的 indexer: %q", frag.Index, frag.Indexer)
+		}
+	}
+
+	s := &indexerStorage{fakeStorage: testStore(), serving: "fake://upload-indexer", asked: map[string][]string{}}
+	storage = s
+	indexerURL = "fake://default-indexer"
+	out := filepath.Join(dir, "out.bin")
+	if err := restoreFile(m, out); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, out, data)
+	for _, frag := range m.Fragments {
+		got := s.asked[frag.Root]
+		if len(got) != 2 || got[0] != "fake://default-indexer" || got[1] != "fake://upload-indexer" {
+			t.Fatalf("分片 %d 期望先问默认 indexer 再问上传时的 indexer，实际 %v", frag.Index, got)
+		}
+	}
+}
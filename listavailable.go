@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var laDir string
+
+// list-available：扫描目录里的所有 manifest，报告每个文件的分片当前是否都能下载，方便清理已经失效的备份。
+// 多个 manifest 共用的 root 只查询一次，查询按 --concurrency 并发
+func newListAvailableCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "list-available",
+		Short: "检查目录中每个 manifest 的分片当前是否都可下载",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			checker, ok := storage.(availabilityChecker)
+			if !ok {
+				return configError(fmt.Errorf("当前存储后端不支持查询 root 是否可下载"))
+			}
+			entries, err := loadManifestDir(laDir)
+			if err != nil {
+				return configError(err)
+			}
+			if len(entries) == 0 {
+				return configError(fmt.Errorf("%s 中没有 manifest", laDir))
+			}
+			live := checkRootsAvailable(checker, entries, downloadWorkers())
+			dead, unknown := reportAvailability(entries, live)
+			if dead > 0 {
+				return verifyErrorf("%d/%d 个文件有分片不可下载", dead, len(entries))
+			}
+			if unknown > 0 {
+				// 不算作不可恢复，但结果不完整，不能当成全部可下载
+				return downloadError(fmt.Errorf("%d/%d 个文件有分片未能查询，请稍后重试", unknown, len(entries)))
+			}
+			return nil
+		},
+	}
+	c.Flags().StringVar(&laDir, "dir", "", "存放 manifest（*.json）的目录（必填）")
+	c.MarkFlagRequired("dir")
+	return c
+}
+
+// 单个 root 的查询结果。查询出错（例如 indexer 超时）时不知道是否可下载，不能当成已经失效
+type rootState int
+
+const (
+	rootMissing rootState = iota
+	rootLive
+	rootUnknown
+)
+
+type manifestEntry struct {
+	path string
+	m    *Manifest
+}
+
+// 目录下不是 manifest 的 JSON（例如 --report-format json 的输出）警告后跳过
+func loadManifestDir(dir string) ([]manifestEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var out []manifestEntry
+	for _, path := range paths {
+		files, err := loadManifestFiles(path)
+		if err != nil {
+			logrus.Warnf("跳过 %s: %v", path, err)
+			continue
+		}
+		for _, m := range files {
+			out = append(out, manifestEntry{path, m})
+		}
+	}
+	return out, nil
+}
+
+// 去重后并发查询所有 root，返回 root -> 查询结果；查询出错记为 rootUnknown
+func checkRootsAvailable(checker availabilityChecker, entries []manifestEntry, workers int) map[string]rootState {
+	live := map[string]rootState{}
+	var roots []string
+	for _, e := range entries {
+		for _, frag := range e.m.Fragments {
+			for _, p := range frag.pieces() {
+				if _, ok := live[p.Root]; !ok && p.Root != "" {
+					live[p.Root] = rootMissing
+					roots = append(roots, p.Root)
+				}
+			}
+		}
+	}
+	fmt.Printf("%d 个文件共 %d 个不同的 root，正在查询\n", len(entries), len(roots))
+
+	var mu sync.Mutex
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for root := range jobs {
+				state := rootMissing
+				ok, err := checker.Available(root)
+				switch {
+				case err != nil:
+					logrus.Warnf("查询 root %s 失败: %v", root, err)
+					state = rootUnknown
+				case ok:
+					state = rootLive
+				}
+				mu.Lock()
+				live[root] = state
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, root := range roots {
+		jobs <- root
+	}
+	close(jobs)
+	wg.Wait()
+	return live
+}
+
+// 每个文件一行汇总，返回确定不可恢复的文件数和因查询出错无法判断的文件数
+func reportAvailability(entries []manifestEntry, live map[string]rootState) (dead, unknown int) {
+	for _, e := range entries {
+		var missing, failed []int
+		for _, frag := range e.m.Fragments {
+			switch fragmentState(frag, live) {
+			case rootMissing:
+				missing = append(missing, frag.Index)
+			case rootUnknown:
+				failed = append(failed, frag.Index)
+			}
+		}
+		name := e.m.FileName
+		if e.m.Namespace != "" {
+			name = e.m.Namespace + "/" + name
+		}
+		total := len(e.m.Fragments)
+		available := total - len(missing) - len(failed)
+		// 查询失败的分片可能其实还在：只有按已确认缺失的分片就无法恢复时才算不可恢复
+		lost := len(missing) > 0
+		if e.m.Erasure != nil {
+			lost = total-len(missing) < e.m.Erasure.DataShards
+		}
+		switch {
+		case len(missing) == 0 && len(failed) == 0:
+			fmt.Printf("%s（%s）: 全部可下载，%d/%d 个分片\n", e.path, name, total, total)
+		case e.m.Erasure != nil && available >= e.m.Erasure.DataShards:
+			fmt.Printf("%s（%s）: %d/%d 个分片可下载，纠删码仍可恢复，缺失或未能查询: %s\n", e.path, name, available, total, formatShardList(append(missing, failed...)))
+		case lost:
+			dead++
+			fmt.Printf("%s（%s）: 不可恢复，%d/%d 个分片可下载，缺失: %s\n", e.path, name, available, total, formatShardList(missing))
+		default:
+			unknown++
+			fmt.Printf("%s（%s）: 未能查询，%d/%d 个分片可下载，查询失败: %s\n", e.path, name, available, total, formatShardList(failed))
+		}
+	}
+	summary := fmt.Sprintf("共 %d 个文件: %d 个可恢复，%d 个不可恢复", len(entries), len(entries)-dead-unknown, dead)
+	if unknown > 0 {
+		summary += fmt.Sprintf("，%d 个未能查询", unknown)
+	}
+	fmt.Println(summary)
+	return dead, unknown
+}
+
+// 有分片缺失就是 rootMissing，否则有查询失败的就是 rootUnknown；全零分片没有 root，总是可用
+func fragmentState(frag ManifestFragment, live map[string]rootState) rootState {
+	state := rootLive
+	for _, p := range frag.pieces() {
+		switch live[p.Root] {
+		case rootMissing:
+			return rootMissing
+		case rootUnknown:
+			state = rootUnknown
+		}
+	}
+	return state
+}
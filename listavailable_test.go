@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 目录里两个 manifest：一个分片全部在，一个缺了分片 2；每个文件一行汇总，有不可恢复的文件时以校验错误退出
+func TestListAvailableSummary(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	mdir := filepath.Join(dir, "manifests")
+	os.Mkdir(mdir, 0755)
+
+	var ms []*Manifest
+	for i, name := range []string{"live.bin", "dead.bin"} {
+		path := filepath.Join(dir, name)
+		writeTestFile(t, path, 3500, byte(i+1))
+		manifestPath = filepath.Join(mdir, name+".manifest.json")
+		ms = append(ms, uploadTestFile(t, path))
+	}
+	os.WriteFile(filepath.Join(mdir, "report.json"), []byte(`{"files": 1}`), 0644) // 不是 manifest，跳过
+	os.Remove(filepath.Join(testStore().dir, ms[1].Fragments[1].Root))
+
+	c := newListAvailableCmd()
+	laDir = mdir
+	var err error
+	out := captureStdout(t, func() { err = c.RunE(c, nil) })
+	if err == nil || exitCode(err) != ExitVerify || !strings.Contains(err.Error(), "1/2 个文件有分片不可下载") {
+		t.Fatalf("有文件缺分片时应以校验错误退出，实际 %v", err)
+	}
+	for _, want := range []string{
+		"live.bin.manifest.json（live.bin）: 全部可下载，4/4 个分片",
+		"dead.bin.manifest.json（dead.bin）: 不可恢复，3/4 个分片可下载，缺失: " + formatShardList([]int{1}),
+		"共 2 个文件: 1 个可恢复，1 个不可恢复",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("输出缺少 %q:\n%s", want, out)
+		}
+	}
+}
+
+// 查询指定 root 时出错（例如 indexer 超时）的后端
+type flakyChecker struct {
+	fakeStorage
+	failRoot string
+}
+
+func (s flakyChecker) Available(root string) (bool, error) {
+	if root == s.failRoot {
+		return false, errors.New("context deadline exceeded")
+	}
+	return s.fakeStorage.Available(root)
+}
+
+// root 查询出错时不知道分片在不在：文件单独列为未能查询，不算不可恢复，退出码也不是校验失败
+func TestListAvailableQueryError(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	mdir := filepath.Join(dir, "manifests")
+	os.Mkdir(mdir, 0755)
+	path := filepath.Join(dir, "flaky.bin")
+	writeTestFile(t, path, 3500, 5)
+	manifestPath = filepath.Join(mdir, "flaky.bin.manifest.json")
+	m := uploadTestFile(t, path)
+	storage = flakyChecker{fakeStorage: testStore(), failRoot: m.Fragments[2].Root}
+
+	c := newListAvailableCmd()
+	laDir = mdir
+	var err error
+	out := captureStdout(t, func() { err = c.RunE(c, nil) })
+	if err == nil || exitCode(err) != ExitDownload || !strings.Contains(err.Error(), "未能查询") {
+		t.Fatalf("查询出错时应报告结果不完整（下载阶段错误），实际 %v", err)
+	}
+	for _, want := range []string{
+		"flaky.bin.manifest.json（flaky.bin）: 未能查询，3/4 个分片可下载，查询失败: " + formatShardList([]int{2}),
+		"共 1 个文件: 0 个可恢复，0 个不可恢复，1 个未能查询",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("输出缺少 %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "）: 不可恢复") {
+		t.Fatalf("查询出错的文件不应标成不可恢复:\n%s", out)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// --manifest-lock（默认开启）：两个运行写同一个 manifest / checkpoint 会互相覆盖。运行期间锁住 <路径>.lock，
+// 拿不到锁直接失败。锁加在旁边的文件上，因为 manifest 是先写临时文件再 rename 的，锁本身的 inode 会被替换。
+// 加锁方式按平台分开实现：unix 用 flock（lock_unix.go），Windows 用 O_EXCL 锁文件（lock_windows.go）
+var manifestLock = true
+
+// 锁已被另一个运行持有，由各平台的 acquireLock 返回
+var errLockHeld = errors.New("锁已被持有")
+
+type fileLock struct {
+	f *os.File
+}
+
+func lockFile(target string) (*fileLock, error) {
+	path := target + ".lock"
+	f, err := acquireLock(path)
+	if err != nil {
+		if errors.Is(err, errLockHeld) {
+			holder, _ := os.ReadFile(path)
+			return nil, fmt.Errorf("%s 正被另一个运行使用（锁文件 %s，pid %s），请等它结束后再试", target, path, strings.TrimSpace(string(holder)))
+		}
+		return nil, fmt.Errorf("锁定 %s 失败: %w", path, err)
+	}
+	// 记下持有者的 pid，方便另一个运行报错时说明是谁
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &fileLock{f: f}, nil
+}
+
+// 读锁文件里记录的持有者 pid
+func lockHolder(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func (l *fileLock) unlock() {
+	releaseLock(l.f)
+}
+
+// 依次锁定 targets，任何一个失败就释放已拿到的锁；返回的函数释放全部锁
+func lockRunTargets(targets ...string) (func(), error) {
+	var held []*fileLock
+	release := func() {
+		for _, l := range held {
+			l.unlock()
+		}
+	}
+	if !manifestLock {
+		return release, nil
+	}
+	for _, t := range targets {
+		if t == "" {
+			continue
+		}
+		l, err := lockFile(t)
+		if err != nil {
+			release()
+			return nil, configError(err)
+		}
+		held = append(held, l)
+	}
+	return release, nil
+}
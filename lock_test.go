@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLockFileContention(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "a.manifest.json")
+	first, err := lockFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockFile(target); err == nil || !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Fatalf("第二次加锁应失败并报出持有者 pid，得到 %v", err)
+	}
+	first.unlock()
+	again, err := lockFile(target)
+	if err != nil {
+		t.Fatalf("释放后应能重新加锁: %v", err)
+	}
+	again.unlock()
+}
+
+// 第一个运行持有 manifest 的锁时，第二个运行在上传前就失败
+func TestRunAbortsWhenManifestLocked(t *testing.T) {
+	dir := setupTest(t)
+	if !manifestLock {
+		t.Fatal("--manifest-lock 应默认开启")
+	}
+	fragmentSize = 1000
+	src := filepath.Join(dir, "src.bin")
+	writeTestFile(t, src, 2500, 1)
+	filePath, manifestPath = src, src+".manifest.json"
+
+	release, err := lockRunTargets(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := useCountingStorage()
+	if err := run(); err == nil || exitCode(err) != ExitConfig || !strings.Contains(err.Error(), "正被另一个运行使用") {
+		t.Fatalf("锁被占用时应以配置错误退出，得到 %v", err)
+	}
+	if n := s.uploadCount(); n != 0 {
+		t.Fatalf("锁被占用时不应上传，得到 %d 次上传", n)
+	}
+	release()
+
+	uploadTestFile(t, src)
+}
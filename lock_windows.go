@@ -0,0 +1,32 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// Windows 没有 flock：用 O_EXCL 创建锁文件，文件存在即视为被占用，释放时删除。
+// 崩溃会留下锁文件，所以记录的持有者进程已经退出时把它当作过期锁删掉重试一次；
+// pid 读不出来（对方刚创建还没写入）时按被占用处理
+func acquireLock(path string) (*os.File, error) {
+	for retried := false; ; retried = true {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		pid, perr := lockHolder(path)
+		if retried || perr != nil || processAlive(pid) {
+			return nil, errLockHeld
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+func releaseLock(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
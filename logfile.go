@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// --log-file：长时间无人值守运行时，把日志（JSON 格式）同时写到文件，超过 --log-max-size 时轮转成
+// <file>.1、<file>.2 …，最多保留 --log-backups 份。--quiet 时终端只显示错误，文件里仍记录全部级别
+var (
+	logFilePath string
+	logMaxSize  = "100M"
+	logBackups  = 3
+	quiet       bool
+)
+
+func setupLogging() error {
+	if logFilePath == "" && !quiet {
+		return nil
+	}
+	stderrLevels := logrus.AllLevels
+	if quiet {
+		stderrLevels = []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+	}
+	// 各输出按自己的级别过滤，logger 本身不再直接写 stderr
+	logrus.SetOutput(io.Discard)
+	logrus.AddHook(&writerHook{w: os.Stderr, levels: stderrLevels, formatter: &logrus.TextFormatter{}})
+
+	if logFilePath == "" {
+		return nil
+	}
+	limit, err := parseByteSize(logMaxSize)
+	if err != nil {
+		return fmt.Errorf("--log-max-size: %w", err)
+	}
+	rf, err := openRotatingFile(logFilePath, limit, logBackups)
+	if err != nil {
+		return fmt.Errorf("--log-file: %w", err)
+	}
+	logrus.AddHook(&writerHook{w: rf, levels: logrus.AllLevels, formatter: &logrus.JSONFormatter{}})
+	return nil
+}
+
+type writerHook struct {
+	w         io.Writer
+	levels    []logrus.Level
+	formatter logrus.Formatter
+}
+
+func (h *writerHook) Levels() []logrus.Level { return h.levels }
+
+func (h *writerHook) Fire(e *logrus.Entry) error {
+	line, err := h.formatter.Format(e)
+	if err != nil {
+		return err
+	}
+	_, err = h.w.Write(line)
+	return err
+}
+
+// 按大小轮转的日志文件；一条日志不会被拆到两个文件里
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	limit   int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, limit int64, backups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, limit: limit, backups: max(backups, 0)}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 && r.size+int64(len(p)) > r.limit {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// <file>.N-1 -> <file>.N，…，<file> -> <file>.1，超出 backups 的最旧一份被覆盖；backups 为 0 时直接清空
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.backups == 0 {
+		if err := os.Truncate(r.path, 0); err != nil {
+			return err
+		}
+		return r.open()
+	}
+	for i := r.backups - 1; i >= 1; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
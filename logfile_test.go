@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// --log-file：日志以 JSON 逐行写入文件，超过 --log-max-size 轮转，最多保留 --log-backups 份；
+// --quiet 时终端只有错误，文件里仍是全部级别
+func TestLogFileRotation(t *testing.T) {
+	dir := setupTest(t)
+	logFilePath = filepath.Join(dir, "run.log")
+	logMaxSize = "1K"
+	logBackups = 2
+	quiet = true
+
+	stderr, err := os.Create(filepath.Join(dir, "stderr.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevStderr, prevOut := os.Stderr, logrus.StandardLogger().Out
+	os.Stderr = stderr
+	prevHooks := logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+	t.Cleanup(func() {
+		os.Stderr = prevStderr
+		logrus.SetOutput(prevOut)
+		logrus.StandardLogger().ReplaceHooks(prevHooks)
+		stderr.Close()
+	})
+	if err := setupLogging(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 60; i++ {
+		logrus.WithField("fragment", i).Infof("分片 %d 上传完成", i)
+	}
+	logrus.Error("最后一条是错误")
+
+	var entries []map[string]interface{}
+	for _, name := range []string{"run.log.2", "run.log.1", "run.log"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("轮转后应有 %s: %v", name, err)
+		}
+		if len(data) > 1024 {
+			t.Fatalf("%s 有 %d bytes，超过了 --log-max-size", name, len(data))
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var e map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				t.Fatalf("%s 中不是 JSON 日志行: %q", name, line)
+			}
+			entries = append(entries, e)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "run.log.3")); !os.IsNotExist(err) {
+		t.Fatalf("--log-backups 2 时不应有 run.log.3: %v", err)
+	}
+	last := entries[len(entries)-1]
+	if last["msg"] != "最后一条是错误" || last["level"] != "error" {
+		t.Fatalf("最新的日志应在 run.log 末尾，实际 %v", last)
+	}
+	for i := 1; i < len(entries)-1; i++ {
+		prev, cur := entries[i-1]["fragment"].(float64), entries[i]["fragment"].(float64)
+		if cur != prev+1 {
+			t.Fatalf("保留的日志应连续，第 %d 条之后是 %v", int(prev), entries[i])
+		}
+	}
+	if first := entries[0]["msg"]; first == "分片 0 上传完成" {
+		t.Fatal("超过保留份数的最旧日志应已被轮转掉")
+	}
+
+	printed, _ := os.ReadFile(stderr.Name())
+	if strings.Contains(string(printed), "上传完成") || !strings.Contains(string(printed), "最后一条是错误") {
+		t.Fatalf("--quiet 时终端只应有错误日志:\n%s", printed)
+	}
+}
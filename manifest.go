@@ -0,0 +1,467 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 当前程序写出的 manifest 版本
+//
+//	v1: 只有 origin_md5，没有 version / hash_algo 字段
+//	v2: 增加 version、hash_algo，整文件哈希改存 origin_hash
+//	v3: 增加 erasure（纠删码参数）和分片的 parity 标记，老程序不能按顺序拼接恢复
+//	v4: 增加分片的 padding（--pad-last 补零字节数），老程序会把补零拼进恢复文件
+//	v5: 增加分片的 codec（--compress），老程序会把压缩数据直接拼进恢复文件
+//	v6: 增加分片的 subs（分段上传），此时分片 root 为空，老程序无法下载
+//	v7: 增加分片的 zero（--skip-zero-fragments），全零分片没有 root，老程序无法下载
+//	v8: 增加 file_root（各分片 root 组成的 Merkle 根），只是新增可选字段
+const ManifestVersion = 8
+
+// Manifest 记录一次上传的完整信息，download 子命令靠它恢复文件
+type Manifest struct {
+	Version          int                `json:"version"`
+	Namespace        string             `json:"namespace,omitempty"` // 多团队共用 manifest 时的隔离前缀
+	FileName         string             `json:"file_name"`
+	FileSize         int64              `json:"file_size"`
+	FragmentSize     int64              `json:"fragment_size"`
+	HashAlgo         string             `json:"hash_algo,omitempty"`
+	OriginHash       string             `json:"origin_hash,omitempty"`
+	FragmentHashAlgo string             `json:"fragment_hash_algo,omitempty"` // 分片哈希算法，和 hash_algo 无关
+	Fragments        []ManifestFragment `json:"fragments"`
+	Erasure          *ErasureInfo       `json:"erasure,omitempty"`
+	Archive          string             `json:"archive,omitempty"`   // "tar" 表示上传的是 --dir 打包出的 tar
+	Offsets          []int64            `json:"offsets,omitempty"`   // --offsets / --cdc 的分片边界，此时 fragment_size 是最大分片的大小
+	Chunking         string             `json:"chunking,omitempty"`  // "cdc-gear" 表示边界按内容定义
+	AppTag           string             `json:"app_tag,omitempty"`   // --app-tag，和链上 submission 的 tags 一致
+	Compress         string             `json:"compress,omitempty"`  // 上传时的 --compress，各分片实际编码见 fragments[].codec
+	FileRoot         string             `json:"file_root,omitempty"` // 各分片 root 组成的 Merkle 根，见 combinedFileRoot
+
+	// --sign-manifest：上传者地址和对 manifest 的 ECDSA 签名，download 时校验
+	Signer    string `json:"signer,omitempty"`
+	Signature string `json:"signature,omitempty"`
+
+	// 只用于读取 v1 manifest，迁移后清空
+	LegacyOriginMD5 string `json:"origin_md5,omitempty"`
+}
+
+// ManifestFragment 单个分片在原始文件中的位置和对应的 root
+type ManifestFragment struct {
+	Index  int    `json:"index"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Root   string `json:"root"`
+	Parity bool   `json:"parity,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+
+	// upload-parts 上传的外部分片文件名，拼接顺序以 index 为准
+	Part string `json:"part,omitempty"`
+
+	// --content-addressed 时分片内容的 sha256（本地文件名 <content_hash>.frag）
+	ContentHash string `json:"content_hash,omitempty"`
+
+	// --pad-last 时存储的分片末尾补了这么多零字节，恢复时截掉
+	Padding int64 `json:"padding,omitempty"`
+
+	// --compress 时存储的是压缩数据：codec 为空表示未压缩，compressed_size 是链上的实际大小
+	Codec          string `json:"codec,omitempty"`
+	CompressedSize int64  `json:"compressed_size,omitempty"`
+
+	// --log-nonces 时提交这个分片前观察到的发送账户 pending nonce
+	Nonce *uint64 `json:"nonce,omitempty"`
+
+	// --fragment-retry-different-size 分段上传时的各段，按顺序拼接得到分片的上传数据
+	Subs []SubFragment `json:"subs,omitempty"`
+
+	// --skip-zero-fragments 时全零的分片没有上传，root 为空，下载时直接补零
+	Zero bool `json:"zero,omitempty"`
+
+	// 上传时使用的 indexer，下载时当前 indexer 找不到该 root 会回退到这里
+	Indexer string `json:"indexer,omitempty"`
+}
+
+// 合并同一个文件的多份 manifest：按分片序号合并，同一序号后面的 manifest 覆盖前面的（例如重传后 root 变了），
+// 但分片哈希不同说明不是同一份数据，直接报错
+func mergeManifests(ms []*Manifest) (*Manifest, error) {
+	if len(ms) == 1 {
+		return ms[0], nil
+	}
+	merged := *ms[0]
+	byIndex := map[int]ManifestFragment{}
+	for i, m := range ms {
+		if m.Namespace != merged.Namespace || m.FileName != merged.FileName || m.FileSize != merged.FileSize || m.OriginHash != merged.OriginHash ||
+			m.FragmentHashAlgo != merged.FragmentHashAlgo || (m.Erasure == nil) != (merged.Erasure == nil) {
+			return nil, fmt.Errorf("第 %d 个 manifest 描述的不是同一个文件（namespace、文件名、大小、哈希或分片方式不同）", i+1)
+		}
+		for _, frag := range m.Fragments {
+			if old, ok := byIndex[frag.Index]; ok && old.Hash != frag.Hash {
+				return nil, fmt.Errorf("分片 %d 在多个 manifest 中定义冲突: 哈希 %s 和 %s", frag.Index+1, old.Hash, frag.Hash)
+			}
+			byIndex[frag.Index] = frag
+		}
+	}
+
+	merged.Fragments = nil
+	for _, frag := range byIndex {
+		merged.Fragments = append(merged.Fragments, frag)
+	}
+	merged.sortFragments()
+	// 纠删码任意 k 片即可恢复；普通分片必须 0..n-1 齐全
+	if merged.Erasure == nil {
+		for i, frag := range merged.Fragments {
+			if frag.Index != i {
+				return nil, fmt.Errorf("合并后缺少分片 %d", i+1)
+			}
+		}
+	}
+	return &merged, nil
+}
+
+// 存储网络上该分片的实际字节数（含补零）
+func (f ManifestFragment) storedSize() int64 {
+	return f.Size + f.Padding
+}
+
+// 上传到存储网络的字节数：压缩过的分片是压缩后的大小，否则同 storedSize
+func (f ManifestFragment) uploadedSize() int64 {
+	if f.Codec != "" {
+		return f.CompressedSize
+	}
+	return f.storedSize()
+}
+
+// roots[i] 是 Index 为 i 的分片的 root
+func buildManifest(src string, originMD5 string, frags []Fragment, roots []string) *Manifest {
+	m := &Manifest{
+		Version:          ManifestVersion,
+		Namespace:        namespace,
+		FileName:         filepath.Base(src),
+		FragmentSize:     fragmentSize,
+		HashAlgo:         "md5",
+		OriginHash:       originMD5,
+		FragmentHashAlgo: fragmentHashAlgo,
+		AppTag:           appTag,
+	}
+	for _, frag := range frags {
+		m.Fragments = append(m.Fragments, ManifestFragment{
+			Index:   frag.Index,
+			Offset:  frag.Offset,
+			Size:    frag.Size,
+			Root:    roots[frag.Index],
+			Parity:  frag.Parity,
+			Hash:    frag.Hash,
+			Padding: frag.Padding,
+			Indexer: indexerURL,
+
+			ContentHash: frag.ContentHash,
+
+			Codec:          frag.Codec,
+			CompressedSize: frag.CompressedSize,
+			Subs:           subUploads.get(frag.Index),
+			Nonce:          nonces.get(frag.Index),
+			Zero:           frag.Zero,
+		})
+		m.FileSize += frag.Size
+	}
+	if compressCodec != "none" {
+		m.Compress = compressCodec
+	}
+	m.sortFragments()
+	return m
+}
+
+func saveManifest(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+func loadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if err := migrateManifest(&m); err != nil {
+		return nil, err
+	}
+	m.sortFragments()
+	if err := checkFragmentTiling(&m, false); err != nil {
+		return nil, fmt.Errorf("manifest 已损坏: %w", err)
+	}
+	return &m, nil
+}
+
+// ManifestSet 把多个文件的 manifest 合并成一个索引，按 namespace + 原始文件名区分
+type ManifestSet struct {
+	Version int         `json:"version"`
+	Files   []*Manifest `json:"files"`
+}
+
+// 读取多文件 manifest；文件不存在时返回空的 set
+func loadManifestSet(path string) (*ManifestSet, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &ManifestSet{Version: ManifestVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var set ManifestSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	if set.Files == nil {
+		return nil, fmt.Errorf("%s 不是多文件 manifest", path)
+	}
+	for _, m := range set.Files {
+		if err := migrateManifest(m); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.FileName, err)
+		}
+		m.sortFragments()
+		if err := checkFragmentTiling(m, false); err != nil {
+			return nil, fmt.Errorf("%s: manifest 已损坏: %w", m.FileName, err)
+		}
+	}
+	set.Version = ManifestVersion
+	return &set, nil
+}
+
+func (s *ManifestSet) Find(ns string, name string) *Manifest {
+	for _, m := range s.Files {
+		if m.Namespace == ns && m.FileName == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *ManifestSet) Append(m *Manifest) error {
+	if s.Find(m.Namespace, m.FileName) != nil {
+		return fmt.Errorf("manifest 中 namespace %q 下已存在同名文件: %s", m.Namespace, m.FileName)
+	}
+	s.Files = append(s.Files, m)
+	return nil
+}
+
+func saveManifestSet(path string, s *ManifestSet) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// 读出 path 里的所有文件 manifest：单文件 manifest 返回它自己，多文件 manifest 返回其中每个文件
+func loadManifestFiles(path string) ([]*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Files json.RawMessage `json:"files"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	if probe.Files == nil {
+		m, err := loadManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		return []*Manifest{m}, nil
+	}
+	set, err := loadManifestSet(path)
+	if err != nil {
+		return nil, err
+	}
+	return set.Files, nil
+}
+
+// download 使用：path 可以是单文件 manifest，也可以是多文件 manifest。
+// 只在 namespace 为 ns 的文件里选，name 为空时要求恰好只有一个文件
+func loadManifestFor(path string, ns string, name string) (*Manifest, error) {
+	files, err := loadManifestFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*Manifest
+	for _, m := range files {
+		if m.Namespace == ns && (name == "" || m.FileName == name) {
+			matched = append(matched, m)
+		}
+	}
+	switch len(matched) {
+	case 1:
+		return matched[0], nil
+	case 0:
+		if name == "" {
+			return nil, fmt.Errorf("manifest 中没有 namespace %q 的文件", ns)
+		}
+		return nil, fmt.Errorf("manifest 中没有 namespace %q 下的文件 %s", ns, name)
+	default:
+		return nil, fmt.Errorf("manifest 中 namespace %q 下有 %d 个文件，请用 --name 指定要恢复的文件", ns, len(matched))
+	}
+}
+
+// 把旧版本 manifest 升级到 ManifestVersion；比当前程序新的版本直接拒绝
+func migrateManifest(m *Manifest) error {
+	if m.Version > ManifestVersion {
+		return fmt.Errorf("manifest 版本 %d 高于当前程序支持的版本 %d，请升级程序后再试", m.Version, ManifestVersion)
+	}
+
+	// v1 没有 version 字段，读出来是 0
+	if m.Version <= 1 {
+		if m.HashAlgo == "" {
+			m.HashAlgo = "md5"
+		}
+		if m.OriginHash == "" {
+			m.OriginHash = m.LegacyOriginMD5
+		}
+		m.LegacyOriginMD5 = ""
+		m.Version = 2
+	}
+
+	// v2 -> ... -> v8 只是新增可选字段
+	if m.Version == 2 {
+		m.Version = 3
+	}
+	if m.Version == 3 {
+		m.Version = 4
+	}
+	if m.Version == 4 {
+		m.Version = 5
+	}
+	if m.Version == 5 {
+		m.Version = 6
+	}
+	if m.Version == 6 {
+		m.Version = 7
+	}
+	if m.Version == 7 {
+		m.Version = 8
+	}
+	return nil
+}
+
+// 检查分片（已按 Index 排序）的 offset / size 是否恰好铺满文件：相邻序号的分片必须首尾相接，
+// offset 大于上一个分片的结尾是空洞，小于是重叠，报出第一处不一致。
+// 单个 manifest 可能只是分批备份的一部分，complete 为 true 时才要求序号从 0 连续、总长等于文件大小。
+// 纠删码的分片是等长的 shard，不对应原始文件的区间，不检查
+func checkFragmentTiling(m *Manifest, complete bool) error {
+	if m.Erasure != nil {
+		return nil
+	}
+	for i, frag := range m.Fragments {
+		end := frag.Offset + frag.Size
+		switch {
+		case frag.Offset < 0 || frag.Size < 0:
+			return fmt.Errorf("分片 %d 的 offset %d / size %d 无效", frag.Index+1, frag.Offset, frag.Size)
+		case end > m.FileSize:
+			return fmt.Errorf("分片 %d 的区间 %d-%d 超出文件大小 %d", frag.Index+1, frag.Offset, end, m.FileSize)
+		}
+		if i == 0 {
+			if frag.Index == 0 && frag.Offset != 0 {
+				return fmt.Errorf("分片 1 的 offset 是 %d，应为 0", frag.Offset)
+			}
+			continue
+		}
+		prev := m.Fragments[i-1]
+		prevEnd := prev.Offset + prev.Size
+		switch {
+		case frag.Index == prev.Index:
+			return fmt.Errorf("分片 %d 重复出现", frag.Index+1)
+		case frag.Index != prev.Index+1:
+			if complete {
+				return fmt.Errorf("缺少分片 %d", prev.Index+2)
+			}
+		case frag.Offset > prevEnd:
+			return fmt.Errorf("分片 %d 和分片 %d 之间有 %d bytes 的空洞（%d-%d）", prev.Index+1, frag.Index+1, frag.Offset-prevEnd, prevEnd, frag.Offset)
+		case frag.Offset < prevEnd:
+			return fmt.Errorf("分片 %d 和分片 %d 重叠 %d bytes（分片 %d 从 %d 开始，分片 %d 到 %d 才结束）",
+				prev.Index+1, frag.Index+1, prevEnd-frag.Offset, frag.Index+1, frag.Offset, prev.Index+1, prevEnd)
+		}
+	}
+	if !complete {
+		return nil
+	}
+	if len(m.Fragments) > 0 && m.Fragments[0].Index != 0 {
+		return fmt.Errorf("缺少分片 1")
+	}
+	var total int64
+	if n := len(m.Fragments); n > 0 {
+		total = m.Fragments[n-1].Offset + m.Fragments[n-1].Size
+	}
+	if total != m.FileSize {
+		return fmt.Errorf("分片总长 %d 与文件大小 %d 不一致", total, m.FileSize)
+	}
+	return nil
+}
+
+func (m *Manifest) sortFragments() {
+	sort.Slice(m.Fragments, func(i, j int) bool { return m.Fragments[i].Index < m.Fragments[j].Index })
+}
+
+// 所有分片的 root，按分片 Index 排列（读取时已保证 Fragments 有序）；分段上传的分片依次列出各段的 root
+func (m *Manifest) Roots() []string {
+	roots := make([]string, 0, len(m.Fragments))
+	for _, f := range m.Fragments {
+		for _, p := range f.pieces() {
+			roots = append(roots, p.Root)
+		}
+	}
+	return roots
+}
+
+// 同一个 root 只能对应同样的内容：内容相同的分片共用 root 是正常的（例如全零分片），
+// 但如果两个分片记录的哈希或大小不同却指向同一个 root，说明 manifest 已损坏，恢复出来的文件一定是错的
+func checkDuplicateRoots(m *Manifest) error {
+	type seen struct {
+		index int
+		hash  string
+		size  int64
+	}
+	byRoot := map[string]seen{}
+	for _, frag := range m.Fragments {
+		pieces := frag.pieces()
+		for _, p := range pieces {
+			if p.Root == "" {
+				continue
+			}
+			cur := seen{index: frag.Index, size: p.Size}
+			if len(pieces) == 1 {
+				cur.hash = frag.Hash // 分段上传时分片哈希不对应单个 root
+			}
+			key := strings.ToLower(p.Root)
+			prev, ok := byRoot[key]
+			if !ok {
+				byRoot[key] = cur
+				continue
+			}
+			if prev.size != cur.size || (prev.hash != "" && cur.hash != "" && prev.hash != cur.hash) {
+				return fmt.Errorf("manifest 已损坏: 分片 %d 和分片 %d 的内容不同，却记录了同一个 root %s", prev.index+1, cur.index+1, p.Root)
+			}
+		}
+	}
+	return nil
+}
+
+// 分片在存储网络上对应的数据段：通常只有一段（整个分片），分段上传时是各个 subs
+func (f ManifestFragment) pieces() []SubFragment {
+	if f.Zero {
+		return nil // 没有上传
+	}
+	if len(f.Subs) > 0 {
+		return f.Subs
+	}
+	return []SubFragment{{Size: f.uploadedSize(), Root: f.Root}}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadManifestMigratesV1(t *testing.T) {
+	m, err := loadManifest(filepath.Join("testdata", "manifest_v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != ManifestVersion {
+		t.Fatalf("迁移后版本 %d，期望 %d", m.Version, ManifestVersion)
+	}
+	if m.HashAlgo != "md5" || m.OriginHash != "0cc175b9c0f1b6a831c399e269772661" || m.LegacyOriginMD5 != "" {
+		t.Fatalf("整文件哈希没有迁移: algo %q hash %q legacy %q", m.HashAlgo, m.OriginHash, m.LegacyOriginMD5)
+	}
+	if len(m.Fragments) != 2 || m.Fragments[1].Root != "0x"+strings.Repeat("2", 64) {
+		t.Fatalf("分片迁移错误: %+v", m.Fragments)
+	}
+}
+
+func TestLoadManifestRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.json")
+	data, _ := json.Marshal(Manifest{Version: ManifestVersion + 1, FileName: "x"})
+	os.WriteFile(path, data, 0644)
+	_, err := loadManifest(path)
+	if err == nil || !strings.Contains(err.Error(), "升级程序") {
+		t.Fatalf("期望提示升级程序，实际 %v", err)
+	}
+}
+
+// 两个文件追加到同一个多文件 manifest，再按文件名分别恢复
+func TestAppendToManifestAndRestoreEach(t *testing.T) {
+	dir := setupTest(t)
+	set := filepath.Join(dir, "backup.manifest.json")
+	contents := map[string][]byte{}
+	for i, name := range []string{"a.bin", "b.bin"} {
+		src := filepath.Join(dir, name)
+		contents[name] = writeTestFile(t, src, 2500+i*700, byte(i+1))
+		filePath, appendTo, manifestPath, fragmentSize = src, set, "", 1000
+		if err := run(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	loaded, err := loadManifestSet(set)
+	if err != nil || len(loaded.Files) != 2 {
+		t.Fatalf("多文件 manifest: %v, %v", loaded, err)
+	}
+	for name, want := range contents {
+		dlManifests, dlName = []string{set}, name
+		dlOutput = filepath.Join(dir, name+".out")
+		if err := restoreFromManifest(); err != nil {
+			t.Fatalf("恢复 %s: %v", name, err)
+		}
+		assertFileContent(t, dlOutput, want)
+	}
+
+	// 同名文件再追加一次应在上传前报错
+	filePath, appendTo, manifestPath = filepath.Join(dir, "a.bin"), set, ""
+	if err := run(); err == nil || exitCode(err) != ExitConfig {
+		t.Fatalf("期望同名文件冲突，实际 %v", err)
+	}
+}
+
+// 两个 namespace 把同名文件追加到同一个 manifest，互不冲突，下载时各自只看到自己的那份
+func TestAppendSameNameInTwoNamespaces(t *testing.T) {
+	dir := setupTest(t)
+	set := filepath.Join(dir, "shared.manifest.json")
+	contents := map[string][]byte{}
+	for i, ns := range []string{"team-a", "team-b"} {
+		src := filepath.Join(dir, ns, "data.bin")
+		os.Mkdir(filepath.Dir(src), 0755)
+		contents[ns] = writeTestFile(t, src, 2000+i*900, byte(i+5))
+		filePath, appendTo, manifestPath, fragmentSize, namespace = src, set, "", 1000, ns
+		if err := run(); err != nil {
+			t.Fatalf("%s: %v", ns, err)
+		}
+	}
+
+	loaded, err := loadManifestSet(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Files) != 2 || loaded.Files[0].Namespace != "team-a" || loaded.Files[1].Namespace != "team-b" {
+		t.Fatalf("manifest 没有按 namespace 记录两个文件: %+v", loaded.Files)
+	}
+	for ns, want := range contents {
+		m, err := loadManifestFor(set, ns, "data.bin")
+		if err != nil {
+			t.Fatalf("%s: %v", ns, err)
+		}
+		if m.FileSize != int64(len(want)) {
+			t.Fatalf("%s 取到了别的 namespace 的文件（%d bytes）", ns, m.FileSize)
+		}
+		namespace, dlManifests, dlName = ns, []string{set}, "data.bin"
+		dlOutput = filepath.Join(dir, ns+".out")
+		if err := restoreFromManifest(); err != nil {
+			t.Fatalf("恢复 %s: %v", ns, err)
+		}
+		assertFileContent(t, dlOutput, want)
+	}
+	if _, err := loadManifestFor(set, "", "data.bin"); err == nil {
+		t.Fatal("默认 namespace 下不应找到其他团队的文件")
+	}
+}
+
+// download --manifest a --manifest b：b 重传了分片 2（root 变了），按序号合并时 b 覆盖 a；
+// 同一序号哈希不同则报冲突
+func TestDownloadMergesManifestsLatestWins(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3000, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	older := *m
+	older.Fragments = append([]ManifestFragment(nil), m.Fragments...)
+	older.Fragments[1].Root = "0x" + strings.Repeat("0", 64) // 旧的 root 已不可下载
+	newer := *m
+	newer.Fragments = []ManifestFragment{m.Fragments[1]}
+	setFileRoot(&older)
+	setFileRoot(&newer)
+	a, b := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")
+	if err := saveManifest(a, &older); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveManifest(b, &newer); err != nil {
+		t.Fatal(err)
+	}
+
+	s := useCountingStorage()
+	dlManifests = []string{a, b}
+	dlOutput = filepath.Join(dir, "out.bin")
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dlOutput, data)
+	for _, root := range s.downloads {
+		if root == older.Fragments[1].Root {
+			t.Fatal("被覆盖的旧 root 不应再下载")
+		}
+	}
+
+	newer.Fragments[0].Hash = strings.Repeat("f", len(newer.Fragments[0].Hash))
+	if err := saveManifest(b, &newer); err != nil {
+		t.Fatal(err)
+	}
+	dlOutput = filepath.Join(dir, "conflict.bin")
+	if err := restoreFromManifest(); err == nil || !strings.Contains(err.Error(), "定义冲突") {
+		t.Fatalf("同一分片哈希不同时应报冲突，实际 %v", err)
+	}
+}
+
+// 分片 offset / size 没有恰好铺满文件：加载时就报出空洞或重叠的位置
+func TestLoadManifestRejectsGapAndOverlap(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 3500, 6)
+	m := uploadTestFile(t, path)
+	if err := checkManifestLayout(m); err != nil {
+		t.Fatalf("正常上传的 manifest 不应报错: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		modify func(m *Manifest)
+		want   string
+	}{
+		{"gap", func(m *Manifest) { m.Fragments[2].Offset += 100; m.Fragments[2].Size -= 100 }, "分片 2 和分片 3 之间有 100 bytes 的空洞（2000-2100）"},
+		{"overlap", func(m *Manifest) { m.Fragments[1].Offset -= 50; m.Fragments[1].Size += 50 }, "分片 1 和分片 2 重叠 50 bytes"},
+		{"past end", func(m *Manifest) { m.Fragments[3].Size += 1 }, "超出文件大小"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bad := *m
+			bad.Fragments = append([]ManifestFragment(nil), m.Fragments...)
+			c.modify(&bad)
+			p := filepath.Join(dir, c.name+".manifest.json")
+			if err := saveManifest(p, &bad); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadManifest(p); err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("期望错误包含 %q，实际 %v", c.want, err)
+			}
+		})
+	}
+
+	// 少了最后一个分片：单独加载可以（可能是分批备份），下载前的完整性检查报出总长不一致
+	partial := *m
+	partial.Fragments = m.Fragments[:3]
+	if err := checkFragmentTiling(&partial, false); err != nil {
+		t.Fatalf("不要求完整时不应报错: %v", err)
+	}
+	if err := checkManifestLayout(&partial); err == nil || !strings.Contains(err.Error(), "分片总长 3000 与文件大小 3500 不一致") {
+		t.Fatalf("期望总长不一致的错误，实际 %v", err)
+	}
+}
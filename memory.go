@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const minIOBuffer = 64 << 10 // 缓冲区再小吞吐会明显下降，先压缩缓冲区到这里，再减并发
+
+var maxMemory string // --max-memory，例如 512M / 2G
+
+// 解析带 K/M/G 后缀的字节数（1024 进制），不带后缀按字节
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1<<10, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1<<20, s[:len(s)-1]
+	case strings.HasSuffix(s, "G"):
+		mult, s = 1<<30, s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的大小: %q", s)
+	}
+	return n * mult, nil
+}
+
+// 在 limit 内确定缓冲区大小和 worker 数，保证 workers × buffer ≤ limit：
+// 先缩小缓冲区（不低于 minIOBuffer），还不够再减少并发
+func memoryPlan(limit int64, buffer int64, workers int) (int64, int) {
+	workers = max(workers, 1)
+	if buffer*int64(workers) <= limit {
+		return buffer, workers
+	}
+	buffer = max(limit/int64(workers), min(minIOBuffer, limit))
+	if buffer*int64(workers) > limit {
+		workers = int(max(limit/buffer, 1))
+	}
+	return buffer, workers
+}
+
+// 按 --max-memory 调整 --io-buffer 和各阶段并发数
+func applyMemoryLimit() error {
+	if maxMemory == "" {
+		return nil
+	}
+	limit, err := parseByteSize(maxMemory)
+	if err != nil {
+		return fmt.Errorf("--max-memory: %w", err)
+	}
+
+	workers := max(uploadWorkers(), downloadWorkers(), fragmentDownloadParallelism)
+	buffer, allowed := memoryPlan(limit, ioBufferSize, workers)
+	if buffer != ioBufferSize {
+		logrus.Warnf("--max-memory %s: --io-buffer 从 %d 调整为 %d bytes", maxMemory, ioBufferSize, buffer)
+		ioBufferSize = buffer
+	}
+	if allowed < workers {
+		logrus.Warnf("--max-memory %s: 并发数限制为 %d", maxMemory, allowed)
+		concurrency = min(max(concurrency, 1), allowed)
+		uploadConcurrency = min(uploadConcurrency, allowed)
+		downloadConcurrency = min(downloadConcurrency, allowed)
+		fragmentDownloadParallelism = min(fragmentDownloadParallelism, allowed)
+	}
+	if fragmentSize > limit {
+		logrus.Warnf("--fragment-size %d 单个分片就超过 --max-memory %s；SDK 上传等需要整片处理的步骤仍可能超出限制", fragmentSize, maxMemory)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestMemoryPlanRespectsLimit(t *testing.T) {
+	for _, tc := range []struct {
+		limit, buffer int64
+		workers       int
+		wantBuffer    int64
+		wantWorkers   int
+	}{
+		{limit: 64 << 20, buffer: 4 << 20, workers: 8, wantBuffer: 4 << 20, wantWorkers: 8}, // 本来就不超
+		{limit: 16 << 20, buffer: 4 << 20, workers: 8, wantBuffer: 2 << 20, wantWorkers: 8}, // 只缩缓冲区
+		{limit: 256 << 10, buffer: 4 << 20, workers: 8, wantBuffer: 64 << 10, wantWorkers: 4},
+		{limit: 32 << 10, buffer: 4 << 20, workers: 8, wantBuffer: 32 << 10, wantWorkers: 1}, // 上限比最小缓冲区还小
+	} {
+		buffer, workers := memoryPlan(tc.limit, tc.buffer, tc.workers)
+		if buffer != tc.wantBuffer || workers != tc.wantWorkers {
+			t.Errorf("memoryPlan(%d, %d, %d) = %d, %d，期望 %d, %d",
+				tc.limit, tc.buffer, tc.workers, buffer, workers, tc.wantBuffer, tc.wantWorkers)
+		}
+		if buffer*int64(workers) > tc.limit {
+			t.Errorf("memoryPlan(%d, %d, %d): %d × %d 超过上限", tc.limit, tc.buffer, tc.workers, workers, buffer)
+		}
+	}
+}
+
+func TestApplyMemoryLimitAdjustsFlags(t *testing.T) {
+	newRootCmd()
+	maxMemory = "256K"
+	ioBufferSize = 4 << 20
+	concurrency, uploadConcurrency = 8, 8
+	if err := applyMemoryLimit(); err != nil {
+		t.Fatal(err)
+	}
+	if ioBufferSize != minIOBuffer || uploadWorkers() != 4 || downloadWorkers() != 4 {
+		t.Fatalf("--max-memory 256K 应得到 64K 缓冲区、4 个 worker，实际 %d / %d / %d",
+			ioBufferSize, uploadWorkers(), downloadWorkers())
+	}
+
+	maxMemory = "lots"
+	if err := applyMemoryLimit(); err == nil {
+		t.Fatal("无效的 --max-memory 应报错")
+	}
+}
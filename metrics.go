@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	metricsAddr string      // --metrics-addr，例如 :9100；为空时不启动
+	stopMetrics = func() {} // main 退出前调用，关闭 metrics 服务
+)
+
+// 长时间运行时给 Prometheus 抓取的指标，手写文本格式，不引入 client_golang。
+// 分片级指标按 phase（upload / download）区分
+type runMetrics struct {
+	mu          sync.Mutex
+	fragments   map[string]int64   // 成功的分片数
+	failures    map[string]int64   // 重试耗尽后仍失败的分片数
+	retries     map[string]int64   // 重试次数
+	bytes       map[string]int64   // 传输字节数
+	durationSum map[string]float64 // 成功分片的耗时之和（秒）
+	phases      map[string]float64 // split / hash / upload / availability / restore / verify 各阶段耗时（秒）
+	concurrency int64
+}
+
+var metrics = &runMetrics{
+	fragments:   map[string]int64{},
+	failures:    map[string]int64{},
+	retries:     map[string]int64{},
+	bytes:       map[string]int64{},
+	durationSum: map[string]float64{},
+	phases:      map[string]float64{},
+}
+
+// withFragmentRetry 等处传入的中文阶段名转成指标标签
+func metricPhase(phase string) string {
+	switch phase {
+	case "上传":
+		return "upload"
+	case "下载":
+		return "download"
+	}
+	return phase
+}
+
+func (m *runMetrics) fragmentDone(phase string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fragments[metricPhase(phase)]++
+	m.durationSum[metricPhase(phase)] += d.Seconds()
+}
+
+func (m *runMetrics) fragmentFailed(phase string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[metricPhase(phase)]++
+}
+
+func (m *runMetrics) retried(phase string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries[metricPhase(phase)]++
+}
+
+func (m *runMetrics) addBytes(phase string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytes[metricPhase(phase)] += n
+}
+
+func (m *runMetrics) setConcurrency(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.concurrency = int64(n)
+}
+
+// 记录一个阶段的耗时，用法: defer metrics.phase("upload", time.Now())
+func (m *runMetrics) phase(name string, start time.Time) {
+	m.phaseTook(name, time.Since(start))
+}
+
+func (m *runMetrics) phaseTook(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.phases[name] = d.Seconds()
+}
+
+func (m *runMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	writeMetric(&b, "og_fragments_total", "counter", "成功上传/下载的分片数", m.fragments)
+	writeMetric(&b, "og_fragment_failures_total", "counter", "重试耗尽后仍失败的分片数", m.failures)
+	writeMetric(&b, "og_fragment_retries_total", "counter", "分片重试次数", m.retries)
+	writeMetric(&b, "og_bytes_total", "counter", "传输的分片字节数", m.bytes)
+	writeMetric(&b, "og_fragment_duration_seconds_sum", "counter", "成功分片的耗时之和", m.durationSum)
+	writeMetric(&b, "og_phase_duration_seconds", "gauge", "各阶段耗时", m.phases)
+	fmt.Fprintf(&b, "# HELP og_upload_concurrency 当前上传并发数\n# TYPE og_upload_concurrency gauge\nog_upload_concurrency %d\n", m.concurrency)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, b.String())
+}
+
+func writeMetric[V int64 | float64](b *strings.Builder, name, typ, help string, values map[string]V) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s{phase=%q} %v\n", name, k, values[k])
+	}
+}
+
+// 启动 /metrics 服务，返回的函数在运行结束时关闭服务
+func startMetricsServer(addr string) (func(), error) {
+	if addr == "" {
+		return func() {}, nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("--metrics-addr 监听失败: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logrus.Warnf("metrics 服务异常退出: %v", err)
+		}
+	}()
+	fmt.Printf("Prometheus 指标: http://%s/metrics\n", ln.Addr())
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 上传第 scrapeAt 个分片时抓一次 /metrics，模拟运行中的 Prometheus
+type scrapingStorage struct {
+	*countingStorage
+	url      string
+	scrapeAt int
+	scraped  string
+}
+
+func (s *scrapingStorage) Upload(path string) (string, error) {
+	if s.uploadCount() == s.scrapeAt {
+		s.scraped = scrapeMetrics(s.url)
+	}
+	return s.countingStorage.Upload(path)
+}
+
+func scrapeMetrics(url string) string {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "抓取失败: " + err.Error()
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// 取 name{phase="phase"} 的值，没有时返回 -1
+func metricValue(t *testing.T, text, name, phase string) float64 {
+	t.Helper()
+	re := regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(name+`{phase="`+phase+`"}`) + ` (\S+)$`)
+	match := re.FindStringSubmatch(text)
+	if match == nil {
+		return -1
+	}
+	v, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		t.Fatalf("%s{phase=%q} 不是数字: %q", name, phase, match[1])
+	}
+	return v
+}
+
+func TestMetricsEndpointDuringRun(t *testing.T) {
+	dir := setupTest(t)
+	metrics = &runMetrics{fragments: map[string]int64{}, failures: map[string]int64{}, retries: map[string]int64{},
+		bytes: map[string]int64{}, durationSum: map[string]float64{}, phases: map[string]float64{}}
+	var stop func()
+	out := captureStdout(t, func() {
+		var err error
+		if stop, err = startMetricsServer("127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer stop()
+	url := regexp.MustCompile(`http://\S+/metrics`).FindString(out)
+	if url == "" {
+		t.Fatalf("没有打印 metrics 地址: %q", out)
+	}
+
+	fragmentSize = 1000
+	concurrency = 1
+	s := &scrapingStorage{countingStorage: useCountingStorage(), url: url, scrapeAt: 2}
+	storage = s
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 4000, 0)
+	uploadTestFile(t, path)
+
+	// 运行中：前两个分片已上传，切分已结束，上传阶段还没结束
+	if got := metricValue(t, s.scraped, "og_fragments_total", "upload"); got != 2 {
+		t.Fatalf("运行中 og_fragments_total{phase=\"upload\"} = %v，期望 2\n%s", got, s.scraped)
+	}
+	if got := metricValue(t, s.scraped, "og_bytes_total", "upload"); got != 2000 {
+		t.Fatalf("运行中 og_bytes_total{phase=\"upload\"} = %v，期望 2000", got)
+	}
+	if !strings.Contains(s.scraped, "\nog_upload_concurrency 1\n") {
+		t.Fatalf("运行中缺少 og_upload_concurrency 1:\n%s", s.scraped)
+	}
+	if metricValue(t, s.scraped, "og_phase_duration_seconds", "split") < 0 {
+		t.Fatalf("开始上传时应已记录 split 阶段:\n%s", s.scraped)
+	}
+	if metricValue(t, s.scraped, "og_phase_duration_seconds", "upload") >= 0 {
+		t.Fatalf("上传阶段还没结束就记录了耗时:\n%s", s.scraped)
+	}
+
+	final := scrapeMetrics(url)
+	if got := metricValue(t, final, "og_fragments_total", "upload"); got != 4 {
+		t.Fatalf("结束后 og_fragments_total{phase=\"upload\"} = %v，期望 4", got)
+	}
+	if got := metricValue(t, final, "og_fragments_total", "download"); got != 4 {
+		t.Fatalf("结束后 og_fragments_total{phase=\"download\"} = %v，期望 4", got)
+	}
+	for _, phase := range []string{"split", "upload", "restore", "verify"} {
+		if metricValue(t, final, "og_phase_duration_seconds", phase) < 0 {
+			t.Fatalf("结束后缺少 og_phase_duration_seconds{phase=%q}:\n%s", phase, final)
+		}
+	}
+}
+
+// upload 阶段只含分片上传，不含之后的 manifest 写入和可用性等待
+func TestUploadPhaseExcludesAvailabilityWait(t *testing.T) {
+	dir := setupTest(t)
+	metrics = &runMetrics{fragments: map[string]int64{}, failures: map[string]int64{}, retries: map[string]int64{},
+		bytes: map[string]int64{}, durationSum: map[string]float64{}, phases: map[string]float64{}}
+	fragmentSize = 1000
+	storage = slowAvailableStorage{fakeStorage: testStore(), delay: 300 * time.Millisecond}
+	waitAvailable = 5 * time.Second
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 2000, 0)
+	uploadTestFile(t, path)
+
+	upload, wait := metrics.phases["upload"], metrics.phases["availability"]
+	if wait < 0.3 {
+		t.Fatalf("availability 阶段 %vs，期望至少包含 0.3s 的可用性等待", wait)
+	}
+	if upload >= 0.3 {
+		t.Fatalf("upload 阶段 %vs 包含了可用性等待", upload)
+	}
+}
+
+// Available 在 delay 之后才返回 true
+type slowAvailableStorage struct {
+	fakeStorage
+	delay time.Duration
+}
+
+func (s slowAvailableStorage) Available(root string) (bool, error) {
+	time.Sleep(s.delay)
+	return s.fakeStorage.Available(root)
+}
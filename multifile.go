@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	filePaths       []string // --file 可以重复；多个文件时所有分片共用一个 worker 池上传
+	allowDuplicates bool     // 同一路径重复给出时去重（只保留第一次），否则报错
+)
+
+// 按给出的顺序去掉重复的 --file（按绝对路径比较），保证 manifest 里文件的顺序确定；
+// 没有 --allow-duplicates 时重复视为参数错误
+func dedupeFilePaths(paths []string) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, p := range paths {
+		key, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key] {
+			if !allowDuplicates {
+				return nil, fmt.Errorf("--file %s 重复指定（加 --allow-duplicates 自动去重）", p)
+			}
+			logrus.Warnf("--file %s 重复指定，忽略", p)
+			continue
+		}
+		seen[key] = true
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+type preparedFile struct {
+	Path      string
+	Frags     []Fragment
+	OriginMD5 string
+}
+
+// 多文件备份：各自切分后把所有分片放进同一个队列，由同一个 worker 池上传，
+// 文件大小不一时也不会因为逐个文件串行而让 worker 空闲。结果写成一个多文件 manifest
+func runMulti(paths []string) error {
+	defer slowReport.print()
+	defer printRetrySummary()
+
+	defaultCompressOff(true)
+	if dirPath != "" || erasureSpec != "" || resumeUpload || resumeManifest != "" || concurrencyAuto || offsetsPath != "" || cdcMode || indexCSVPath != "" || dryRun || compressCodec != "none" || retrySubSize != "" || sinceManifest != "" || logNonces || waitAvailable > 0 || pipelineUpload || sha256SumsPath != "" || skipZeroFragments || publishManifest || dedupeAcross {
+		return configError(fmt.Errorf("多个 --file 时不支持 --dir / --erasure / --resume / --resume-manifest / --concurrency-auto / --offsets / --cdc / --index-csv / --dry-run / --compress / --fragment-retry-different-size / --since-manifest / --log-nonces / --wait-available / --pipeline / --sha256sums / --skip-zero-fragments / --publish-manifest / --dedupe-across-manifests"))
+	}
+	if manifestPath == stdoutManifest {
+		return configError(fmt.Errorf("多个 --file 时不支持 --manifest -"))
+	}
+	if manifestPath == "" && appendTo == "" {
+		return configError(fmt.Errorf("多个 --file 时需要用 --manifest 或 --append-to-manifest 指定合并后的 manifest"))
+	}
+	if _, err := newFragmentHasher(fragmentHashAlgo); err != nil {
+		return configError(err)
+	}
+
+	target := manifestPath
+	if appendTo != "" {
+		target = appendTo
+	}
+	unlock, err := lockRunTargets(target)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	set := &ManifestSet{Version: ManifestVersion}
+	if appendTo != "" {
+		if set, err = loadManifestSet(appendTo); err != nil {
+			return configError(fmt.Errorf("读取 manifest 失败: %w", err))
+		}
+	}
+
+	// 文件名是 manifest 里的 key，上传前先查重
+	seen := map[string]bool{}
+	for _, p := range paths {
+		name := filepath.Base(p)
+		if seen[name] || set.Find(namespace, name) != nil {
+			return configError(fmt.Errorf("文件名重复: %s", name))
+		}
+		seen[name] = true
+		if err := checkFragmentCount(p, fragmentSize, maxFragments); err != nil {
+			return configError(err)
+		}
+	}
+
+	tmpDir, err := makeTempDir("0g-split-*")
+	if err != nil {
+		return uploadError(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 1. 逐个切分，每个文件一个子目录
+	files := make([]*preparedFile, len(paths))
+	var all []Fragment
+	counts := map[string]int{} // 源文件 -> 分片数，进度行显示 i/total
+	for i, p := range paths {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("file_%03d", i))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			return uploadError(err)
+		}
+		h, sum := newOriginHash(p)
+		frags, err := splitFile(p, dir, fragmentSize, fragmentHashAlgo, h)
+		if err != nil {
+			return uploadError(fmt.Errorf("切分 %s 失败: %w", p, err))
+		}
+		files[i] = &preparedFile{Path: p, Frags: frags, OriginMD5: sum()}
+		if files[i].OriginMD5 != "" {
+			storeCachedMD5(p, files[i].OriginMD5)
+		}
+		if contentAddressed {
+			// 放进共享的 tmpDir，不同文件间内容相同的分片共用一个磁盘文件
+			if err := contentAddress(frags, tmpDir); err != nil {
+				return uploadError(err)
+			}
+		}
+		for j := range frags {
+			frags[j].Source = p
+		}
+		all = append(all, frags...)
+		counts[p] = len(frags)
+		fmt.Printf("%s: %d 个分片\n", p, len(frags))
+	}
+
+	// 2. 共用一个 worker 池上传全部分片
+	if err := preflightUpload(all); err != nil {
+		return err
+	}
+	workers := uploadWorkers()
+	metrics.setConcurrency(workers)
+	fmt.Printf("\n共 %d 个文件、%d 个分片，使用 %d 个 worker 上传\n", len(files), len(all), workers)
+	results := runPool(all, workers, func(frag Fragment) (string, error) {
+		name := filepath.Base(frag.Source)
+		fmt.Printf("\n正在上传 %s 的分片 %d/%d\n", name, frag.Index+1, counts[frag.Source])
+
+		start := time.Now()
+		root, err := withFragmentRetry("上传", frag.Index, frag.Size, func() (string, error) {
+			return storage.Upload(frag.Path)
+		}, nil)
+		if err != nil {
+			webhook.fragmentUploaded(frag, "failed", "", nil, err)
+			return "", fmt.Errorf("上传 %s 的分片 %d 失败: %w", name, frag.Index+1, err)
+		}
+		webhook.fragmentUploaded(frag, "uploaded", root, nil, nil)
+		slowReport.record("上传", frag.Index, root, time.Since(start))
+		metrics.addBytes("上传", frag.Size)
+		fmt.Printf("%s 分片 %d 上传成功，root = %s\n", name, frag.Index+1, root)
+		return root, nil
+	})
+	for _, r := range results {
+		if r.Err != nil {
+			return uploadError(r.Err)
+		}
+		if !r.Done {
+			return uploadError(fmt.Errorf("--fail-fast: 有分片上传失败，其余分片未上传"))
+		}
+	}
+
+	// 3. 合并写入一个 manifest
+	var manifests []*Manifest
+	pos := 0
+	for _, f := range files {
+		roots := make([]string, len(f.Frags))
+		for _, frag := range f.Frags {
+			roots[frag.Index] = results[pos].Root
+			pos++
+		}
+		m := buildManifest(f.Path, f.OriginMD5, f.Frags, roots)
+		if noVerify {
+			m.HashAlgo = ""
+		}
+		setFileRoot(m)
+		if err := maybeSignManifest(m); err != nil {
+			return uploadError(err)
+		}
+		if err := set.Append(m); err != nil {
+			return uploadError(err)
+		}
+		manifests = append(manifests, m)
+	}
+	if err := saveManifestSet(target, set); err != nil {
+		return uploadError(fmt.Errorf("写 manifest 失败: %w", err))
+	}
+	fmt.Printf("\n=== 所有文件上传完成 ===\nmanifest 已写入: %s（共 %d 个文件）\n", target, len(set.Files))
+
+	// 4. 逐个恢复并校验
+	var firstErr error
+	for i, m := range manifests {
+		restored := files[i].Path + ".restored"
+		fmt.Printf("\n恢复 %s\n", m.FileName)
+		err := downloadError(restoreFile(m, restored))
+		if err == nil {
+			err = verifyMD5(restored, m.OriginHash)
+		}
+		if err = runRestoreHooks(restored, err); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录同时在上传的分片来自哪些文件
+type overlapStorage struct {
+	*countingStorage
+	mu       sync.Mutex
+	inFlight map[string]int // 本地分片所在目录（每个文件一个）-> 正在上传的数量
+	maxFiles int            // 同一时刻最多有几个文件的分片在上传
+}
+
+func (s *overlapStorage) Upload(path string) (string, error) {
+	dir := filepath.Dir(path)
+	s.mu.Lock()
+	s.inFlight[dir]++
+	s.maxFiles = max(s.maxFiles, len(s.inFlight))
+	s.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	s.mu.Lock()
+	if s.inFlight[dir]--; s.inFlight[dir] == 0 {
+		delete(s.inFlight, dir)
+	}
+	s.mu.Unlock()
+	return s.countingStorage.Upload(path)
+}
+
+// 三个大小不同的文件共用一个 worker 池：分片跨文件并发上传，合并的 manifest 里每个文件都能单独恢复
+func TestRunMultiSharedPool(t *testing.T) {
+	dir := setupTest(t)
+	s := &overlapStorage{countingStorage: useCountingStorage(), inFlight: map[string]int{}}
+	storage = s
+	fragmentSize, concurrency = 1000, 4
+	manifestPath = filepath.Join(dir, "all.manifest.json")
+
+	var paths []string
+	contents := map[string][]byte{}
+	for i, size := range []int{500, 2500, 4200} {
+		p := filepath.Join(dir, []string{"a.bin", "b.bin", "c.bin"}[i])
+		contents[filepath.Base(p)] = writeTestFile(t, p, size, byte(i))
+		paths = append(paths, p)
+	}
+	if err := runMulti(paths); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.uploadCount() != 1+3+5 {
+		t.Fatalf("期望上传 9 个分片，实际 %d 个", s.uploadCount())
+	}
+	if s.maxFiles < 2 {
+		t.Fatal("分片没有跨文件并发上传")
+	}
+	set, err := loadManifestSet(manifestPath)
+	if err != nil || len(set.Files) != 3 {
+		t.Fatalf("合并的 manifest: %v", err)
+	}
+	for i, m := range set.Files {
+		if m.FileName != filepath.Base(paths[i]) {
+			t.Fatalf("manifest 中第 %d 个文件是 %s，期望 %s", i, m.FileName, filepath.Base(paths[i]))
+		}
+		out := filepath.Join(dir, m.FileName+".out")
+		if err := restoreFile(m, out); err != nil {
+			t.Fatal(err)
+		}
+		assertFileContent(t, out, contents[m.FileName])
+		if err := verifyMD5(out, m.OriginHash); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunMultiRequiresManifest(t *testing.T) {
+	dir := setupTest(t)
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTestFile(t, a, 10, 0)
+	writeTestFile(t, b, 10, 1)
+	err := runMulti([]string{a, b})
+	if err == nil || exitCode(err) != ExitConfig || !strings.Contains(err.Error(), "--manifest") {
+		t.Fatalf("没有 --manifest 时应报参数错误，实际 %v", err)
+	}
+}
+
+// 进度行带上每个文件自己的 i/total；--content-addressed 时不同文件间内容相同的分片共用一个本地文件
+func TestRunMultiContentAddressedProgress(t *testing.T) {
+	dir := setupTest(t)
+	contentAddressed = true
+	fragmentSize = 1000
+	manifestPath = filepath.Join(dir, "all.manifest.json")
+	a, b := filepath.Join(dir, "a.bin"), filepath.Join(dir, "b.bin")
+	writeBlockFile(t, a, 1, 2, 3)
+	want := writeBlockFile(t, b, 1, 2)
+
+	var err error
+	out := captureStdout(t, func() { err = runMulti([]string{a, b}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"正在上传 a.bin 的分片 3/3", "正在上传 b.bin 的分片 2/2"} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("输出中缺少 %q:\n%s", line, out)
+		}
+	}
+
+	set, err := loadManifestSet(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ma, mb := set.Files[0], set.Files[1]
+	for i := range mb.Fragments {
+		if mb.Fragments[i].ContentHash != ma.Fragments[i].ContentHash || mb.Fragments[i].Root != ma.Fragments[i].Root {
+			t.Fatalf("两个文件的分片 %d 内容相同，content_hash / root 应一致", i+1)
+		}
+	}
+	restored := filepath.Join(dir, "b.out")
+	if err := restoreFile(mb, restored); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, restored, want)
+}
+
+// 重复的 --file（写法不同但是同一路径）：默认报参数错误；--allow-duplicates 时按第一次出现的顺序去重，manifest 中文件顺序确定
+func TestDuplicateFilePaths(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	manifestPath = filepath.Join(dir, "all.manifest.json")
+	a, b := filepath.Join(dir, "a.bin"), filepath.Join(dir, "b.bin")
+	writeTestFile(t, a, 1500, 1)
+	writeTestFile(t, b, 2500, 2)
+	given := []string{b, a, filepath.Join(dir, "x", "..", "b.bin"), a}
+
+	if _, err := dedupeFilePaths(given); err == nil || !strings.Contains(err.Error(), "重复指定") {
+		t.Fatalf("没有 --allow-duplicates 时重复的 --file 应报错，实际 %v", err)
+	}
+
+	allowDuplicates = true
+	paths, err := dedupeFilePaths(given)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, ",") != b+","+a {
+		t.Fatalf("应按第一次出现的顺序保留 b、a，实际 %v", paths)
+	}
+	counter := useCountingStorage()
+	if err := runMulti(paths); err != nil {
+		t.Fatal(err)
+	}
+	if n := counter.uploadCount(); n != 3+2 {
+		t.Fatalf("每个文件只应上传一次，共 5 个分片，实际 %d 个", n)
+	}
+	set, err := loadManifestSet(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Files) != 2 || set.Files[0].FileName != "b.bin" || set.Files[1].FileName != "a.bin" {
+		t.Fatalf("manifest 中的文件应依次是 b.bin、a.bin")
+	}
+}
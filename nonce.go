@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/sirupsen/logrus"
+)
+
+// --log-nonces：排查 nonce 卡住时用。SDK 的 upload 命令自己取 nonce 发交易、不返回交易信息，
+// 这里在每次提交前查询发送账户的 pending nonce——串行上传时就是这笔 submission 使用的 nonce，
+// 并发上传时多个分片可能观察到同一个值
+var logNonces bool
+
+type nonceLog struct {
+	once   sync.Once
+	sender string
+	err    error
+
+	mu     sync.Mutex
+	nonces map[int]uint64
+}
+
+var nonces = &nonceLog{nonces: map[int]uint64{}}
+
+// 提交分片前调用：第一次调用时记录发送地址，之后每次查询并记录 pending nonce；查询失败只告警
+func (l *nonceLog) observe(index int) {
+	if !logNonces {
+		return
+	}
+	if _, ok := storage.(sdkStorage); !ok {
+		return
+	}
+	l.once.Do(func() {
+		prv, err := crypto.HexToECDSA(strings.TrimPrefix(privateKey, "0x"))
+		if err != nil {
+			l.err = fmt.Errorf("私钥无效: %w", err)
+			return
+		}
+		l.sender = crypto.PubkeyToAddress(prv.PublicKey).Hex()
+		fmt.Printf("交易发送地址: %s\n", l.sender)
+		if uploadWorkers() > 1 {
+			logrus.Warn("--log-nonces: 并发上传时记录的是提交前观察到的 pending nonce，可能和实际使用的不同")
+		}
+	})
+	if l.err != nil {
+		logrus.Warnf("--log-nonces: %v", l.err)
+		return
+	}
+
+	var raw string
+	if err := rpcCall(rpcURL, "eth_getTransactionCount", []interface{}{l.sender, "pending"}, &raw); err != nil {
+		logrus.Warnf("查询分片 %d 提交前的 nonce 失败: %v", index+1, err)
+		return
+	}
+	n, err := parseQuantity(raw)
+	if err != nil || !n.IsUint64() {
+		logrus.Warnf("分片 %d 提交前的 nonce 无效: %q", index+1, raw)
+		return
+	}
+	fmt.Printf("分片 %d 提交交易，发送地址 %s，nonce %d\n", index+1, l.sender, n.Uint64())
+	l.mu.Lock()
+	l.nonces[index] = n.Uint64() // 重试时以最后一次提交为准
+	l.mu.Unlock()
+}
+
+func (l *nonceLog) get(index int) *uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n, ok := l.nonces[index]
+	if !ok {
+		return nil
+	}
+	return &n
+}
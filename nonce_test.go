@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// --log-nonces：第一次提交前打印一次发送地址，之后每个分片打印并记录提交前的 nonce，写进 manifest
+func TestLogNoncesPerFragment(t *testing.T) {
+	chain := setupPreflight(t)
+	chain.mu.Lock()
+	chain.nonce = 41
+	chain.mu.Unlock()
+	logNonces = true
+	frags := []Fragment{{Index: 0, Size: 1000}, {Index: 1, Size: 1000}, {Index: 2, Size: 500}}
+
+	out := captureStdout(t, func() {
+		for _, frag := range frags {
+			nonces.observe(frag.Index)
+		}
+	})
+	sender := keyAddress(t, testKey)
+	if strings.Count(out, "交易发送地址: "+sender) != 1 {
+		t.Fatalf("发送地址应只打印一次:\n%s", out)
+	}
+	for i, want := range []string{"分片 1 提交交易，发送地址 " + sender + "，nonce 41", "分片 2 提交交易，发送地址 " + sender + "，nonce 42", "分片 3 提交交易，发送地址 " + sender + "，nonce 43"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("缺少分片 %d 的 nonce 日志 %q:\n%s", i+1, want, out)
+		}
+	}
+
+	m := buildManifest("data.bin", "", frags, []string{"0x01", "0x02", "0x03"})
+	for i, frag := range m.Fragments {
+		if frag.Nonce == nil || *frag.Nonce != uint64(41+i) {
+			t.Fatalf("manifest 中分片 %d 的 nonce 为 %v，期望 %d", i, frag.Nonce, 41+i)
+		}
+	}
+}
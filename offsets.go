@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var offsetsPath string // --offsets：按给定字节边界切分，代替 --fragment-size
+
+// 读取 --offsets 文件：JSON 整数数组，列出全部分片边界，
+// 必须从 0 开始、严格递增、以文件大小结束，例如 [0, 1048576, 5242880, 8388608]
+func loadOffsets(path string, fileSize int64) ([]int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var bounds []int64
+	if err := json.Unmarshal(data, &bounds); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	if err := validateOffsets(bounds, fileSize); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return bounds, nil
+}
+
+func validateOffsets(bounds []int64, fileSize int64) error {
+	if len(bounds) < 2 {
+		return fmt.Errorf("边界至少要有 0 和文件大小两个值")
+	}
+	if bounds[0] != 0 {
+		return fmt.Errorf("第一个边界必须是 0，实际为 %d", bounds[0])
+	}
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return fmt.Errorf("边界必须严格递增: 第 %d 个 %d 不大于前一个 %d", i+1, bounds[i], bounds[i-1])
+		}
+		if bounds[i] > fileSize {
+			return fmt.Errorf("边界 %d 超出文件大小 %d", bounds[i], fileSize)
+		}
+	}
+	if last := bounds[len(bounds)-1]; last != fileSize {
+		return fmt.Errorf("最后一个边界 %d 没有覆盖到文件末尾 %d", last, fileSize)
+	}
+	return nil
+}
+
+// 按边界切分，分片大小可以各不相同；逐片流式复制，不需要整片放进内存
+func splitAtOffsets(src string, dstDir string, bounds []int64, hashAlgo string, origin io.Writer) ([]Fragment, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// --since-manifest 时边界从已覆盖部分的末尾开始
+	if _, err := f.Seek(bounds[0], io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var files []Fragment
+	for i := 0; i+1 < len(bounds); i++ {
+		size := bounds[i+1] - bounds[i]
+		h, err := newFragmentHasher(hashAlgo)
+		if err != nil {
+			return nil, err
+		}
+
+		fragPath := filepath.Join(dstDir, fragmentFileName(i, len(bounds)-1))
+		out, err := os.Create(fragPath)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.CopyN(io.MultiWriter(out, h, origin), ctxReader{runCtx, f}, size)
+		out.Close()
+		if err != nil {
+			return nil, fmt.Errorf("切分分片 %d 失败: %w", i+1, err)
+		}
+		files = append(files, Fragment{Index: i, Path: fragPath, Offset: bounds[i], Size: size, Hash: hex.EncodeToString(h.Sum(nil))})
+	}
+	return orderFragments(files)
+}
+
+func boundsSource() string {
+	if cdcMode {
+		return "--cdc"
+	}
+	if sinceManifest != "" {
+		return "--since-manifest 之后的新增部分"
+	}
+	return offsetsPath
+}
+
+// 最大的分片，作为传给 SDK 的 --fragment-size，保证 SDK 不会再二次切分
+func largestFragment(bounds []int64) int64 {
+	var largest int64
+	for i := 0; i+1 < len(bounds); i++ {
+		largest = max(largest, bounds[i+1]-bounds[i])
+	}
+	return largest
+}
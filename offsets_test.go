@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOffsetsUploadRoundTrip(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "records.bin")
+	data := writeTestFile(t, path, 3000, 2)
+	offsetsPath = filepath.Join(dir, "offsets.json")
+	os.WriteFile(offsetsPath, []byte("[0, 100, 1900, 3000]"), 0644)
+	m := uploadTestFile(t, path)
+
+	if len(m.Fragments) != 3 || m.Fragments[1].Offset != 100 || m.Fragments[1].Size != 1800 {
+		t.Fatalf("没有按边界切分: %+v", m.Fragments)
+	}
+	if len(m.Offsets) != 4 || m.Offsets[2] != 1900 || m.FragmentSize != 1800 {
+		t.Fatalf("manifest 没有记录边界: offsets %v，fragment_size %d", m.Offsets, m.FragmentSize)
+	}
+	assertFileContent(t, path+".restored", data)
+}
+
+func TestValidateOffsets(t *testing.T) {
+	for _, c := range []struct {
+		bounds []int64
+		want   string
+	}{
+		{[]int64{0, 500, 400, 1000}, "严格递增"},
+		{[]int64{0, 500, 500, 1000}, "严格递增"},
+		{[]int64{0, 500, 900}, "没有覆盖到文件末尾"},
+		{[]int64{0, 500, 1200}, "超出文件大小"},
+		{[]int64{10, 1000}, "第一个边界必须是 0"},
+		{[]int64{0}, "至少"},
+	} {
+		err := validateOffsets(c.bounds, 1000)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%v: 期望包含 %q 的错误，实际 %v", c.bounds, c.want, err)
+		}
+	}
+	if err := validateOffsets([]int64{0, 1, 999, 1000}, 1000); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 分片的上传顺序策略：输入待上传的分片，返回派发顺序。roots 按 Index 回填，顺序不影响结果
+type orderStrategy func(frags []Fragment) []Fragment
+
+var (
+	uploadOrder   string // --upload-order
+	priorityFirst bool   // --priority-first，等同 --upload-order head-tail
+)
+
+var orderStrategies = map[string]orderStrategy{
+	"sequential": func(frags []Fragment) []Fragment { return frags },
+	// 先传首尾分片，部分上传完成时文件头尾（格式头、索引等）已经可用
+	"head-tail": headTailOrder,
+	// 先传小分片，尽快让更多分片可用
+	"smallest-first": func(frags []Fragment) []Fragment {
+		out := append([]Fragment(nil), frags...)
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Size < out[j].Size })
+		return out
+	},
+}
+
+func headTailOrder(frags []Fragment) []Fragment {
+	if len(frags) <= 2 {
+		return frags
+	}
+	out := make([]Fragment, 0, len(frags))
+	out = append(out, frags[0], frags[len(frags)-1])
+	return append(out, frags[1:len(frags)-1]...)
+}
+
+func resolveOrder() (orderStrategy, error) {
+	name := uploadOrder
+	if priorityFirst {
+		if name != "" && name != "head-tail" {
+			return nil, fmt.Errorf("--priority-first 和 --upload-order %s 冲突", name)
+		}
+		name = "head-tail"
+	}
+	if name == "" {
+		name = "sequential"
+	}
+	s, ok := orderStrategies[name]
+	if !ok {
+		names := make([]string, 0, len(orderStrategies))
+		for n := range orderStrategies {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("未知的 --upload-order: %q（可选 %s）", name, strings.Join(names, " / "))
+	}
+	return s, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// 单 worker 时派发顺序就是上传顺序：按策略排出的 Index 依次上传
+func TestUploadOrderStrategies(t *testing.T) {
+	// 注册一个测试用的策略，验证策略表是可扩展的
+	orderStrategies["reverse"] = func(frags []Fragment) []Fragment {
+		out := make([]Fragment, len(frags))
+		for i, frag := range frags {
+			out[len(frags)-1-i] = frag
+		}
+		return out
+	}
+	defer delete(orderStrategies, "reverse")
+
+	for _, tc := range []struct {
+		order    string
+		priority bool
+		want     []int
+	}{
+		{order: "", want: []int{0, 1, 2, 3, 4}},
+		{order: "head-tail", want: []int{0, 4, 1, 2, 3}},
+		{priority: true, want: []int{0, 4, 1, 2, 3}},
+		{order: "reverse", want: []int{4, 3, 2, 1, 0}},
+	} {
+		dir := setupTest(t)
+		fragmentSize = 1000
+		concurrency = 1
+		uploadOrder, priorityFirst = tc.order, tc.priority
+		path := filepath.Join(dir, "data.bin")
+		writeTestFile(t, path, 5000, 9)
+		s := useCountingStorage()
+		m := uploadTestFile(t, path)
+
+		index := map[string]int{}
+		for _, frag := range m.Fragments {
+			index[frag.Root] = frag.Index
+		}
+		var got []int
+		for _, root := range s.uploads {
+			got = append(got, index[root])
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Fatalf("--upload-order %q --priority-first=%v: 上传顺序 %v，期望 %v", tc.order, tc.priority, got, tc.want)
+		}
+	}
+}
+
+func TestResolveOrderErrors(t *testing.T) {
+	newRootCmd()
+	uploadOrder = "random"
+	if _, err := resolveOrder(); err == nil {
+		t.Fatal("未知的策略应报错")
+	}
+	uploadOrder, priorityFirst = "smallest-first", true
+	if _, err := resolveOrder(); err == nil {
+		t.Fatal("--priority-first 和其它 --upload-order 冲突时应报错")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --parallel-verify 逐分片并行校验和整文件 MD5 结论一致：完整的文件都通过，改动一个字节都失败
+func TestParallelVerifyMatchesSerialMD5(t *testing.T) {
+	manifest, data := uploadForDownload(t, 7300, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FragmentHashAlgo == "" || !allFragmentsHashed(m) {
+		t.Fatal("上传应记录分片哈希")
+	}
+	out := filepath.Join(t.TempDir(), "out.bin")
+	if err := restoreFile(m, out); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, out, data)
+
+	var serialErr, parallelErr error
+	captureStdout(t, func() {
+		serialErr = verifyMD5(out, m.OriginHash)
+		parallelErr = verifyFragmentsParallel(out, m, 4)
+	})
+	if serialErr != nil || parallelErr != nil {
+		t.Fatalf("完整文件应都通过：整文件 MD5 %v，并行逐分片 %v", serialErr, parallelErr)
+	}
+
+	// 分片 6 中间改一个字节：两种校验都失败，并行校验指出是哪个分片
+	corrupt := append([]byte(nil), data...)
+	corrupt[5500] ^= 0xff
+	if err := os.WriteFile(out, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() { serialErr = verifyMD5(out, m.OriginHash) })
+	printed := captureStdout(t, func() { parallelErr = verifyFragmentsParallel(out, m, 4) })
+	if serialErr == nil || parallelErr == nil || exitCode(parallelErr) != ExitVerify {
+		t.Fatalf("损坏的文件应都失败：整文件 MD5 %v，并行逐分片 %v", serialErr, parallelErr)
+	}
+	if !strings.Contains(printed, "分片 06 校验失败") || strings.Count(printed, "校验失败") != 1 {
+		t.Fatalf("应只报告分片 06 失败:\n%s", printed)
+	}
+
+	// 文件被截短：分片不再覆盖整个文件，同样失败
+	if err := os.WriteFile(out, data[:7000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() { parallelErr = verifyFragmentsParallel(out, m, 4) })
+	if parallelErr == nil {
+		t.Fatal("截短的文件应校验失败")
+	}
+}
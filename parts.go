@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	partsDir      string
+	partsGlob     string
+	partsName     string
+	partsManifest string
+)
+
+// 上传已经用 split(1) 等工具切好的分片：匹配的文件按文件名排序依次作为分片，
+// 拼接顺序显式记在 manifest 里（index 和 part 文件名），之后可以照常用 download 恢复
+func newUploadPartsCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "upload-parts",
+		Short: "把目录中已切好的分片文件按文件名顺序上传并生成 manifest",
+		Example: `  split -b 400M big.iso part_
+  split-upload-4g upload-parts --key <私钥> --parts-dir . --glob 'part_*' --name big.iso`,
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			return runUploadParts()
+		},
+	}
+
+	c.Flags().StringVar(&privateKey, "key", "", "钱包私钥（必填）")
+	c.Flags().StringVar(&partsDir, "parts-dir", "", "分片所在目录（必填）")
+	c.Flags().StringVar(&partsGlob, "glob", "*", "分片文件名匹配模式，按文件名字典序拼接")
+	c.Flags().StringVar(&partsName, "name", "", "写入 manifest 的原始文件名（默认取目录名）")
+	c.Flags().StringVar(&partsManifest, "manifest", "", "manifest 输出路径（默认 <name>.manifest.json）")
+	c.Flags().StringVar(&fragmentHashAlgo, "fragment-hash", "sha256", "分片校验算法: crc32 / xxhash / sha256")
+	c.Flags().BoolVar(&signManifest, "sign-manifest", false, "用 --key 对 manifest 签名（ECDSA over keccak256）")
+	c.Flags().StringVar(&appTag, "app-tag", "", "应用标识，作为 SDK --tags 随每个分片提交")
+	c.Flags().BoolVar(&logNonces, "log-nonces", false, "打印交易发送地址和每个分片提交前的 nonce，并记录到 manifest")
+	c.Flags().BoolVar(&assumeYes, "yes", false, "不询问，直接确认上传花费（非交互环境必须指定）")
+	c.Flags().BoolVar(&skipBalanceCheck, "skip-balance-check", false, "上传前不检查账户余额")
+	c.MarkFlagRequired("key")
+	c.MarkFlagRequired("parts-dir")
+	return c
+}
+
+// 按文件名排序收集分片，逐个计算分片哈希，并按拼接顺序计算整文件 MD5
+func collectParts(dir string, pattern string) ([]Fragment, string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return nil, "", fmt.Errorf("--glob 无效: %w", err)
+	}
+	sort.Strings(paths)
+
+	origin := md5.New()
+	var frags []Fragment
+	var offset int64
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, "", err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if info.Size() == 0 {
+			return nil, "", fmt.Errorf("分片 %s 是空文件", p)
+		}
+
+		h, err := newFragmentHasher(fragmentHashAlgo)
+		if err != nil {
+			return nil, "", err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, "", err
+		}
+		_, err = io.Copy(io.MultiWriter(h, origin), ctxReader{runCtx, f})
+		f.Close()
+		if err != nil {
+			return nil, "", err
+		}
+
+		frags = append(frags, Fragment{
+			Index:  len(frags),
+			Path:   p,
+			Offset: offset,
+			Size:   info.Size(),
+			Hash:   hex.EncodeToString(h.Sum(nil)),
+		})
+		offset += info.Size()
+	}
+	if len(frags) == 0 {
+		return nil, "", fmt.Errorf("%s 下没有匹配 %q 的文件", dir, pattern)
+	}
+	return frags, hex.EncodeToString(origin.Sum(nil)), nil
+}
+
+func runUploadParts() error {
+	defer slowReport.print()
+	defer printRetrySummary()
+
+	if partsName == "" {
+		partsName = filepath.Base(filepath.Clean(partsDir))
+	}
+	if partsManifest == "" {
+		partsManifest = partsName + ".manifest.json"
+	}
+
+	frags, originMD5, err := collectParts(partsDir, partsGlob)
+	if err != nil {
+		return configError(err)
+	}
+	if len(frags) > maxFragments {
+		return configError(fmt.Errorf("共 %d 个分片，超过 --max-fragments %d", len(frags), maxFragments))
+	}
+	// 传给 SDK 的 --fragment-size 取最大分片，保证 SDK 不会再二次切分
+	fragmentSize = 0
+	for _, frag := range frags {
+		fragmentSize = max(fragmentSize, frag.Size)
+	}
+	fmt.Printf("找到 %d 个分片，拼接后 %d bytes，MD5 %s\n", len(frags), frags[len(frags)-1].Offset+frags[len(frags)-1].Size, originMD5)
+
+	if err := preflightUpload(frags); err != nil {
+		return err
+	}
+
+	roots := make([]string, len(frags))
+	_, err = uploadFragments(frags, roots, func(frag Fragment) (string, error) {
+		fmt.Printf("\n[%d/%d] 正在上传分片: %s\n", frag.Index+1, len(frags), filepath.Base(frag.Path))
+		start := time.Now()
+		root, err := uploadWithFallback(frag) // upload-parts 没有 --fragment-retry-different-size，只会整片上传
+		if err != nil {
+			return "", fmt.Errorf("上传分片 %s 失败: %w", filepath.Base(frag.Path), err)
+		}
+		slowReport.record("上传", frag.Index, root, time.Since(start))
+		metrics.addBytes("上传", frag.Size)
+		fmt.Printf("分片 %d 上传成功，root = %s\n", frag.Index+1, root)
+		return root, nil
+	})
+	if err != nil {
+		return uploadError(err)
+	}
+
+	m := buildManifest(partsName, originMD5, frags, roots)
+	for i := range m.Fragments {
+		m.Fragments[i].Part = filepath.Base(frags[i].Path)
+	}
+	setFileRoot(m)
+	if err := maybeSignManifest(m); err != nil {
+		return uploadError(err)
+	}
+	if err := saveManifest(partsManifest, m); err != nil {
+		return uploadError(fmt.Errorf("写 manifest 失败: %w", err))
+	}
+	fmt.Printf("\nmanifest 已写入: %s\n", partsManifest)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 像 split -b 1000 那样在外部切好的分片（文件名顺序与创建顺序不同）：按文件名顺序上传，manifest 记录拼接顺序
+func TestUploadPartsOrderAndManifest(t *testing.T) {
+	dir := setupTest(t)
+	partsDir = filepath.Join(dir, "parts")
+	if err := os.Mkdir(partsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	whole := writeTestFile(t, filepath.Join(dir, "whole.bin"), 2500, 5)
+	names := []string{"part_aa", "part_ab", "part_ac"}
+	for i := len(names) - 1; i >= 0; i-- {
+		end := min((i+1)*1000, len(whole))
+		if err := os.WriteFile(filepath.Join(partsDir, names[i]), whole[i*1000:end], 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(partsDir, "README"), []byte("不匹配 --glob"), 0644)
+	partsGlob = "part_*"
+	partsName = "whole.bin"
+	partsManifest = filepath.Join(dir, "whole.manifest.json")
+	s := useCountingStorage()
+
+	if err := runUploadParts(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.uploads) != len(names) {
+		t.Fatalf("上传了 %d 个分片，期望 %d", len(s.uploads), len(names))
+	}
+	for i := range names {
+		sum := sha256.Sum256(whole[i*1000 : min((i+1)*1000, len(whole))])
+		if s.uploads[i] != "0x"+hex.EncodeToString(sum[:]) {
+			t.Fatalf("第 %d 次上传的不是 %s", i+1, names[i])
+		}
+	}
+
+	m, err := loadManifest(partsManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FileName != "whole.bin" || m.FileSize != int64(len(whole)) || len(m.Fragments) != len(names) {
+		t.Fatalf("manifest: 文件 %s，%d bytes，%d 个分片", m.FileName, m.FileSize, len(m.Fragments))
+	}
+	for i, frag := range m.Fragments {
+		if frag.Index != i || frag.Part != names[i] || frag.Offset != int64(i*1000) {
+			t.Fatalf("manifest 位置 %d: index %d, part %s, offset %d", i, frag.Index, frag.Part, frag.Offset)
+		}
+	}
+	out := filepath.Join(dir, "restored.bin")
+	if err := restoreFile(m, out); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, out, whole)
+	if err := verifyMD5(out, m.OriginHash); err != nil {
+		t.Fatal(err)
+	}
+}
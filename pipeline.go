@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// --pipeline：边切分边上传。固定大小切分时分片的 offset/size 只由文件大小决定，可以先规划好，
+// 切分在后台进行，上传 worker 等到对应分片写完就开始上传，不必等整个文件切完。
+// 整文件 MD5 仍在切分的读路径上顺带计算，切分结束（所有分片上传完）后、写 manifest 前取出
+var pipelineUpload bool
+
+type pipelineSplit struct {
+	frags []Fragment // 按文件大小预先规划，Hash 在分片写完后持 mu 填入
+	ready []chan struct{}
+
+	mu      sync.Mutex
+	written int   // 已写完的分片数，分片按顺序写出
+	err     error // 切分失败的原因，关闭剩余 ready 之前写入
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	took   time.Duration // 切分本身的耗时，done 关闭后可读
+}
+
+func planFragments(dstDir string, size, chunkSize int64) []Fragment {
+	var frags []Fragment
+	total := int((size + chunkSize - 1) / chunkSize)
+	for off := int64(0); off < size; off += chunkSize {
+		n := min(chunkSize, size-off)
+		var padding int64
+		if padLast && n < chunkSize {
+			padding = chunkSize - n
+		}
+		i := len(frags)
+		frags = append(frags, Fragment{Index: i, Path: filepath.Join(dstDir, fragmentFileName(i, total)), Offset: off, Size: n, Padding: padding})
+	}
+	return frags
+}
+
+// 在后台开始切分；调用方必须在返回前调用 stop（或 finish）
+func startPipelineSplit(src, dstDir string, size, chunkSize int64, hashAlgo string, origin io.Writer) *pipelineSplit {
+	ctx, cancel := context.WithCancel(runCtx)
+	p := &pipelineSplit{frags: planFragments(dstDir, size, chunkSize), cancel: cancel, done: make(chan struct{})}
+	p.ready = make([]chan struct{}, len(p.frags))
+	for i := range p.ready {
+		p.ready[i] = make(chan struct{})
+	}
+
+	go func() {
+		defer close(p.done)
+		start := time.Now()
+		err := splitFileEach(ctx, src, dstDir, chunkSize, hashAlgo, origin, func(frag Fragment) error {
+			if frag.Index >= len(p.frags) || frag.Size != p.frags[frag.Index].Size {
+				return fmt.Errorf("源文件在切分期间发生变化: 分片 %d 与按 %d bytes 规划的不一致", frag.Index+1, size)
+			}
+			p.mu.Lock()
+			p.frags[frag.Index].Hash = frag.Hash // plan() 可能同时在复制 p.frags
+			close(p.ready[frag.Index])
+			p.written++
+			p.mu.Unlock()
+			return nil
+		})
+		p.took = time.Since(start)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err == nil && p.written < len(p.frags) {
+			err = fmt.Errorf("源文件在切分期间变短: 规划 %d 个分片，只切出 %d 个", len(p.frags), p.written)
+		}
+		p.err = err
+		for _, c := range p.ready[p.written:] {
+			close(c)
+		}
+	}()
+	return p
+}
+
+// 规划好的分片（Hash 可能还没填）；返回副本，后台切分只写 p.frags
+func (p *pipelineSplit) plan() []Fragment {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Fragment(nil), p.frags...)
+}
+
+// 等分片 i 写完，返回带哈希的分片
+func (p *pipelineSplit) wait(i int) (Fragment, error) {
+	<-p.ready[i]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i >= p.written { // 没有写成功，是切分失败时统一关闭的
+		return Fragment{}, fmt.Errorf("切分分片 %d 失败: %w", i+1, p.err)
+	}
+	return p.frags[i], nil
+}
+
+// 等切分结束，返回全部分片；此后才能取整文件哈希
+func (p *pipelineSplit) finish() ([]Fragment, error) {
+	<-p.done
+	p.cancel()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.frags, nil
+}
+
+// 出错提前返回时停止后台切分并等它退出，避免临时目录被删时还在写
+func (p *pipelineSplit) stop() {
+	p.cancel()
+	<-p.done
+}
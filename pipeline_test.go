@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+)
+
+// --pipeline：切分和上传重叠时边读边算的整文件 MD5 与单独计算的一致，分片哈希也和普通切分一致
+func TestPipelineOriginHashMatches(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize, uploadConcurrency = 1000, 3
+	pipelineUpload = true
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 7500, 6)
+	m := uploadTestFile(t, path)
+
+	sum := md5.Sum(data)
+	if want := hex.EncodeToString(sum[:]); m.OriginHash != want {
+		t.Fatalf("--pipeline 的整文件 MD5 %s，单独计算 %s", m.OriginHash, want)
+	}
+	for _, frag := range m.Fragments {
+		want, _ := fragmentDigest(m.FragmentHashAlgo, data[frag.Offset:frag.Offset+frag.Size])
+		if frag.Hash != want {
+			t.Fatalf("分片 %d 的哈希 %s，期望 %s", frag.Index, frag.Hash, want)
+		}
+	}
+}
+
+// 后台切分填写分片哈希的同时读取规划（配合 -race 运行）
+func TestPipelinePlanWhileSplitting(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 20000, 1)
+	h := md5.New()
+	p := startPipelineSplit(path, t.TempDir(), 20000, 500, "sha256", h)
+	defer p.stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 40; i++ {
+			p.plan()
+		}
+	}()
+	for i := range p.plan() {
+		if _, err := p.wait(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	frags, err := p.finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frag := range frags {
+		if frag.Hash == "" {
+			t.Fatalf("分片 %d 切分完成后仍没有哈希", frag.Index)
+		}
+	}
+}
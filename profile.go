@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/sirupsen/logrus"
+)
+
+// --cpuprofile / --memprofile：写出整个运行的 pprof 文件（go tool pprof 查看），
+// 用来判断瓶颈在哈希、磁盘 I/O 还是并发上传
+var (
+	cpuProfilePath string
+	memProfilePath string
+	stopProfiling  = func() {} // main 退出前调用，停止 CPU 采样并写出内存 profile
+)
+
+func startProfiling() (func(), error) {
+	var cpuFile *os.File
+	if cpuProfilePath != "" {
+		f, err := os.Create(cpuProfilePath)
+		if err != nil {
+			return nil, fmt.Errorf("--cpuprofile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("--cpuprofile: %w", err)
+		}
+		cpuFile = f
+	}
+
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				logrus.Warnf("写 CPU profile 失败: %v", err)
+			} else {
+				fmt.Printf("CPU profile 已写入: %s\n", cpuProfilePath)
+			}
+		}
+		if memProfilePath != "" {
+			if err := writeHeapProfile(memProfilePath); err != nil {
+				logrus.Warnf("写内存 profile 失败: %v", err)
+			} else {
+				fmt.Printf("内存 profile 已写入: %s\n", memProfilePath)
+			}
+		}
+	}, nil
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC() // 让 profile 反映最新的存活对象
+	err = pprof.WriteHeapProfile(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// --cpuprofile / --memprofile 包住一次上传，写出的文件是 pprof 格式（gzip 压缩的 protobuf），go tool pprof 能解析
+func TestProfilesWrittenAndParseable(t *testing.T) {
+	dir := setupTest(t)
+	cpuProfilePath = filepath.Join(dir, "cpu.pprof")
+	memProfilePath = filepath.Join(dir, "mem.pprof")
+	stop, err := startProfiling()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragmentSize = 1000
+	src := filepath.Join(dir, "src.bin")
+	writeTestFile(t, src, 3000, 1)
+	uploadTestFile(t, src)
+	stop()
+
+	for _, path := range []string{cpuProfilePath, memProfilePath} {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("没有写出 profile: %v", err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s 不是 pprof 格式: %v", path, err)
+		}
+		if data, err := io.ReadAll(zr); err != nil || len(data) == 0 {
+			t.Fatalf("%s 内容无效: %d bytes, %v", path, len(data), err)
+		}
+		f.Close()
+
+		goBin, err := exec.LookPath("go")
+		if err != nil {
+			continue
+		}
+		if out, err := exec.Command(goBin, "tool", "pprof", "-raw", "-symbolize=none", path).CombinedOutput(); err != nil {
+			t.Fatalf("go tool pprof 无法解析 %s: %v\n%s", path, err, out)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// 按字节统计的整体上传进度。--resume 时已在 checkpoint 里的分片一开始就计入已完成，
+// 进度不会从 0% 开始再突然跳上去
+type byteProgress struct {
+	total int64
+	done  atomic.Int64
+}
+
+func newByteProgress(all []Fragment, pending []Fragment) *byteProgress {
+	p := &byteProgress{}
+	for _, frag := range all {
+		p.total += frag.Size
+	}
+	var left int64
+	for _, frag := range pending {
+		left += frag.Size
+	}
+	p.done.Store(p.total - left)
+	return p
+}
+
+func (p *byteProgress) add(n int64) {
+	p.done.Add(n)
+}
+
+func (p *byteProgress) String() string {
+	done := p.done.Load()
+	pct := 100.0
+	if p.total > 0 {
+		pct = float64(done) * 100 / float64(p.total)
+	}
+	return fmt.Sprintf("%.1f%% (%d/%d MB)", pct, done>>20, p.total>>20)
+}
+
+// Hooks 是嵌入方（例如 RunDeterministic 的调用者）接收运行事件的回调
+type Hooks struct {
+	// 单个分片的上传进度，done 对同一个分片单调递增，done == total 表示该分片完成。
+	// SDK 的 upload 命令不提供字节级回调，所以只在分片开始、每个分段完成和分片完成时触发
+	OnProgress func(index int, done, total int64)
+}
+
+var (
+	hooks      Hooks
+	progressMu sync.Mutex // 并发上传的分片依次回调，回调里不用自己加锁
+)
+
+func reportProgress(index int, done, total int64) {
+	if hooks.OnProgress == nil {
+		return
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	hooks.OnProgress(index, done, total)
+}
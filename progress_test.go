@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// --resume 时进度从 checkpoint 中已完成分片的字节数开始
+func TestByteProgressStartsFromCheckpoint(t *testing.T) {
+	all := []Fragment{{Index: 0, Size: 3 << 20}, {Index: 1, Size: 3 << 20}, {Index: 2, Size: 2 << 20}}
+	pending := []Fragment{all[1]}
+	p := newByteProgress(all, pending)
+	if got := p.done.Load(); got != 5<<20 {
+		t.Fatalf("初始进度 %d bytes，期望已完成分片之和 %d", got, 5<<20)
+	}
+	if s := p.String(); s != "62.5% (5/8 MB)" {
+		t.Fatalf("进度显示 %q", s)
+	}
+	p.add(all[1].Size)
+	if s := p.String(); s != "100.0% (8/8 MB)" {
+		t.Fatalf("全部完成后进度显示 %q", s)
+	}
+}
+
+// 并发上传时每个分片的进度回调带着自己的 index，done 单调不减并以 total 结束，回调之间不会交错执行
+func TestOnProgressPerFragment(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize, uploadConcurrency = 1000, 3
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 4500, 4)
+
+	var inCallback, overlapped atomic.Int32
+	seen := map[int][]int64{}
+	totals := map[int]int64{}
+	hooks.OnProgress = func(index int, done, total int64) {
+		if inCallback.Add(1) > 1 {
+			overlapped.Store(1)
+		}
+		seen[index] = append(seen[index], done)
+		totals[index] = total
+		time.Sleep(time.Millisecond)
+		inCallback.Add(-1)
+	}
+	m := uploadTestFile(t, path)
+
+	if overlapped.Load() != 0 {
+		t.Fatal("进度回调被并发执行")
+	}
+	if len(seen) != len(m.Fragments) {
+		t.Fatalf("收到 %d 个分片的进度，期望 %d 个", len(seen), len(m.Fragments))
+	}
+	for _, frag := range m.Fragments {
+		dones := seen[frag.Index]
+		if totals[frag.Index] != frag.Size || dones[len(dones)-1] != frag.Size {
+			t.Fatalf("分片 %d 的进度 %v / %d，期望以 %d 结束", frag.Index, dones, totals[frag.Index], frag.Size)
+		}
+		for i := 1; i < len(dones); i++ {
+			if dones[i] < dones[i-1] {
+				t.Fatalf("分片 %d 的进度倒退: %v", frag.Index, dones)
+			}
+		}
+	}
+}
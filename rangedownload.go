@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 存储节点按 chunk（256 字节）寻址，zgs_downloadSegment 一次最多返回一个 segment（1024 个 chunk）
+const (
+	chunkSize        = 256
+	chunksPerSegment = 1024
+)
+
+var fragmentDownloadParallelism int
+
+// 把一个分片按 segment 切成 --fragment-download-parallelism 段，直接向存储节点并发拉取，
+// 按偏移写回临时文件。高延迟链路上单个大分片可以快很多。
+// 任何一步失败都返回错误，由调用方退回 SDK 整体下载
+func downloadParallel(root string, indexer string, workers int) (string, error) {
+	locs, err := downloadLocations(indexer, root)
+	if err != nil {
+		return "", err
+	}
+	if len(locs) == 0 {
+		return "", fmt.Errorf("indexer 没有报告持有 root %s 的节点", root)
+	}
+	var size int64 = -1
+	for _, loc := range locs {
+		if info, err := getNodeFileInfo(loc.URL, root); err == nil && info != nil && info.Finalized {
+			size = info.Tx.Size
+			break
+		}
+	}
+	if size < 0 {
+		return "", fmt.Errorf("没有节点报告 root %s 已完成存储", root)
+	}
+
+	tmpFile, err := os.CreateTemp("", "0g-download-*.dat")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmpFile.Name()
+	defer tmpFile.Close()
+
+	totalChunks := (size + chunkSize - 1) / chunkSize
+	segments := (totalChunks + chunksPerSegment - 1) / chunksPerSegment
+	workers = int(min(int64(workers), max(segments, 1)))
+	perWorker := (segments + int64(workers) - 1) / int64(workers)
+
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			node := locs[w%len(locs)].URL
+			for seg := int64(w) * perWorker; seg < min(int64(w+1)*perWorker, segments); seg++ {
+				start := seg * chunksPerSegment
+				end := min(start+chunksPerSegment, totalChunks)
+				var data []byte // 节点以 base64 返回，encoding/json 自动解码
+				if err := rpcCall(node, "zgs_downloadSegment", []interface{}{root, start, end}, &data); err != nil {
+					errs[w] = fmt.Errorf("从 %s 下载 segment %d 失败: %w", node, seg, err)
+					return
+				}
+				if _, err := tmpFile.WriteAt(data, start*chunkSize); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			os.Remove(tmpPath)
+			return "", err
+		}
+	}
+	// 最后一个 chunk 按 256 字节补齐过，截掉补齐部分
+	if err := tmpFile.Truncate(size); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+// 开启分段并发时先试直连节点，失败再走 SDK
+func downloadRoot(root string, indexer string) (string, error) {
+	if dlNode != "" {
+		if err := checkNodeHasRoot(dlNode, root); err != nil {
+			return "", err
+		}
+	}
+	if fragmentDownloadParallelism > 1 {
+		tmpPath, err := downloadParallel(root, indexer, fragmentDownloadParallelism)
+		if err == nil {
+			return tmpPath, nil
+		}
+		logrus.Warnf("分段并发下载 root %s 失败，改用 SDK 整体下载: %v", root, err)
+	}
+	return downloadToTemp(root, indexer)
+}
+
+// 指定 --node 时只用这个节点，否则用 indexer 报告的节点
+func downloadLocations(indexer string, root string) ([]fileLocation, error) {
+	if dlNode != "" {
+		return []fileLocation{{URL: dlNode}}, nil
+	}
+	return fileLocations(indexer, root)
+}
+
+// --node 指定的节点必须已经完整存储了这个 root，否则直接失败，不换其他节点
+func checkNodeHasRoot(node string, root string) error {
+	info, err := getNodeFileInfo(node, root)
+	if err != nil {
+		return fmt.Errorf("查询节点 %s 上的 root %s 失败: %w", node, root, err)
+	}
+	if info == nil {
+		return fmt.Errorf("root %s not found on node %s（--node 不回退到其他节点）", root, node)
+	}
+	if !info.Finalized {
+		return fmt.Errorf("节点 %s 上的 root %s 尚未完成存储（--node 不回退到其他节点）", node, root)
+	}
+	return nil
+}
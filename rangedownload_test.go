@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+// 假的存储节点：持有一个 root 的数据，支持 zgs_downloadSegment 按 chunk 区间读取，并记录读取过的区间
+type fakeSegmentNode struct {
+	root string
+	data []byte
+
+	mu       sync.Mutex
+	segments [][2]int64
+}
+
+func (n *fakeSegmentNode) serve(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var result interface{}
+		switch req.Method {
+		case "indexer_getFileLocations":
+			result = []fileLocation{{URL: srv.URL}}
+		case "zgs_getFileInfo":
+			info := &nodeFileInfo{Finalized: true}
+			info.Tx.DataMerkleRoot, info.Tx.Size = n.root, int64(len(n.data))
+			result = info
+		case "zgs_downloadSegment":
+			var start, end int64
+			json.Unmarshal(req.Params[1], &start)
+			json.Unmarshal(req.Params[2], &end)
+			n.mu.Lock()
+			n.segments = append(n.segments, [2]int64{start, end})
+			n.mu.Unlock()
+			seg := make([]byte, (end-start)*chunkSize) // 最后一个 chunk 按 256 字节补零
+			copy(seg, n.data[min(start*chunkSize, int64(len(n.data))):min(end*chunkSize, int64(len(n.data)))])
+			result = seg
+		}
+		data, _ := json.Marshal(result)
+		json.NewEncoder(w).Encode(rpcResponse{Result: data})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownloadParallelReassemblesRanges(t *testing.T) {
+	setupTest(t)
+	data := make([]byte, 3*chunkSize*chunksPerSegment+1000) // 4 个 segment，最后一个不满
+	for i := range data {
+		data[i] = byte(i * 31 / 7)
+	}
+	node := &fakeSegmentNode{root: "0xabc", data: data}
+	srv := node.serve(t)
+
+	path, err := downloadParallel("0xabc", srv.URL, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	assertFileContent(t, path, data)
+	if len(node.segments) != 4 {
+		t.Fatalf("期望 4 次区间请求，实际 %v", node.segments)
+	}
+	covered := map[int64]bool{}
+	for _, s := range node.segments {
+		covered[s[0]] = true
+	}
+	for seg := int64(0); seg < 4; seg++ {
+		if !covered[seg*chunksPerSegment] {
+			t.Fatalf("segment %d 没有请求，实际 %v", seg, node.segments)
+		}
+	}
+}
+
+// --node：只向指定节点取数据，不询问 indexer；节点上没有时直接失败，不回退到上传时的 indexer
+func TestDownloadNodeNoFallback(t *testing.T) {
+	setupTest(t)
+	data := make([]byte, chunkSize*chunksPerSegment+500)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+	node := &fakeSegmentNode{root: "0xabc", data: data}
+	var indexerHits int
+	var mu sync.Mutex
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		indexerHits++
+		mu.Unlock()
+		http.Error(w, "indexer should not be used", http.StatusInternalServerError)
+	}))
+	t.Cleanup(indexer.Close)
+
+	dlNode = node.serve(t).URL
+	fragmentDownloadParallelism = 2
+	path, err := downloadRoot("0xabc", indexer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	assertFileContent(t, path, data)
+	if len(node.segments) == 0 || indexerHits != 0 {
+		t.Fatalf("应只从 --node 下载：节点收到 %d 次区间请求，indexer 收到 %d 次请求", len(node.segments), indexerHits)
+	}
+
+	// 节点上没有这个 root：直接报 not found，不再问 indexer
+	dlNode = newFakeNodeServer(t, func(string) *nodeFileInfo { return nil }).URL
+	if _, err := downloadRoot("0xdef", indexer.URL); err == nil || !isNotFound(err) {
+		t.Fatalf("节点上没有 root 时应报 not found，实际 %v", err)
+	}
+	if indexerHits != 0 {
+		t.Fatalf("--node 失败后不应询问 indexer，实际 %d 次", indexerHits)
+	}
+
+	// 分片级别同样不回退到上传时记录的 indexer
+	s := &indexerStorage{fakeStorage: testStore(), serving: "fake://upload-indexer", asked: map[string][]string{}}
+	storage = s
+	frag := ManifestFragment{Index: 0, Root: "0xdef", Indexer: "fake://upload-indexer"}
+	if _, err := downloadStoredRoot(frag, frag.Root); err == nil {
+		t.Fatal("--node 下找不到分片时应失败")
+	}
+	if got := s.asked["0xdef"]; len(got) != 1 || got[0] != indexerURL {
+		t.Fatalf("--node 下不应回退到上传时的 indexer，实际询问了 %v", got)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rcManifest string
+	rcName     string
+	rcOutput   string
+)
+
+// reconstruct：纠删码备份部分分片丢失时使用。先向 indexer 查询每个分片是否可下载，
+// 只下载恰好 k 个可用分片重建原始文件，并报告用了哪些、缺了哪些
+func newReconstructCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "reconstruct",
+		Short: "从部分可用的纠删码分片中挑出 k 个重建原始文件",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			defer printRetrySummary()
+			m, err := loadManifestFor(rcManifest, namespace, rcName)
+			if err != nil {
+				return configError(fmt.Errorf("读取 manifest 失败: %w", err))
+			}
+			if m.Erasure == nil {
+				return configError(fmt.Errorf("manifest 不是纠删码上传（--erasure），请用 download"))
+			}
+			if err := verifyManifestSignature(m, expectSigner); err != nil {
+				return verifyError(err)
+			}
+			if err := verifyFileRoot(m); err != nil {
+				return verifyError(err)
+			}
+			if rcOutput == "" {
+				rcOutput = m.FileName + ".restored"
+			}
+			if err := reconstructErasure(m, rcOutput); err != nil {
+				return downloadError(err)
+			}
+			return verifyMD5(rcOutput, m.OriginHash)
+		},
+	}
+	c.Flags().StringVar(&rcManifest, "manifest", "", "manifest 路径（必填）")
+	c.Flags().StringVar(&rcName, "name", "", "多文件 manifest 中的原始文件名")
+	c.Flags().StringVar(&rcOutput, "output", "", "恢复文件路径（默认 <原文件名>.restored）")
+	c.MarkFlagRequired("manifest")
+	return c
+}
+
+// 分片的所有 root（分段上传时是每一段）都可下载才算可用；后端不支持查询时视为可用
+func fragmentAvailable(frag ManifestFragment) (bool, error) {
+	checker, ok := storage.(availabilityChecker)
+	if !ok {
+		return true, nil
+	}
+	for _, p := range frag.pieces() {
+		ok, err := checker.Available(p.Root)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func reconstructErasure(m *Manifest, outputPath string) error {
+	e := m.Erasure
+	enc, err := reedsolomon.NewStream(e.DataShards, e.ParityShards)
+	if err != nil {
+		return err
+	}
+	total := e.DataShards + e.ParityShards
+
+	// 查询失败的分片不一定丢了，排在确认可用的后面作为备选
+	var available, unknown []ManifestFragment
+	var missing []int
+	present := map[int]bool{}
+	for _, frag := range m.Fragments {
+		present[frag.Index] = true
+		ok, err := fragmentAvailable(frag)
+		switch {
+		case err != nil:
+			logrus.Warnf("查询分片 %d 是否可用失败，稍后再尝试下载: %v", frag.Index+1, err)
+			unknown = append(unknown, frag)
+		case ok:
+			available = append(available, frag)
+		default:
+			missing = append(missing, frag.Index)
+		}
+	}
+	for i := 0; i < total; i++ {
+		if !present[i] {
+			missing = append(missing, i) // manifest 里就没有记录
+		}
+	}
+	fmt.Printf("共 %d 个分片，需要 %d 个: %d 个可用，%d 个不可用，%d 个状态未知\n",
+		total, e.DataShards, len(available), len(missing), len(unknown))
+	if len(available)+len(unknown) < e.DataShards {
+		return fmt.Errorf("可用分片不足: 需要 %d 个，只有 %d 个可用（不可用: %s）",
+			e.DataShards, len(available)+len(unknown), formatShardList(missing))
+	}
+
+	paths := make([]string, total)
+	var used []int
+	for _, frag := range append(available, unknown...) {
+		if len(used) == e.DataShards {
+			break
+		}
+		fmt.Printf("[%d/%d] 正在下载分片 %d，root: %s\n", len(used)+1, e.DataShards, frag.Index+1, frag.Root)
+		tmpPath, err := downloadFragment(m, frag)
+		if err != nil {
+			logrus.Warnf("分片 %d 下载失败，改用其他分片: %v", frag.Index+1, err)
+			missing = append(missing, frag.Index)
+			continue
+		}
+		defer os.Remove(tmpPath)
+		paths[frag.Index] = tmpPath
+		used = append(used, frag.Index)
+	}
+	if len(used) < e.DataShards {
+		return fmt.Errorf("可用分片不足: 需要 %d 个，只下载到 %d 个（不可用: %s）",
+			e.DataShards, len(used), formatShardList(missing))
+	}
+
+	fmt.Printf("使用的分片: %s\n", formatShardList(used))
+	fmt.Printf("缺失的分片: %s\n", formatShardList(missing))
+	return joinErasure(m, enc, paths, outputPath)
+}
+
+// 分片序号按 1 开始显示
+func formatShardList(indices []int) string {
+	if len(indices) == 0 {
+		return "无"
+	}
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+	parts := make([]string, len(sorted))
+	for i, idx := range sorted {
+		parts[i] = strconv.Itoa(idx + 1)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// 只保留了 file_root 时从链上找回 manifest。
+//
+// 链上的 submission 只有 root、大小和 tags，本身不知道哪些分片属于同一个文件，所以需要上传时
+// 用 --publish-manifest 把 manifest 本身也作为一个 submission 上传，tags 为 "0g-manifest:<file_root>"。
+// recover-manifest 从最新的 submission 往前扫描，找到 tags 匹配的那个，下载出 manifest，
+// 再用其中的分片 root 重新计算 file_root 确认无误。没有用 --publish-manifest 上传过的文件无法找回
+var (
+	publishManifest   bool   // 上传：写完 manifest 后把它也作为一个 submission 上传
+	uploadTagOverride string // 非空时代替 --app-tag 作为这次 SDK 上传的 --tags
+
+	rmFileRoot string
+	rmNode     string
+	rmScan     int
+	rmOutput   string
+)
+
+const manifestTagPrefix = "0g-manifest:"
+
+func manifestTag(fileRoot string) string {
+	return manifestTagPrefix + strings.ToLower(fileRoot)
+}
+
+// 把 manifest 作为一个 submission 上传，tags 带上 file_root，供 recover-manifest 查找
+func publishManifestOnChain(m *Manifest) error {
+	if m.FileRoot == "" {
+		return fmt.Errorf("manifest 没有 file_root（有分片没有 root），无法发布")
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp("", "0g-manifest-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	uploadTagOverride = manifestTag(m.FileRoot)
+	defer func() { uploadTagOverride = "" }()
+	root, err := storage.Upload(f.Name())
+	if err != nil {
+		return fmt.Errorf("上传 manifest 失败: %w", err)
+	}
+	fmt.Printf("manifest 已发布到链上，root = %s，tags = %s；只保留 file_root %s 也可以用 recover-manifest 找回\n", root, uploadTagOverride, m.FileRoot)
+	return nil
+}
+
+func newRecoverManifestCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "recover-manifest",
+		Short: "只有 file_root 时，从链上找回用 --publish-manifest 发布的 manifest",
+		Long: "只有 file_root 时，从链上找回用 --publish-manifest 发布的 manifest。\n\n" +
+			"要求: 上传时加了 --publish-manifest（manifest 作为一个 submission 上传，tags 为 \"" + manifestTagPrefix + "<file_root>\"），\n" +
+			"并且这个 submission 在存储节点最新的 --scan 个 submission 之内。",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			node := rmNode
+			if node == "" {
+				var err error
+				if node, err = firstStorageNode(); err != nil {
+					return configError(fmt.Errorf("需要 --node，或者 indexer 能返回存储节点: %w", err))
+				}
+			}
+			m, err := recoverManifest(node, rmFileRoot, rmScan)
+			if err != nil {
+				return downloadError(err)
+			}
+			output := rmOutput
+			if output == "" {
+				if output, err = recoveredManifestPath(m); err != nil {
+					return downloadError(err)
+				}
+			}
+			if err := saveManifest(output, m); err != nil {
+				return downloadError(err)
+			}
+			fmt.Printf("manifest 已写入: %s（%s，%d 个分片）\n", output, m.FileName, len(m.Fragments))
+			return nil
+		},
+	}
+	c.Flags().StringVar(&rmFileRoot, "file-root", "", "manifest 里的 file_root（必填）")
+	c.Flags().StringVar(&rmNode, "node", "", "查询 submission 的存储节点，默认用 indexer 返回的第一个节点")
+	c.Flags().IntVar(&rmScan, "scan", 10000, "从最新的 submission 往前最多扫描多少个")
+	c.Flags().StringVar(&rmOutput, "output", "", "找回的 manifest 写到这里（默认 <原文件名>.manifest.json）")
+	c.MarkFlagRequired("file-root")
+	return c
+}
+
+// 默认输出 <原文件名>.manifest.json，写在当前目录。文件名来自链上任何人都能发布的 manifest，
+// 只取最后一段，免得 "../../x" 之类的名字写到别处
+func recoveredManifestPath(m *Manifest) (string, error) {
+	name := filepath.Base(m.FileName)
+	if m.FileName == "" || name == "." || name == ".." || name == string(filepath.Separator) {
+		return "", fmt.Errorf("manifest 的文件名 %q 不能用作输出文件名，请用 --output 指定", m.FileName)
+	}
+	return name + ".manifest.json", nil
+}
+
+func recoverManifest(node string, fileRoot string, scan int) (*Manifest, error) {
+	var status struct {
+		NextTxSeq uint64 `json:"nextTxSeq"`
+	}
+	if err := rpcCall(node, "zgs_getStatus", []interface{}{}, &status); err != nil {
+		return nil, fmt.Errorf("查询节点 %s 状态失败: %w", node, err)
+	}
+	want := manifestTag(fileRoot)
+	fmt.Printf("在节点 %s 上从 submission %d 往前扫描 tags = %s\n", node, status.NextTxSeq, want)
+
+	for i := 0; i < scan && uint64(i) < status.NextTxSeq; i++ {
+		if err := runCtx.Err(); err != nil {
+			return nil, err
+		}
+		seq := status.NextTxSeq - 1 - uint64(i)
+		var info *nodeFileInfo
+		if err := rpcCall(node, "zgs_getFileInfoByTxSeq", []interface{}{seq}, &info); err != nil {
+			return nil, fmt.Errorf("查询 submission %d 失败: %w", seq, err)
+		}
+		if info == nil || string(decodeTxData(info.Tx.Data)) != want {
+			continue
+		}
+		fmt.Printf("submission %d 的 tags 匹配，root = %s\n", seq, info.Tx.DataMerkleRoot)
+		m, err := downloadPublishedManifest(info.Tx.DataMerkleRoot)
+		if err != nil {
+			return nil, fmt.Errorf("下载 submission %d 中的 manifest 失败: %w", seq, err)
+		}
+		if !strings.EqualFold(m.FileRoot, fileRoot) {
+			return nil, fmt.Errorf("submission %d 中的 manifest file_root 是 %s，不是 %s", seq, m.FileRoot, fileRoot)
+		}
+		if err := verifyFileRoot(m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("最新的 %d 个 submission 中没有 tags 为 %s 的 manifest（上传时需要 --publish-manifest）", scan, want)
+}
+
+func downloadPublishedManifest(root string) (*Manifest, error) {
+	tmpPath, err := storage.Download(root, indexerURL)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+	return loadManifest(tmpPath)
+}
+
+// 节点返回的 tags 可能是 hex / base64 字符串或字节数组，统一解成字节
+func decodeTxData(raw json.RawMessage) []byte {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if strings.HasPrefix(s, "0x") {
+			b, _ := hex.DecodeString(s[2:])
+			return b
+		}
+		b, _ := base64.StdEncoding.DecodeString(s)
+		return b
+	}
+	var b []byte
+	json.Unmarshal(raw, &b)
+	return b
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 假的链上节点：按 seq 保存 submission（root + tags），回答 zgs_getStatus / zgs_getFileInfoByTxSeq
+func newFakeSubmissionNode(t *testing.T, roots []string, tags []string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var result interface{}
+		switch req.Method {
+		case "zgs_getStatus":
+			result = map[string]uint64{"nextTxSeq": uint64(len(roots))}
+		case "zgs_getFileInfoByTxSeq":
+			seq := int(req.Params[0].(float64))
+			info := &nodeFileInfo{Finalized: true}
+			info.Tx.DataMerkleRoot, info.Tx.Seq = roots[seq], uint64(seq)
+			info.Tx.Data, _ = json.Marshal("0x" + hex.EncodeToString([]byte(tags[seq])))
+			result = info
+		default:
+			http.Error(w, "unknown method", http.StatusBadRequest)
+			return
+		}
+		data, _ := json.Marshal(result)
+		json.NewEncoder(w).Encode(rpcResponse{Result: data})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// --publish-manifest 上传后只保留 file_root：recover-manifest 在假链上找到 tags 匹配的 submission，重建出可用的 manifest
+func TestRecoverManifestFromFileRoot(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	publishManifest = true
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 3500, 4)
+	counter := useCountingStorage()
+	m := uploadTestFile(t, path)
+	if m.FileRoot == "" || counter.uploadCount() != len(m.Fragments)+1 {
+		t.Fatalf("应上传 %d 个分片和 manifest 本身，实际上传 %d 次", len(m.Fragments), counter.uploadCount())
+	}
+	published := counter.uploads[len(counter.uploads)-1]
+
+	// 链上：各分片的 submission，然后是 manifest，最新的是另一个文件的 manifest
+	var roots, tags []string
+	for _, frag := range m.Fragments {
+		roots, tags = append(roots, frag.Root), append(tags, "")
+	}
+	roots, tags = append(roots, published), append(tags, manifestTag(m.FileRoot))
+	roots, tags = append(roots, "0x"+strings.Repeat("ef", 32)), append(tags, manifestTag("0x"+strings.Repeat("12", 32)))
+	node := newFakeSubmissionNode(t, roots, tags)
+	os.Remove(manifestPath)
+
+	got, err := recoverManifest(node.URL, m.FileRoot, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.FileRoot != m.FileRoot || len(got.Fragments) != len(m.Fragments) {
+		t.Fatalf("找回的 manifest 不对: file_root %s，%d 个分片", got.FileRoot, len(got.Fragments))
+	}
+	for i, frag := range got.Fragments {
+		if frag.Root != m.Fragments[i].Root {
+			t.Fatalf("分片 %d 的 root 是 %s，期望 %s", i, frag.Root, m.Fragments[i].Root)
+		}
+	}
+	out := filepath.Join(dir, "out.bin")
+	if err := restoreFile(got, out); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, out, data)
+
+	// 没有发布过的 file_root：扫完后报错
+	if _, err := recoverManifest(node.URL, "0x"+strings.Repeat("34", 32), 100); err == nil || !strings.Contains(err.Error(), "--publish-manifest") {
+		t.Fatalf("找不到 manifest 时应提示需要 --publish-manifest，实际 %v", err)
+	}
+}
+
+// 默认输出路径只用链上 manifest 文件名的最后一段，不能跳出当前目录
+func TestRecoveredManifestPath(t *testing.T) {
+	for name, want := range map[string]string{
+		"data.bin":    "data.bin.manifest.json",
+		"../../x":     "x.manifest.json",
+		"/etc/passwd": "passwd.manifest.json",
+		"a/b/c.tar":   "c.tar.manifest.json",
+	} {
+		got, err := recoveredManifestPath(&Manifest{FileName: name})
+		if err != nil || got != want {
+			t.Fatalf("文件名 %q: %q, %v，期望 %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../..", "/"} {
+		if got, err := recoveredManifestPath(&Manifest{FileName: name}); err == nil {
+			t.Fatalf("文件名 %q 应报错，实际得到 %q", name, got)
+		}
+	}
+}
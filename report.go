@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// --report-format：上传完成后的汇总（并发数、gas、每个分片的 root）用什么格式输出。
+// text 是原来的逐行输出，json 输出 RunSummary，table 是对齐的分片表格
+var reportFormat = "text"
+
+// RunSummary 是上传完成后的汇总，--report-format json 时原样输出
+type RunSummary struct {
+	File            string            `json:"file"`
+	Fragments       []FragmentSummary `json:"fragments"`
+	Concurrency     int               `json:"concurrency"`
+	AutoConcurrency bool              `json:"auto_concurrency"`
+	Gas             string            `json:"gas"`
+	UploadSeconds   float64           `json:"upload_seconds"`
+}
+
+type FragmentSummary struct {
+	Index   int           `json:"index"` // 从 0 开始，同 manifest
+	Root    string        `json:"root"`  // 分段上传时为空，见 Subs
+	Subs    int           `json:"subs,omitempty"`
+	Zero    bool          `json:"zero,omitempty"` // 全零分片，没有上传
+	Size    int64         `json:"size"`
+	Seconds float64       `json:"seconds"` // 从 checkpoint 跳过的分片为 0
+	elapsed time.Duration // table / text 输出用
+}
+
+func validateReportFormat(f string) error {
+	switch f {
+	case "text", "json", "table":
+		return nil
+	}
+	return fmt.Errorf("不支持的 --report-format: %s（可选 text / json / table）", f)
+}
+
+func newRunSummary(file string, frags []Fragment, roots []string, durations []time.Duration, concurrency int, elapsed time.Duration) *RunSummary {
+	s := &RunSummary{
+		File:            file,
+		Concurrency:     concurrency,
+		AutoConcurrency: concurrencyAuto,
+		Gas:             gasSummary(),
+		UploadSeconds:   elapsed.Seconds(),
+	}
+	for _, frag := range frags {
+		s.Fragments = append(s.Fragments, FragmentSummary{
+			Index:   frag.Index,
+			Root:    roots[frag.Index],
+			Subs:    len(subUploads.get(frag.Index)),
+			Zero:    frag.Zero,
+			Size:    frag.Size,
+			Seconds: durations[frag.Index].Seconds(),
+			elapsed: durations[frag.Index],
+		})
+	}
+	return s
+}
+
+func (s *RunSummary) render(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case "table":
+		s.renderHeader(w)
+		// 表头用 ASCII：tabwriter 按字符数对齐，中文会错位
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "#\tSIZE\tDURATION\tROOT")
+		for _, f := range s.Fragments {
+			fmt.Fprintf(tw, "%02d\t%d\t%s\t%s\n", f.Index+1, f.Size, f.elapsed.Round(time.Millisecond), f.rootText())
+		}
+		return tw.Flush()
+	default:
+		s.renderHeader(w)
+		for _, f := range s.Fragments {
+			fmt.Fprintf(w, "分片 %02d root: %s\n", f.Index+1, f.rootText())
+		}
+		return nil
+	}
+}
+
+func (s *RunSummary) renderHeader(w io.Writer) {
+	if s.AutoConcurrency {
+		fmt.Fprintf(w, "并发数: %d（自动调节）\n", s.Concurrency)
+	} else {
+		fmt.Fprintf(w, "并发数: %d\n", s.Concurrency)
+	}
+	fmt.Fprintf(w, "交易 gas: %s\n", s.Gas)
+}
+
+func (f FragmentSummary) rootText() string {
+	if f.Zero {
+		return "全零，未上传"
+	}
+	if f.Root == "" {
+		return fmt.Sprintf("分 %d 段上传（见 manifest 的 subs）", f.Subs)
+	}
+	return f.Root
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRunSummary(t *testing.T) *RunSummary {
+	t.Helper()
+	setupTest(t)
+	frags := []Fragment{
+		{Index: 0, Size: 1000},
+		{Index: 1, Size: 1000, Zero: true},
+		{Index: 2, Size: 500},
+	}
+	roots := []string{"0xaaaa", "", "0xcccc"}
+	durations := []time.Duration{1200 * time.Millisecond, 0, 30 * time.Millisecond}
+	return newRunSummary("src.bin", frags, roots, durations, 2, 1500*time.Millisecond)
+}
+
+func TestReportFormatText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testRunSummary(t).render(&buf, "text"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"并发数: 2\n", "分片 01 root: 0xaaaa\n", "分片 02 root: 全零，未上传\n", "分片 03 root: 0xcccc\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("text 输出缺少 %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestReportFormatJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testRunSummary(t).render(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var got RunSummary
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("json 输出无法解析为 RunSummary: %v\n%s", err, buf.String())
+	}
+	if got.File != "src.bin" || got.Concurrency != 2 || got.UploadSeconds != 1.5 || len(got.Fragments) != 3 {
+		t.Fatalf("解析结果不对: %+v", got)
+	}
+	if f := got.Fragments[0]; f.Root != "0xaaaa" || f.Size != 1000 || f.Seconds != 1.2 {
+		t.Fatalf("分片 1 解析结果不对: %+v", f)
+	}
+	if !got.Fragments[1].Zero {
+		t.Fatal("分片 2 应标记为全零")
+	}
+}
+
+// table 的每一列在表头和各行中起始位置相同
+func TestReportFormatTable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testRunSummary(t).render(&buf, "table"); err != nil {
+		t.Fatal(err)
+	}
+	var table []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "0") {
+			table = append(table, line)
+		}
+	}
+	if len(table) != 4 {
+		t.Fatalf("应有表头和 3 行，得到:\n%s", buf.String())
+	}
+	col := strings.Index(table[0], "ROOT")
+	for _, row := range table[1:] {
+		if row[col-2:col] != "  " || row[col] == ' ' {
+			t.Fatalf("ROOT 列没有对齐:\n%s", strings.Join(table, "\n"))
+		}
+	}
+	if !strings.Contains(table[1], "1.2s") || !strings.HasSuffix(table[3], "0xcccc") {
+		t.Fatalf("表格内容不对:\n%s", strings.Join(table, "\n"))
+	}
+}
+
+func TestValidateReportFormat(t *testing.T) {
+	for _, f := range []string{"text", "json", "table"} {
+		if err := validateReportFormat(f); err != nil {
+			t.Errorf("%s: %v", f, err)
+		}
+	}
+	if err := validateReportFormat("yaml"); err == nil {
+		t.Error("yaml 应被拒绝")
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rootsManifest string
+	rootsName     string
+)
+
+// 只输出 root，每行一个，方便脚本处理
+func newRootsCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "roots",
+		Short: "按分片顺序输出 manifest 中的 root，每行一个",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			m, err := loadManifestFor(rootsManifest, namespace, rootsName)
+			if err != nil {
+				return configError(fmt.Errorf("读取 manifest 失败: %w", err))
+			}
+			for _, root := range m.Roots() {
+				fmt.Println(root)
+			}
+			return nil
+		},
+	}
+
+	c.Flags().StringVar(&rootsManifest, "manifest", "", "manifest 路径（必填）")
+	c.Flags().StringVar(&rootsName, "name", "", "多文件 manifest 中的原始文件名")
+	c.MarkFlagRequired("manifest")
+	return c
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// roots 子命令只输出 root，每个分片一行，按分片顺序，没有任何其他内容
+func TestRootsCommandOutput(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 4500, 0)
+	m := uploadTestFile(t, path)
+
+	c := newRootsCmd()
+	rootsManifest = manifestPath
+	out := captureStdout(t, func() {
+		if err := c.RunE(c, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != len(m.Fragments) || len(lines) != 5 {
+		t.Fatalf("期望 5 行，实际 %d 行: %q", len(lines), out)
+	}
+	for i, line := range lines {
+		if line != m.Fragments[i].Root {
+			t.Fatalf("第 %d 行是 %q，期望分片 %d 的 root %s", i+1, line, i, m.Fragments[i].Root)
+		}
+	}
+}
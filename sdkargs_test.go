@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sdkArgMap(args []string) map[string]string {
+	m := map[string]string{}
+	for i := 0; i+1 < len(args); i += 2 {
+		m[args[i]] = args[i+1]
+	}
+	return m
+}
+
+// --dump-options 输出的就是传给 SDK 的参数，应反映命令行给出的 flags，私钥只显示首尾
+func TestSDKUploadArgsReflectFlags(t *testing.T) {
+	dir := setupTest(t)
+	frag := filepath.Join(dir, "fragment_000.dat")
+	os.WriteFile(frag, make([]byte, 100), 0644)
+	rpcURL, indexerURL = "https://rpc.example", "https://indexer.example"
+	privateKey = "0123456789abcdef"
+	fragmentSize, perFragmentTimeout, fragmentUploadParallelism, appTag = 4<<20, 90*time.Second, 4, "backup"
+
+	args := sdkArgMap(sdkUploadArgs(frag))
+	want := map[string]string{
+		"--url":              "https://rpc.example",
+		"--indexer":          "https://indexer.example",
+		"--file":             frag,
+		"--fragment-size":    "4194304",
+		"--timeout":          "1m30s",
+		"--routines":         "4",
+		"--tags":             "0x6261636b7570",
+		"--expected-replica": "1",
+	}
+	for k, v := range want {
+		if args[k] != v {
+			t.Errorf("%s = %q，期望 %q", k, args[k], v)
+		}
+	}
+
+	dump := formatSDKArgs(sdkUploadArgs(frag))
+	if strings.Contains(dump, privateKey) || !strings.Contains(dump, "0123...cdef") {
+		t.Fatalf("dump 中私钥没有打码:\n%s", dump)
+	}
+	if !strings.Contains(dump, "  --fragment-size      4194304\n") {
+		t.Fatalf("dump 格式不对:\n%s", dump)
+	}
+}
+
+// --app-tag 随每个分片的 SDK 参数提交，并记录到 manifest；不指定时不传 --tags
+func TestAppTagReachesUploadAndManifest(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 100, 1)
+	if _, ok := sdkArgMap(sdkUploadArgs(path))["--tags"]; ok {
+		t.Fatal("没有 --app-tag 时不应传 --tags")
+	}
+
+	appTag = "my-app"
+	if got := sdkArgMap(sdkUploadArgs(path))["--tags"]; got != "0x6d792d617070" {
+		t.Fatalf("--tags = %q，期望 my-app 的 hex", got)
+	}
+	if m := uploadTestFile(t, path); m.AppTag != "my-app" {
+		t.Fatalf("manifest 记录的 app_tag = %q", m.AppTag)
+	}
+}
+
+// 记录每次上传时交给 SDK 的参数，再交给 fakeStorage
+type argsRecordingStorage struct {
+	fakeStorage
+	args [][]string
+}
+
+func (s *argsRecordingStorage) Upload(path string) (string, error) {
+	s.args = append(s.args, sdkUploadArgs(path))
+	return s.fakeStorage.Upload(path)
+}
+
+// --fragment-upload-parallelism 只改变 SDK 提交 segment 的并发数，得到的 root 与串行上传相同
+func TestFragmentUploadParallelismKeepsRoots(t *testing.T) {
+	var roots [2][]string
+	for i, parallelism := range []int{1, 4} {
+		dir := setupTest(t)
+		fragmentSize = 1000
+		src := filepath.Join(dir, "src.bin")
+		writeTestFile(t, src, 3500, 9)
+		compressCodec = "none"
+		fragmentUploadParallelism = parallelism
+		s := &argsRecordingStorage{fakeStorage: testStore()}
+		storage = s
+		m := uploadTestFile(t, src)
+
+		if len(s.args) != len(m.Fragments) {
+			t.Fatalf("上传了 %d 次，期望 %d 个分片", len(s.args), len(m.Fragments))
+		}
+		for _, args := range s.args {
+			routines, ok := sdkArgMap(args)["--routines"]
+			if parallelism == 1 && ok {
+				t.Fatalf("串行上传不应传 --routines，得到 %s", routines)
+			}
+			if parallelism > 1 && routines != "4" {
+				t.Fatalf("--routines 为 %q，期望 4", routines)
+			}
+		}
+		for _, frag := range m.Fragments {
+			roots[i] = append(roots[i], frag.Root)
+		}
+	}
+	if strings.Join(roots[0], ",") != strings.Join(roots[1], ",") {
+		t.Fatalf("并发上传的 root %v 与串行上传 %v 不同", roots[1], roots[0])
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --sha256sums：把原始文件和恢复文件的 sha256 写成 sha256sum 的输出格式，之后可以直接 sha256sum -c。
+// 原始文件的 sha256 在切分的读路径上顺带计算；恢复文件另外读一遍，顺便和原始文件比对
+var sha256SumsPath string
+
+// download --expected-hashes：用别处保存的 sha256sum 输出（SHA256SUMS）再校验一次恢复文件，
+// 不依赖 manifest 自己记录的哈希，manifest 被篡改或记录错误时也能发现
+var dlExpectedHashes string
+
+// 在整文件 MD5 的 writer 上再挂一个 sha256；没开 --sha256sums 时原样返回
+func withOriginSHA256(w io.Writer) (io.Writer, func() string) {
+	if sha256SumsPath == "" {
+		return w, func() string { return "" }
+	}
+	h := sha256.New()
+	return io.MultiWriter(w, h), func() string { return hex.EncodeToString(h.Sum(nil)) }
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.CopyBuffer(h, ctxReader{runCtx, f}, make([]byte, ioBufferSize)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeSHA256Sums(path, origin, originSum, restored string) error {
+	restoredSum, err := fileSHA256(restored)
+	if err != nil {
+		return verifyError(err)
+	}
+	if restoredSum != originSum {
+		return verifyErrorf("sha256 不一致: 原始 %s，恢复 %s", originSum, restoredSum)
+	}
+
+	data := sha256SumLine(originSum, origin) + sha256SumLine(restoredSum, restored)
+	if err := writeFileAtomic(path, []byte(data), 0644); err != nil {
+		return fmt.Errorf("写 %s 失败: %w", path, err)
+	}
+	fmt.Printf("sha256 校验和已写入: %s（可用 sha256sum -c 校验）\n", path)
+	return nil
+}
+
+// 与 GNU sha256sum 相同：文件名含反斜杠或换行时转义，并在行首加反斜杠
+func sha256SumLine(sum, name string) string {
+	if strings.ContainsAny(name, "\\\n") {
+		name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		return fmt.Sprintf("\\%s  %s\n", sum, name)
+	}
+	return fmt.Sprintf("%s  %s\n", sum, name)
+}
+
+// 解析 sha256sum 的输出：每行 "<哈希>  <文件名>"，二进制模式是 "<哈希> *<文件名>"，
+// 行首反斜杠表示文件名经过转义。空行和 # 开头的注释跳过，返回文件名 -> 哈希（小写）
+func parseSHA256Sums(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sums := map[string]string{}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+		if len(line) < 66 || line[64] != ' ' || (line[65] != ' ' && line[65] != '*') {
+			return nil, fmt.Errorf("%s 第 %d 行格式不对，应为 sha256sum 的输出", path, n+1)
+		}
+		sum := strings.ToLower(line[:64])
+		if _, err := hex.DecodeString(sum); err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行不是有效的 sha256: %q", path, n+1, line[:64])
+		}
+		name := line[66:]
+		if escaped {
+			name = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(name)
+		}
+		if prev, ok := sums[name]; ok && prev != sum {
+			return nil, fmt.Errorf("%s 中 %s 有两个不同的哈希", path, name)
+		}
+		sums[name] = sum
+	}
+	if len(sums) == 0 {
+		return nil, fmt.Errorf("%s 中没有任何校验和", path)
+	}
+	return sums, nil
+}
+
+// 依次按输出路径、输出文件名、原始文件名查找；文件里只有一条记录时直接用它
+func expectedSHA256For(sums map[string]string, output, origin string) (string, string, bool) {
+	for _, name := range []string{output, filepath.Base(output), origin, filepath.Base(origin)} {
+		if sum, ok := sums[name]; ok {
+			return sum, name, true
+		}
+	}
+	if len(sums) == 1 {
+		for name, sum := range sums {
+			return sum, name, true
+		}
+	}
+	return "", "", false
+}
+
+func verifyExpectedHashes(path, restored, origin string) error {
+	sums, err := parseSHA256Sums(path)
+	if err != nil {
+		return configError(fmt.Errorf("读取 --expected-hashes 失败: %w", err))
+	}
+	want, name, ok := expectedSHA256For(sums, restored, origin)
+	if !ok {
+		return configError(fmt.Errorf("%s 中找不到 %s 或 %s 的校验和", path, restored, origin))
+	}
+	got, err := fileSHA256(restored)
+	if err != nil {
+		return verifyError(err)
+	}
+	if got != want {
+		return verifyErrorf("%s 的 sha256 与 %s 中 %s 的记录不一致: 期望 %s，实际 %s", restored, path, name, want, got)
+	}
+	fmt.Printf("sha256 与 %s 中 %s 的记录一致: %s\n", path, name, got)
+	return nil
+}
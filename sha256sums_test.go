@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// sha256sum 文本模式的一行："<64 位十六进制>  <文件名>"
+var sha256SumRe = regexp.MustCompile(`^([0-9a-f]{64})  (.+)$`)
+
+func TestSHA256SumsVerifiesWithStandardFormat(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	src := filepath.Join(dir, "src.bin")
+	data := writeTestFile(t, src, 2500, 5)
+	sha256SumsPath = filepath.Join(dir, "SHA256SUMS")
+	uploadTestFile(t, src)
+
+	raw, err := os.ReadFile(sha256SumsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	want := hex.EncodeToString(sum[:])
+	lines := strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("应有原始文件和恢复文件两行，得到 %q", raw)
+	}
+	for i, line := range lines {
+		m := sha256SumRe.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("第 %d 行不是 sha256sum 格式: %q", i+1, line)
+		}
+		if m[1] != want {
+			t.Fatalf("%s 的哈希 %s，期望 %s", m[2], m[1], want)
+		}
+		if i == 0 && m[2] != src {
+			t.Fatalf("第一行应引用原始文件名 %s，得到 %s", src, m[2])
+		}
+	}
+
+	if _, err := exec.LookPath("sha256sum"); err != nil {
+		return
+	}
+	if out, err := exec.Command("sha256sum", "-c", sha256SumsPath).CombinedOutput(); err != nil {
+		t.Fatalf("sha256sum -c 失败: %v\n%s", err, out)
+	}
+}
+
+// download --expected-hashes：按原始文件名在 SHA256SUMS 里找到记录，一致时通过，不一致时以校验错误退出
+func TestDownloadExpectedHashes(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3500, 1000)
+	sum := sha256.Sum256(data)
+	good := hex.EncodeToString(sum[:])
+	other := strings.Repeat("ab", 32)
+	dir := t.TempDir()
+
+	for _, c := range []struct {
+		name string
+		sums string
+		ok   bool
+	}{
+		{"match", "# 备份时生成\n" + other + "  other.bin\n" + strings.ToUpper(good) + "  src.bin\n", true},
+		{"binary mode", good + " *src.bin\n", true},
+		{"mismatch", other + "  src.bin\n" + good + "  other.bin\n", false},
+	} {
+		sumsPath := filepath.Join(dir, strings.ReplaceAll(c.name, " ", "_")+".SHA256SUMS")
+		if err := os.WriteFile(sumsPath, []byte(c.sums), 0644); err != nil {
+			t.Fatal(err)
+		}
+		dlManifests = []string{manifest}
+		dlOutput = filepath.Join(dir, c.name+".out")
+		dlExpectedHashes = sumsPath
+		err := restoreFromManifest()
+		if c.ok {
+			if err != nil {
+				t.Fatalf("%s: 校验和一致时应通过: %v", c.name, err)
+			}
+			assertFileContent(t, dlOutput, data)
+			continue
+		}
+		if err == nil || exitCode(err) != ExitVerify || !strings.Contains(err.Error(), "期望 "+other) {
+			t.Fatalf("%s: 校验和不一致时应以校验错误退出，实际 %v", c.name, err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bad.SHA256SUMS"), []byte("not a checksum line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dlExpectedHashes = filepath.Join(dir, "bad.SHA256SUMS")
+	dlOutput = filepath.Join(dir, "bad.out")
+	if err := restoreFromManifest(); err == nil || exitCode(err) != ExitConfig {
+		t.Fatalf("格式不对的校验和文件应报参数错误，实际 %v", err)
+	}
+}
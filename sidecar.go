@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// --sidecar-hashes：为每个分片写一个 <分片文件名>.sha256，格式与 sha256sum 输出相同，
+// 和分片文件放在同一目录时可以直接用 sha256sum -c 校验
+var sidecarHashes bool
+
+// 上传时的分片在临时目录里，结束就删掉，sidecar 写到原文件旁的 <原文件>.sidecars/ 目录
+func sidecarDir(outBase string) string {
+	return outBase + ".sidecars"
+}
+
+// 在 dir 中写 path 对应的 sidecar。分片哈希本来就是 sha256 时直接复用，否则重新读一遍分片文件
+func writeSidecar(path, dir string, algo string, sum string) error {
+	if algo != "sha256" || sum == "" {
+		var err error
+		if sum, err = fileFragmentDigest("sha256", path); err != nil {
+			return err
+		}
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	return os.WriteFile(filepath.Join(dir, filepath.Base(path)+".sha256"), []byte(line), 0644)
+}
+
+// 切分后为每个本地分片写 sidecar；--content-addressed 去重后共用文件的分片只写一次
+func writeFragmentSidecars(frags []Fragment, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	done := map[string]bool{}
+	for _, frag := range frags {
+		if done[frag.Path] {
+			continue
+		}
+		done[frag.Path] = true
+		if err := writeSidecar(frag.Path, dir, fragmentHashAlgo, frag.Hash); err != nil {
+			return fmt.Errorf("分片 %d 写 sha256 sidecar 失败: %w", frag.Index+1, err)
+		}
+	}
+	fmt.Printf("已为 %d 个分片文件写入 .sha256 sidecar: %s\n", len(done), dir)
+	return nil
+}
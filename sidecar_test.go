@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// --sidecar-hashes：上传结束后临时分片已删除，sidecar 仍留在 <文件>.sidecars/ 里且摘要正确；
+// download --output-dir 时 sidecar 写在输出的分片旁边
+func TestSidecarHashes(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	sidecarHashes = true
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 2500, 8)
+	m := uploadTestFile(t, path)
+
+	check := func(dir string, frag ManifestFragment) {
+		t.Helper()
+		name := fragmentFileName(frag.Index, len(m.Fragments))
+		got, err := os.ReadFile(filepath.Join(dir, name+".sha256"))
+		if err != nil {
+			t.Fatalf("缺少分片 %d 的 sidecar: %v", frag.Index, err)
+		}
+		sum, _ := fragmentDigest("sha256", data[frag.Offset:frag.Offset+frag.Size])
+		if want := fmt.Sprintf("%s  %s\n", sum, name); string(got) != want {
+			t.Fatalf("sidecar 内容 %q，期望 %q", got, want)
+		}
+	}
+	for _, frag := range m.Fragments {
+		check(sidecarDir(path), frag)
+	}
+
+	dlManifests = []string{manifestPath}
+	dlOutputDir = filepath.Join(dir, "frags")
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	for _, frag := range m.Fragments {
+		check(dlOutputDir, frag)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	signManifest bool   // --sign-manifest：用上传私钥给 manifest 签名
+	expectSigner string // download --expect-signer：要求 manifest 由该地址签名
+)
+
+// 签名覆盖的规范字节：去掉签名字段本身后的 JSON。
+// version 也清零，以后的程序迁移 manifest 版本号时签名仍然有效（新版本只会增加可选字段）
+func manifestSigningBytes(m *Manifest) ([]byte, error) {
+	c := *m
+	c.Signer, c.Signature = "", ""
+	c.Version = 0
+	return json.Marshal(&c)
+}
+
+// 用 ECDSA(secp256k1) 对规范字节的 keccak256 签名，记录签名和签名者地址
+func signManifestWith(m *Manifest, key string) error {
+	prv, err := crypto.HexToECDSA(strings.TrimPrefix(key, "0x"))
+	if err != nil {
+		return fmt.Errorf("私钥无效: %w", err)
+	}
+	m.Signer = crypto.PubkeyToAddress(prv.PublicKey).Hex()
+	data, err := manifestSigningBytes(m)
+	if err != nil {
+		return err
+	}
+	sig, err := crypto.Sign(crypto.Keccak256(data), prv)
+	if err != nil {
+		return err
+	}
+	m.Signature = "0x" + hex.EncodeToString(sig)
+	return nil
+}
+
+// 校验签名：有签名就必须有效；给了 expect 时还要求签名存在且签名者一致
+func verifyManifestSignature(m *Manifest, expect string) error {
+	if m.Signature == "" {
+		if expect != "" {
+			return fmt.Errorf("manifest %s 没有签名，但要求由 %s 签名", m.FileName, expect)
+		}
+		return nil
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(m.Signature, "0x"))
+	if err != nil || len(sig) != 65 {
+		return fmt.Errorf("manifest %s 的签名格式无效", m.FileName)
+	}
+	data, err := manifestSigningBytes(m)
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.SigToPub(crypto.Keccak256(data), sig)
+	if err != nil {
+		return fmt.Errorf("manifest %s 签名无法恢复公钥: %w", m.FileName, err)
+	}
+	signer := crypto.PubkeyToAddress(*pub)
+	if signer != common.HexToAddress(m.Signer) {
+		return fmt.Errorf("manifest %s 签名校验失败: 内容被改动或签名者不是 %s", m.FileName, m.Signer)
+	}
+	if expect != "" && signer != common.HexToAddress(expect) {
+		return fmt.Errorf("manifest %s 由 %s 签名，不是期望的 %s", m.FileName, signer.Hex(), expect)
+	}
+	fmt.Printf("manifest %s 签名有效，签名者 %s\n", m.FileName, signer.Hex())
+	return nil
+}
+
+// 写 manifest 前按需签名
+func maybeSignManifest(m *Manifest) error {
+	if !signManifest {
+		return nil
+	}
+	return signManifestWith(m, privateKey)
+}
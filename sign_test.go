@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	testKey      = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	otherTestKey = "0x8f2a55949038a9610f50fb23b5883af3b4ecb3c3bb792cbcefbd1542c692be63"
+)
+
+func keyAddress(t *testing.T, key string) string {
+	t.Helper()
+	prv, err := crypto.HexToECDSA(strings.TrimPrefix(key, "0x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypto.PubkeyToAddress(prv.PublicKey).Hex()
+}
+
+// 上传并用 testKey 签名，返回签过名的 manifest
+func signedTestManifest(t *testing.T) *Manifest {
+	t.Helper()
+	dir := setupTest(t)
+	fragmentSize = 1000
+	privateKey = testKey
+	signManifest = true
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 2500, 0)
+	return uploadTestFile(t, path)
+}
+
+func TestManifestSignatureValid(t *testing.T) {
+	m := signedTestManifest(t)
+	if m.Signature == "" || m.Signer != keyAddress(t, testKey) {
+		t.Fatalf("manifest 签名者 %q，期望 %s", m.Signer, keyAddress(t, testKey))
+	}
+	if err := verifyManifestSignature(m, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyManifestSignature(m, keyAddress(t, testKey)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManifestSignatureTampered(t *testing.T) {
+	m := signedTestManifest(t)
+	m.Fragments[1].Root = "0x" + strings.Repeat("ab", 32)
+	err := verifyManifestSignature(m, "")
+	if err == nil || !strings.Contains(err.Error(), "签名校验失败") {
+		t.Fatalf("改动分片 root 后签名应校验失败，实际 %v", err)
+	}
+
+	// 只换签名者地址也不行
+	m = signedTestManifest(t)
+	m.Signer = keyAddress(t, otherTestKey)
+	if err := verifyManifestSignature(m, ""); err == nil {
+		t.Fatal("签名者地址被替换后签名应校验失败")
+	}
+}
+
+func TestManifestSignatureWrongSigner(t *testing.T) {
+	m := signedTestManifest(t)
+	err := verifyManifestSignature(m, keyAddress(t, otherTestKey))
+	if err == nil || !strings.Contains(err.Error(), "不是期望的") {
+		t.Fatalf("签名者不是 --expect-signer 时应报错，实际 %v", err)
+	}
+
+	m.Signature, m.Signer = "", ""
+	if err := verifyManifestSignature(m, keyAddress(t, testKey)); err == nil {
+		t.Fatal("要求签名但 manifest 没有签名时应报错")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var slowThreshold time.Duration // 单个分片上传/下载超过这个时长就在汇总里标出来，0 表示不检查
+
+type slowFragment struct {
+	Phase    string // "上传" / "下载"
+	Index    int
+	Root     string
+	Node     string // download --node 指定的节点；上传和经 indexer 的下载由 SDK 选节点，记录不到
+	Duration time.Duration
+}
+
+// 汇总里先上传后下载
+var slowPhaseRank = map[string]int{"上传": 0, "下载": 1}
+
+// 记录超过 --slow-threshold 的分片，方便定位有问题的存储节点；上传是并发的，需要加锁
+type slowFragments struct {
+	mu    sync.Mutex
+	items []slowFragment
+}
+
+var slowReport = &slowFragments{}
+
+func (s *slowFragments) record(phase string, index int, root string, d time.Duration) {
+	if slowThreshold <= 0 || d <= slowThreshold {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it := slowFragment{Phase: phase, Index: index, Root: root, Duration: d}
+	if phase == "下载" {
+		it.Node = dlNode
+	}
+	s.items = append(s.items, it)
+}
+
+func (s *slowFragments) print() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.items) == 0 {
+		return
+	}
+
+	sort.Slice(s.items, func(i, j int) bool {
+		if s.items[i].Phase != s.items[j].Phase {
+			return slowPhaseRank[s.items[i].Phase] < slowPhaseRank[s.items[j].Phase]
+		}
+		return s.items[i].Index < s.items[j].Index
+	})
+	fmt.Printf("\n=== 慢分片（超过 %s）===\n", slowThreshold)
+	for _, it := range s.items {
+		// 阈值可以小于 1 秒，按毫秒取整，免得超过阈值的分片显示成 0s
+		line := fmt.Sprintf("%s 分片 %02d 耗时 %s，root: %s", it.Phase, it.Index+1, it.Duration.Round(time.Millisecond), it.Root)
+		if it.Node != "" {
+			line += "，节点: " + it.Node
+		}
+		fmt.Println(line)
+	}
+}
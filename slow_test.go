@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 指定的分片上传时人为变慢
+type slowStorage struct {
+	fakeStorage
+	slow  string // 本地文件名包含这个字符串的分片会变慢
+	delay time.Duration
+}
+
+func (s slowStorage) Upload(path string) (string, error) {
+	if strings.Contains(filepath.Base(path), s.slow) {
+		time.Sleep(s.delay)
+	}
+	return s.fakeStorage.Upload(path)
+}
+
+func TestSlowThresholdFlagsSlowUpload(t *testing.T) {
+	dir := setupTest(t)
+	storage = slowStorage{fakeStorage: testStore(), slow: "fragment_001", delay: 200 * time.Millisecond}
+	slowThreshold = 100 * time.Millisecond
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 3000, 0)
+	uploadTestFile(t, path)
+
+	var uploads []slowFragment
+	for _, it := range slowReport.items {
+		if it.Phase == "上传" {
+			uploads = append(uploads, it)
+		}
+	}
+	if len(uploads) != 1 || uploads[0].Index != 1 || uploads[0].Root == "" {
+		t.Fatalf("期望只标出分片 1 的上传，实际 %+v", uploads)
+	}
+	if uploads[0].Duration < 200*time.Millisecond {
+		t.Fatalf("记录的耗时 %s 小于实际延迟", uploads[0].Duration)
+	}
+	out := captureStdout(t, slowReport.print)
+	if !strings.Contains(out, "上传 分片 02 耗时 "+uploads[0].Duration.Round(time.Millisecond).String()+"，root: "+uploads[0].Root) {
+		t.Fatalf("汇总应按毫秒列出分片 02 的耗时:\n%s", out)
+	}
+}
+
+// 汇总先上传后下载、同阶段按分片顺序；下载带上 --node 指定的节点
+func TestSlowReportOrderAndNode(t *testing.T) {
+	setupTest(t)
+	slowThreshold = 100 * time.Millisecond
+	slowReport.record("下载", 0, "0xd0", 250*time.Millisecond)
+	dlNode = "http://10.0.0.1:5678"
+	slowReport.record("下载", 1, "0xd1", 1500*time.Millisecond)
+	slowReport.record("上传", 2, "0xu2", 300*time.Millisecond)
+	slowReport.record("上传", 1, "0xu1", 99*time.Millisecond) // 没超过阈值
+
+	out := captureStdout(t, slowReport.print)
+	want := []string{
+		"上传 分片 03 耗时 300ms，root: 0xu2\n",
+		"下载 分片 01 耗时 250ms，root: 0xd0\n",
+		"下载 分片 02 耗时 1.5s，root: 0xd1，节点: http://10.0.0.1:5678\n",
+	}
+	last := -1
+	for _, line := range want {
+		i := strings.Index(out, line)
+		if i <= last {
+			t.Fatalf("汇总缺少或顺序不对 %q:\n%s", line, out)
+		}
+		last = i
+	}
+	if strings.Contains(out, "0xu1") {
+		t.Fatalf("没超过阈值的分片不应列出:\n%s", out)
+	}
+}
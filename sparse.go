@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	sparseRestore bool // --sparse-restore：预分配输出文件，各分片按 offset 直接写入
+	strictOrder   bool // --strict-order：恢复后按 offset 逐个重算分片哈希，确认分片写在正确位置
+)
+
+// 先把输出文件扩到完整大小，分片下载完成后用 WriteAt 写到自己的 offset，
+// 不要求按顺序，多个分片可以并行下载，也不需要单独的合并阶段
+func downloadSparse(m *Manifest, outputPath string, workers int) error {
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := out.Truncate(m.FileSize); err != nil {
+		return fmt.Errorf("预分配 %s 失败: %w", outputPath, err)
+	}
+
+	errs := make([]error, len(m.Fragments))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = downloadFragmentAt(m, m.Fragments[i], out)
+			}
+		}()
+	}
+	for i := range m.Fragments {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downloadFragmentAt(m *Manifest, frag ManifestFragment, out *os.File) error {
+	fmt.Printf("[%d/%d] 正在下载分片（offset %d），root: %s\n", frag.Index+1, len(m.Fragments), frag.Offset, frag.Root)
+	start := time.Now()
+	tmpPath, err := downloadFragment(m, frag)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	slowReport.record("下载", frag.Index, frag.Root, time.Since(start))
+
+	if err := copyFragmentData(io.NewOffsetWriter(out, frag.Offset), tmpPath, frag.Size, frag.storedSize()); err != nil {
+		return fmt.Errorf("分片 %d: %w", frag.Index+1, err)
+	}
+	fmt.Printf("分片 %d 已写入 offset %d，%d bytes\n", frag.Index+1, frag.Offset, frag.Size)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 下载 slow 这个 root 时人为变慢，并记录下载完成的顺序
+type orderedDownloadStorage struct {
+	fakeStorage
+	slow string
+
+	mu   sync.Mutex
+	done []string
+}
+
+func (s *orderedDownloadStorage) Download(root string, indexer string) (string, error) {
+	if root == s.slow {
+		time.Sleep(100 * time.Millisecond)
+	}
+	path, err := s.fakeStorage.Download(root, indexer)
+	s.mu.Lock()
+	s.done = append(s.done, root)
+	s.mu.Unlock()
+	return path, err
+}
+
+func TestWriteAtFragmentsOutOfOrder(t *testing.T) {
+	manifest, data := uploadForDownload(t, 3500, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outPath := filepath.Join(t.TempDir(), "out.bin")
+	out, err := os.Create(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Truncate(m.FileSize); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{3, 1, 0, 2} {
+		if err := downloadFragmentAt(m, m.Fragments[i], out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Close()
+	assertFileContent(t, outPath, data)
+}
+
+// 第一个分片最后才下载完，--sparse-restore 仍然得到完整文件
+func TestSparseRestoreParallel(t *testing.T) {
+	manifest, data := uploadForDownload(t, 4000, 1000)
+	m, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &orderedDownloadStorage{fakeStorage: testStore(), slow: m.Fragments[0].Root}
+	storage = s
+	sparseRestore = true
+	downloadConcurrency = 4
+	outPath := filepath.Join(t.TempDir(), "out.bin")
+	if err := restoreFile(m, outPath); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, outPath, data)
+	if len(s.done) != 4 || s.done[len(s.done)-1] != m.Fragments[0].Root {
+		t.Fatalf("分片 1 应最后下载完成（乱序写入），实际顺序 %v", s.done)
+	}
+}
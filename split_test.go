@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 并发、倒序产生分片，排序后必须按 Index 排列
+func TestOrderFragmentsOutOfOrderProduction(t *testing.T) {
+	const n = 16
+	var mu sync.Mutex
+	var frags []Fragment
+	var wg sync.WaitGroup
+	for i := n - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mu.Lock()
+			frags = append(frags, Fragment{Index: i, Offset: int64(i) * 100, Size: 100})
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	got, err := orderFragments(frags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range got {
+		if f.Index != i || f.Offset != int64(i)*100 {
+			t.Fatalf("位置 %d 上是分片 %d（offset %d）", i, f.Index, f.Offset)
+		}
+	}
+}
+
+func TestOrderFragmentsRejectsGap(t *testing.T) {
+	_, err := orderFragments([]Fragment{{Index: 2}, {Index: 0}, {Index: 3}})
+	if err == nil || !strings.Contains(err.Error(), "不连续") {
+		t.Fatalf("期望序号不连续的错误，实际 %v", err)
+	}
+}
+
+// 小分片 + 大文件：切分前就按 --max-fragments 拦下，并给出建议的分片大小
+func TestMaxFragmentsGuard(t *testing.T) {
+	dir := setupTest(t)
+	s := useCountingStorage()
+	path := filepath.Join(dir, "huge.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Truncate(1 << 30) // 稀疏文件，不占磁盘
+	f.Close()
+
+	filePath, fragmentSize = path, 4096
+	err = run()
+	if err == nil || exitCode(err) != ExitConfig {
+		t.Fatalf("期望参数错误，实际 %v", err)
+	}
+	for _, want := range []string{"262144 个分片", "--max-fragments 10000", "至少 107375"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("错误信息缺少 %q: %v", want, err)
+		}
+	}
+	if s.uploadCount() != 0 {
+		t.Fatalf("超过上限时不应上传任何分片，实际上传了 %d 个", s.uploadCount())
+	}
+}
+
+// --pad-last：最后一个分片补零到完整大小上传，manifest 记录真实长度，恢复时去掉补零
+func TestPadLastRoundTrip(t *testing.T) {
+	dir := setupTest(t)
+	s := useCountingStorage()
+	padLast, fragmentSize = true, 1000
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 2500, 1)
+	m := uploadTestFile(t, path)
+
+	last := m.Fragments[len(m.Fragments)-1]
+	if last.Size != 500 || last.Padding != 500 || m.FileSize != 2500 {
+		t.Fatalf("最后一个分片: size %d padding %d，文件 %d", last.Size, last.Padding, m.FileSize)
+	}
+	stored, err := os.ReadFile(filepath.Join(s.dir, last.Root))
+	if err != nil || len(stored) != 1000 {
+		t.Fatalf("上传的最后一个分片应补零到 1000 bytes，实际 %d（%v）", len(stored), err)
+	}
+	assertFileContent(t, path+".restored", data)
+}
+
+// 不同 --io-buffer 切出的分片完全一致；分配的内存随缓冲区变化，与分片大小无关
+func TestSplitIOBufferSizes(t *testing.T) {
+	dir := setupTest(t)
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 3<<20+123, 9)
+
+	var want []Fragment
+	for _, size := range []int64{1 << 10, 7777, 64 << 10, 4 << 20} {
+		ioBufferSize = size
+		out := filepath.Join(dir, fmt.Sprintf("buf-%d", size))
+		os.Mkdir(out, 0755)
+		frags, err := splitFile(path, out, 1<<20, "sha256", io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, frag := range frags {
+			got, _ := os.ReadFile(frag.Path)
+			if string(got) != string(data[frag.Offset:frag.Offset+frag.Size]) {
+				t.Fatalf("--io-buffer %d: 分片 %d 内容不对", size, i)
+			}
+			if want != nil && (frag.Hash != want[i].Hash || frag.Size != want[i].Size) {
+				t.Fatalf("--io-buffer %d: 分片 %d 与其他缓冲区大小切出的不一致", size, i)
+			}
+		}
+		if want == nil {
+			want = frags
+		}
+	}
+
+	allocated := func(buf int64) uint64 {
+		ioBufferSize = buf
+		out := t.TempDir()
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		if _, err := splitFile(path, out, 2<<20, "sha256", io.Discard); err != nil {
+			t.Fatal(err)
+		}
+		runtime.ReadMemStats(&after)
+		return after.TotalAlloc - before.TotalAlloc
+	}
+	small, large := allocated(64<<10), allocated(4<<20)
+	if small >= 1<<20 {
+		t.Fatalf("--io-buffer 64KB 切 2MB 的分片分配了 %d bytes，不应随分片大小增长", small)
+	}
+	if large < 4<<20 {
+		t.Fatalf("--io-buffer 4MB 只分配了 %d bytes", large)
+	}
+}
+
+// 分片超过 999 个时序号按总数补位：文件名按字典序排列就是分片顺序
+func TestFragmentFileNamesSortLexically(t *testing.T) {
+	setupTest(t)
+	if got := fragmentFileName(7, 12); got != "fragment_007.dat" {
+		t.Fatalf("分片少时仍应补到 3 位，实际 %s", got)
+	}
+	if got := fragmentFileName(7, 12000); got != "fragment_00007.dat" {
+		t.Fatalf("12000 个分片应补到 5 位，实际 %s", got)
+	}
+
+	const total = 12000
+	names := make([]string, total)
+	for i := range names {
+		names[i] = fragmentFileName(i, total)
+	}
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	for i := range names {
+		if sorted[i] != names[i] {
+			t.Fatalf("字典序第 %d 个是 %s，期望 %s", i, sorted[i], names[i])
+		}
+	}
+
+	// 真实切分 1200 个分片：目录按文件名列出的顺序与分片序号一致
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 1200*10, 3)
+	out := filepath.Join(dir, "frags")
+	os.Mkdir(out, 0755)
+	frags, err := splitFile(path, out, 10, "sha256", io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(frags) {
+		t.Fatalf("切出 %d 个分片，目录里有 %d 个文件", len(frags), len(entries))
+	}
+	for i, e := range entries {
+		if filepath.Join(out, e.Name()) != frags[i].Path {
+			t.Fatalf("字典序第 %d 个文件是 %s，期望分片 %s", i, e.Name(), frags[i].Path)
+		}
+	}
+}
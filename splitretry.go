@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// --split-retries：切分到一半遇到磁盘错误（临时目录所在盘 I/O 出错、空间短暂不足等）时，
+// 换一个新的临时目录从头重新切分，间隔按 splitRetryBackoff 翻倍。
+// 源文件不存在、没有权限、参数错误、校验失败这类重试也不会好的错误直接返回
+var (
+	splitRetries      int
+	splitRetryBackoff = 5 * time.Second
+)
+
+// attempt 每次都要从头切分（包括重新计算整文件哈希）；重试前删掉 *tmpDir 并换成新建的目录
+func retrySplit(tmpDir *string, attempt func() error) error {
+	for i := 0; ; i++ {
+		err := attempt()
+		if err == nil || i >= splitRetries || !retryableSplitError(err) || runCtx.Err() != nil {
+			return err
+		}
+		wait := splitRetryBackoff << i
+		logrus.Warnf("切分失败（第 %d 次），%s 后换新的临时目录重新切分: %v", i+1, wait, err)
+		select {
+		case <-runCtx.Done():
+			return runCtx.Err()
+		case <-time.After(wait):
+		}
+		os.RemoveAll(*tmpDir)
+		dir, derr := makeTempDir("0g-split-*")
+		if derr != nil {
+			return derr
+		}
+		*tmpDir = dir
+	}
+}
+
+func retryableSplitError(err error) bool {
+	var e *ExitError
+	if errors.As(err, &e) && e.Code != ExitUpload {
+		return false // 参数错误、前缀校验失败等
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission),
+		errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
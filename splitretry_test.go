@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 第一次切分遇到 I/O 错误：删掉旧的临时目录，在新目录里重新切分成功
+func TestSplitRetryAfterIOError(t *testing.T) {
+	dir := setupTest(t)
+	splitRetries = 2
+	prev := splitRetryBackoff
+	splitRetryBackoff = time.Millisecond
+	t.Cleanup(func() { splitRetryBackoff = prev })
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 2500, 8)
+
+	tmpDir, err := makeTempDir("0g-split-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := tmpDir
+	defer func() { os.RemoveAll(tmpDir) }()
+
+	var attempts int
+	var frags []Fragment
+	err = retrySplit(&tmpDir, func() error {
+		attempts++
+		if attempts == 1 {
+			os.WriteFile(filepath.Join(tmpDir, fragmentFileName(0, 3)), []byte("partial"), 0644)
+			return uploadError(&fs.PathError{Op: "write", Path: filepath.Join(tmpDir, fragmentFileName(1, 3)), Err: syscall.EIO})
+		}
+		var err error
+		frags, err = splitFile(path, tmpDir, 1000, "sha256", io.Discard)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("重试后应切分成功: %v", err)
+	}
+	if attempts != 2 || len(frags) != 3 {
+		t.Fatalf("期望切分 2 次得到 3 个分片，实际 %d 次、%d 个分片", attempts, len(frags))
+	}
+	if tmpDir == first {
+		t.Fatal("重试应换一个新的临时目录")
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Fatalf("失败的临时目录应已删除: %v", err)
+	}
+	for _, frag := range frags {
+		if filepath.Dir(frag.Path) != tmpDir {
+			t.Fatalf("分片 %s 不在新的临时目录 %s 里", frag.Path, tmpDir)
+		}
+	}
+}
+
+// 源文件不存在、参数错误不重试；I/O 错误重试次数用完后返回最后的错误
+func TestSplitRetryGivesUp(t *testing.T) {
+	setupTest(t)
+	splitRetries = 2
+	prev := splitRetryBackoff
+	splitRetryBackoff = time.Millisecond
+	t.Cleanup(func() { splitRetryBackoff = prev })
+	tmpDir := t.TempDir()
+
+	for _, fail := range []error{
+		&fs.PathError{Op: "open", Path: "missing.bin", Err: syscall.ENOENT},
+		configError(fs.ErrInvalid),
+	} {
+		attempts := 0
+		err := retrySplit(&tmpDir, func() error { attempts++; return fail })
+		if err != fail || attempts != 1 {
+			t.Fatalf("%v 不应重试：切分了 %d 次，返回 %v", fail, attempts, err)
+		}
+	}
+
+	attempts := 0
+	ioErr := uploadError(&fs.PathError{Op: "write", Path: "x", Err: syscall.EIO})
+	err := retrySplit(&tmpDir, func() error { attempts++; return ioErr })
+	if err != ioErr || attempts != 3 {
+		t.Fatalf("--split-retries 2 应共切分 3 次后返回错误，实际 %d 次，%v", attempts, err)
+	}
+	os.RemoveAll(tmpDir)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// 直接调 indexer / 存储节点的 JSON-RPC 查询 root 的存储状态。
+// 使用 endpointClient，--proxy / --insecure-skip-verify / --http-timeout 对这些请求生效。
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func rpcCall(url string, method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	resp, err := endpointClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回 HTTP %d", method, resp.StatusCode)
+	}
+
+	var r rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("%s 响应解析失败: %w", method, err)
+	}
+	if r.Error != nil {
+		return fmt.Errorf("%s 失败: %s", method, r.Error.Message)
+	}
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
+
+type fileLocation struct {
+	URL string `json:"url"`
+}
+
+// indexer 报告的持有该 root 的存储节点
+func fileLocations(indexer string, root string) ([]fileLocation, error) {
+	var locs []fileLocation
+	if err := rpcCall(indexer, "indexer_getFileLocations", []interface{}{root}, &locs); err != nil {
+		return nil, err
+	}
+	return locs, nil
+}
+
+type nodeFileInfo struct {
+	Finalized bool `json:"finalized"`
+	Tx        struct {
+		DataMerkleRoot string          `json:"dataMerkleRoot"`
+		Size           int64           `json:"size"`
+		Seq            uint64          `json:"seq"`
+		Data           json.RawMessage `json:"data"` // submission 的 tags
+	} `json:"tx"` // 链上提交记录（Flow 合约中的 submission）
+}
+
+// 存储节点上该 root 的文件信息；节点没有这个文件时返回 nil
+func getNodeFileInfo(nodeURL string, root string) (*nodeFileInfo, error) {
+	var info *nodeFileInfo
+	if err := rpcCall(nodeURL, "zgs_getFileInfo", []interface{}{root}, &info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// 查询 root 的链上提交信息：依次询问 indexer 报告的节点，返回第一个有记录的结果；都没有时返回 nil
+func chainFileInfo(root string) (*nodeFileInfo, error) {
+	locs, err := fileLocations(indexerURL, root)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, loc := range locs {
+		info, err := getNodeFileInfo(loc.URL, root)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if info != nil {
+			return info, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// 分片是否已经完整存储（至少一个节点报告 finalized），而不只是交易已提交
+func fragmentStored(root string) (bool, error) {
+	locs, err := fileLocations(indexerURL, root)
+	if err != nil {
+		return false, err
+	}
+	var lastErr error
+	for _, loc := range locs {
+		info, err := getNodeFileInfo(loc.URL, root)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if info != nil && info.Finalized {
+			return true, nil
+		}
+	}
+	return false, lastErr
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// --manifest -：标准输出只留给 manifest JSON，方便直接接管道。
+// 进度等所有 fmt.Print 输出（包括 SDK 打印的内容）改写到标准错误，日志本来就在标准错误
+const stdoutManifest = "-"
+
+var manifestOut io.Writer = os.Stdout
+
+func reserveStdoutForManifest() {
+	manifestOut = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+func writeManifestStdout(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(manifestOut, "%s\n", data); err != nil {
+		return fmt.Errorf("manifest 写到标准输出失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --manifest -：标准输出只有 manifest JSON，可以整体反序列化；进度输出都在标准错误
+func TestManifestToStdout(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 3500, 2)
+	filePath, manifestPath = path, stdoutManifest
+
+	stderr, err := os.Create(filepath.Join(dir, "stderr.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevStderr := os.Stderr
+	os.Stderr = stderr
+	t.Cleanup(func() {
+		os.Stderr = prevStderr
+		manifestOut = os.Stdout
+		stderr.Close()
+	})
+
+	var runErr error
+	out := captureStdout(t, func() {
+		reserveStdoutForManifest()
+		runErr = run()
+	})
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	var m Manifest
+	if err := json.Unmarshal([]byte(out), &m); err != nil {
+		t.Fatalf("标准输出不是单个 manifest JSON: %v\n%s", err, out)
+	}
+	if len(m.Fragments) != 4 || m.FileSize != int64(len(data)) {
+		t.Fatalf("manifest 内容不对: %d 个分片，%d bytes", len(m.Fragments), m.FileSize)
+	}
+	restored := filepath.Join(dir, "out.bin")
+	if err := restoreFile(&m, restored); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, restored, data)
+
+	progress, _ := os.ReadFile(stderr.Name())
+	if !strings.Contains(string(progress), "manifest 已写到标准输出") {
+		t.Fatalf("进度输出应在标准错误:\n%s", progress)
+	}
+	if _, err := os.Stat(stdoutManifest); err == nil {
+		t.Fatal("不应写出名为 - 的文件")
+	}
+}
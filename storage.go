@@ -0,0 +1,26 @@
+package main
+
+// Storage 是上传/下载单个分片的后端。默认走 0g-storage-client，
+// 测试时换成 fakeStorage（storage_test.go），整条流程不需要网络和私钥
+type Storage interface {
+	Upload(path string) (root string, err error)
+	Download(root string, indexer string) (tmpPath string, err error)
+}
+
+type sdkStorage struct{}
+
+func (sdkStorage) Upload(path string) (string, error) { return uploadSingleFragment(path) }
+
+func (sdkStorage) Download(root string, indexer string) (string, error) {
+	return downloadRoot(root, indexer)
+}
+
+// 查询 root 当前能否下载，不真正下载数据
+func (sdkStorage) Available(root string) (bool, error) { return fragmentStored(root) }
+
+var storage Storage = sdkStorage{}
+
+// 可选：能查询 root 是否可下载的后端实现它，reconstruct 据此挑选分片
+type availabilityChecker interface {
+	Available(root string) (bool, error)
+}
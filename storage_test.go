@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 把分片存到本地目录，root 取内容的 sha256，同样的输入总是得到同样的 root
+type fakeStorage struct {
+	dir string
+}
+
+func (s fakeStorage) Upload(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	root := "0x" + hex.EncodeToString(sum[:])
+	return root, os.WriteFile(filepath.Join(s.dir, root), data, 0644)
+}
+
+func (s fakeStorage) Available(root string) (bool, error) {
+	_, err := os.Stat(filepath.Join(s.dir, root))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s fakeStorage) Download(root string, indexer string) (string, error) {
+	in, err := os.Open(filepath.Join(s.dir, root))
+	if err != nil {
+		return "", fmt.Errorf("root %s not found", root)
+	}
+	defer in.Close()
+	out, err := os.CreateTemp("", "0g-download-*.dat")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// 分片保存在内存里的后端，root 取内容的 sha256；实现 bytesStorage，配合 uploadBytes / downloadBytes 不落盘
+type memStorage struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func newMemStorage() *memStorage { return &memStorage{blobs: map[string][]byte{}} }
+
+func (s *memStorage) UploadBytes(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	root := "0x" + hex.EncodeToString(sum[:])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[root] = append([]byte(nil), data...)
+	return root, nil
+}
+
+func (s *memStorage) DownloadBytes(root string, indexer string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.blobs[root]
+	if !ok {
+		return nil, fmt.Errorf("root %s not found", root)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *memStorage) Available(root string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.blobs[root]
+	return ok, nil
+}
+
+func (s *memStorage) Upload(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return s.UploadBytes(data)
+}
+
+func (s *memStorage) Download(root string, indexer string) (string, error) {
+	data, err := s.DownloadBytes(root, indexer)
+	if err != nil {
+		return "", err
+	}
+	out, err := os.CreateTemp("", "0g-download-*.dat")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	if _, err := out.Write(data); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// 记录每次调用的 fakeStorage，测试据此断言上传 / 下载了哪些分片
+type countingStorage struct {
+	fakeStorage
+	mu        sync.Mutex
+	uploads   []string // 上传的本地文件内容的 root，按调用顺序
+	downloads []string // 下载的 root，按调用顺序
+}
+
+func (s *countingStorage) Upload(path string) (string, error) {
+	root, err := s.fakeStorage.Upload(path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads = append(s.uploads, root)
+	return root, err
+}
+
+func (s *countingStorage) Download(root string, indexer string) (string, error) {
+	s.mu.Lock()
+	s.downloads = append(s.downloads, root)
+	s.mu.Unlock()
+	return s.fakeStorage.Download(root, indexer)
+}
+
+func (s *countingStorage) uploadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.uploads)
+}
+
+func (s *countingStorage) downloadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.downloads)
+}
+
+// 每个测试开始时调用：重新注册 flags 把全局参数恢复为默认值，
+// 存储换成临时目录里的 fakeStorage。返回测试专用的临时目录
+func setupTest(t *testing.T) string {
+	t.Helper()
+	newRootCmd()
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache")) // 哈希缓存不写到真实的用户缓存目录
+	store := filepath.Join(dir, "store")
+	if err := os.Mkdir(store, 0755); err != nil {
+		t.Fatal(err)
+	}
+	storage = fakeStorage{dir: store}
+	localRoot = contentRoot
+	indexerURL = "fake://indexer"
+	runCtx, cancelRun = context.Background(), func() {}
+	subUploads = &subFragmentSet{subs: map[int][]SubFragment{}}
+	nonces = &nonceLog{nonces: map[int]uint64{}}
+	fragmentUploadedAt = &uploadTimes{at: map[int]time.Time{}}
+	slowReport = &slowFragments{}
+	hooks = Hooks{}
+	webhook = &webhookNotifier{}
+	retriesUsed.Store(0)
+	assumedThroughput = 0
+	effectiveGasPrice = nil
+	dlFragmentSizeSet, compressSet = false, false
+	endpointClient = http.DefaultClient
+	return dir
+}
+
+// 和 fakeStorage / memStorage 一样取文件内容的 sha256，离线审计算出的 root 才能和上传得到的对上
+func contentRoot(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "0x" + hex.EncodeToString(sum[:]), nil
+}
+
+// 测试里用的 fakeStorage 目录（setupTest 之后）
+func testStore() fakeStorage {
+	switch s := storage.(type) {
+	case fakeStorage:
+		return s
+	case *countingStorage:
+		return s.fakeStorage
+	}
+	panic("storage 不是 fakeStorage")
+}
+
+// 换成会计数的 fakeStorage，目录不变
+func useCountingStorage() *countingStorage {
+	s := &countingStorage{fakeStorage: testStore()}
+	storage = s
+	return s
+}
+
+// 写一个内容可复现的测试文件：每个字节由位置和 seed 决定，不同位置的分片内容不同
+func writeTestFile(t *testing.T, path string, size int, seed byte) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7+i/251) ^ seed
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// 上传 path 并返回写出的 manifest；调用前由测试设置好 fragmentSize 等参数
+func uploadTestFile(t *testing.T, path string) *Manifest {
+	t.Helper()
+	filePath = path
+	if manifestPath == "" {
+		manifestPath = path + ".manifest.json"
+	}
+	if err := run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	m, err := loadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func assertFileContent(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("%s 内容不一致: %d bytes，期望 %d bytes", path, len(got), len(want))
+	}
+}
+
+// 运行 f 期间把 os.Stdout 换成管道，返回 f 打印的全部内容
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	defer func() {
+		os.Stdout = prev
+	}()
+	f()
+	w.Close()
+	return string(<-done)
+}
+
+// 假的 indexer + 存储节点（同一个 JSON-RPC 地址）：indexer_getFileLocations 把自己报告为持有节点，
+// zgs_getFileInfo 返回 fileInfo(root)（nil 表示节点上没有这个文件）
+func newFakeNodeServer(t *testing.T, fileInfo func(root string) *nodeFileInfo) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Params) != 1 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		root, _ := req.Params[0].(string)
+		var result interface{}
+		switch req.Method {
+		case "indexer_getFileLocations":
+			result = []fileLocation{{URL: srv.URL}}
+		case "zgs_getFileInfo":
+			result = fileInfo(root)
+		default:
+			http.Error(w, "unknown method", http.StatusBadRequest)
+			return
+		}
+		data, _ := json.Marshal(result)
+		json.NewEncoder(w).Encode(rpcResponse{Result: data})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
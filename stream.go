@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --dir --stream：tar 通过 io.Pipe 直接喂给切分，每切出一个分片就上传并删除，
+// 磁盘上最多只有一个分片，目录再大也不需要同样大的临时空间。
+// 分片必须按顺序产生，所以上传是串行的，也不支持需要完整源文件的选项
+func runStreamDir(outBase string) error {
+	if erasureSpec != "" || offsetsPath != "" || cdcMode || padLast || paranoid || resumeUpload || resumeManifest != "" || contentAddressed || dryRun {
+		return configError(fmt.Errorf("--stream 不支持 --erasure / --offsets / --cdc / --pad-last / --paranoid / --resume / --resume-manifest / --content-addressed / --dry-run"))
+	}
+	if fragmentSize <= 0 {
+		return configError(fmt.Errorf("--fragment-size 必须大于 0: %d", fragmentSize))
+	}
+	estimate, err := estimateStreamFragments(dirPath)
+	if err != nil {
+		return uploadError(fmt.Errorf("估算目录打包大小失败: %w", err))
+	}
+	if err := preflightUpload(estimate); err != nil {
+		return err
+	}
+
+	tmpDir, err := makeTempDir("0g-split-*")
+	if err != nil {
+		return uploadError(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(dirPath, pw))
+	}()
+	defer pr.Close() // 提前返回时让打包的 goroutine 退出
+
+	originHash, originSum := newOriginHash("") // tar 流没有对应的文件，不查缓存
+	r := io.TeeReader(ctxReader{runCtx, pr}, originHash)
+	buf := make([]byte, ioBufferSize) // 分片按 --io-buffer 分段写盘，内存占用和分片大小无关
+
+	var frags []Fragment
+	var roots []string
+	var offset int64
+	for i := 0; ; i++ {
+		frag, err := writeStreamFragment(tmpDir, i, offset, r, buf)
+		if err != nil {
+			return uploadError(err)
+		}
+		if frag.Size == 0 {
+			os.Remove(frag.Path)
+			break
+		}
+		if i >= maxFragments {
+			return configError(fmt.Errorf("目录打包后超过 --max-fragments %d 个分片，请调大 --fragment-size", maxFragments))
+		}
+		root, err := uploadStreamFragment(frag)
+		if err != nil {
+			return uploadError(err)
+		}
+		frags = append(frags, frag)
+		roots = append(roots, root)
+		offset += frag.Size
+		if frag.Size < fragmentSize {
+			break // tar 流已读完
+		}
+	}
+	fmt.Printf("\n=== 所有分片上传完成 ===\n目录打包后 %d bytes，共 %d 个分片\n", offset, len(frags))
+
+	originMD5 := originSum()
+	m := buildManifest(outBase, originMD5, frags, roots)
+	m.Archive = "tar"
+	if noVerify {
+		m.HashAlgo = ""
+	}
+	if err := writeManifest(m); err != nil {
+		return uploadError(fmt.Errorf("写 manifest 失败: %w", err))
+	}
+	if indexCSVPath != "" {
+		if err := writeIndexCSV(indexCSVPath, m); err != nil {
+			return uploadError(fmt.Errorf("写 %s 失败: %w", indexCSVPath, err))
+		}
+	}
+	if waitAvailable > 0 {
+		if err := waitForAvailability(m); err != nil {
+			return uploadError(err)
+		}
+	}
+
+	// 恢复时同样不落完整的 tar：分片按顺序经 io.Pipe 直接解包成目录，钩子拿到的是这个目录
+	restoredDir := strings.TrimSuffix(outBase, ".tar") + ".restored"
+	return runRestoreHooks(restoredDir, restoreStreamDir(m, restoredDir))
+}
+
+const tarBlock = 512
+
+// tar 流边打包边上传，上传前还不知道分片：按目录内容估算 tar 大小并切成同样大小的分片，
+// 只用于余额检查和花费确认。长文件名的 PAX 头等不计，误差在费用余量之内
+func estimateStreamFragments(dir string) ([]Fragment, error) {
+	size := int64(2 * tarBlock) // 结束标记
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		size += tarBlock
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += (info.Size() + tarBlock - 1) / tarBlock * tarBlock
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var frags []Fragment
+	for off := int64(0); off < size; off += fragmentSize {
+		frags = append(frags, Fragment{Index: len(frags), Offset: off, Size: min(fragmentSize, size-off)})
+	}
+	return frags, nil
+}
+
+// 按顺序下载分片写进 tar.Reader 解包到 dst，同时计算整文件 MD5；每个分片写完就删掉临时文件，
+// 磁盘上最多只有一个分片加上解出的目录
+func restoreStreamDir(m *Manifest, dst string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return downloadError(err)
+	}
+	pr, pw := io.Pipe()
+	h := md5.New()
+	done := make(chan error, 1)
+	go func() {
+		err := streamFragments(m, io.MultiWriter(pw, h))
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	failed, err := extractTar(pr, dst, preserveMetadata)
+	if err == nil {
+		_, err = io.Copy(io.Discard, pr) // tar 结束标记之后的补零也要读完，整文件 MD5 才对得上
+	}
+	pr.CloseWithError(err) // 解包出错时让下载的 goroutine 不再阻塞在写入上
+	if streamErr := <-done; streamErr != nil && !errors.Is(streamErr, err) {
+		return downloadError(streamErr) // 下载失败在先，解包看到的只是管道被关闭
+	}
+	if err != nil {
+		return downloadError(fmt.Errorf("解包失败: %w", err))
+	}
+	printExtracted(dst, failed)
+
+	if m.OriginHash == "" {
+		fmt.Println("\nmanifest 没有整文件哈希（上传时用了 --no-verify），跳过 MD5 校验")
+		return nil
+	}
+	restoredMD5 := hex.EncodeToString(h.Sum(nil))
+	fmt.Printf("\n恢复的 tar 流 MD5: %s\n", restoredMD5)
+	if restoredMD5 != m.OriginHash {
+		return verifyErrorf("MD5 不一致: 原始 %s，恢复 %s", m.OriginHash, restoredMD5)
+	}
+	fmt.Println("MD5 校验通过！目录 100% 完整恢复")
+	return nil
+}
+
+// 按顺序下载分片并写进 w，下载完的临时文件立即删除
+func streamFragments(m *Manifest, w io.Writer) error {
+	for i, frag := range m.Fragments {
+		fmt.Printf("[%d/%d] 正在下载 root: %s\n", i+1, len(m.Fragments), frag.Root)
+		start := time.Now()
+		tmpPath, err := downloadFragment(m, frag)
+		if err != nil {
+			return err
+		}
+		slowReport.record("下载", frag.Index, frag.Root, time.Since(start))
+		err = copyFragmentData(w, tmpPath, frag.Size, frag.storedSize())
+		os.Remove(tmpPath)
+		if err != nil {
+			return fmt.Errorf("分片 %d: %w", frag.Index+1, err)
+		}
+	}
+	return nil
+}
+
+// 从 tar 流读出最多 fragmentSize 字节写成分片文件，同时计算分片哈希；返回的 Size 为 0 表示流已结束
+func writeStreamFragment(tmpDir string, index int, offset int64, r io.Reader, buf []byte) (Fragment, error) {
+	h, err := newFragmentHasher(fragmentHashAlgo)
+	if err != nil {
+		return Fragment{}, err
+	}
+	path := filepath.Join(tmpDir, fragmentFileName(index, maxFragments))
+	f, err := os.Create(path)
+	if err != nil {
+		return Fragment{}, err
+	}
+	n, err := io.CopyBuffer(io.MultiWriter(f, h), io.LimitReader(r, fragmentSize), buf)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Fragment{}, fmt.Errorf("打包目录写分片 %d 失败: %w", index+1, err)
+	}
+	return Fragment{Index: index, Path: path, Offset: offset, Size: n, Hash: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// 上传 writeStreamFragment 写出的分片，然后立即删除本地文件
+func uploadStreamFragment(frag Fragment) (string, error) {
+	defer os.Remove(frag.Path)
+
+	fmt.Printf("\n[%d] 正在上传分片: %s（%d bytes）\n", frag.Index+1, filepath.Base(frag.Path), frag.Size)
+	start := time.Now()
+	root, err := uploadWithFallback(frag) // --stream 不能和 --fragment-retry-different-size 同时用，只会整片上传
+	if err != nil {
+		return "", fmt.Errorf("上传分片 %d 失败: %w", frag.Index+1, err)
+	}
+	slowReport.record("上传", frag.Index, root, time.Since(start))
+	metrics.addBytes("上传", frag.Size)
+	fmt.Printf("分片 %d 上传成功，root = %s\n", frag.Index+1, root)
+	return root, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// --fragment-retry-different-size：分片整片上传用完重试仍失败时（可能触发了节点的大小限制），
+// 把这个分片的上传数据切成更小的段分别上传。manifest 里该分片的 root 为空，改记 subs，下载时按顺序拼回
+var (
+	retrySubSize    string // 例如 100M
+	subFragmentSize int64  // 解析后的字节数，0 表示不启用
+)
+
+// SubFragment 是分段上传的一段，Offset 相对于分片的上传数据（压缩时是压缩后的数据）
+type SubFragment struct {
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Root   string `json:"root"`
+}
+
+// 本次运行中改为分段上传的分片，Index -> 各段；上传 worker 并发写入
+type subFragmentSet struct {
+	mu   sync.Mutex
+	subs map[int][]SubFragment
+}
+
+var subUploads = &subFragmentSet{subs: map[int][]SubFragment{}}
+
+func (s *subFragmentSet) set(index int, subs []SubFragment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[index] = subs
+}
+
+func (s *subFragmentSet) get(index int) []SubFragment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subs[index]
+}
+
+// 整片上传，失败后按 subFragmentSize 分段重传；分段成功时返回空 root，各段记在 subUploads
+func uploadWithFallback(frag Fragment) (string, error) {
+	if root, ok := dedupe.lookup(frag); ok {
+		fmt.Printf("分片 %d 的内容之前已上传过，复用 root %s（--dedupe-across-manifests）\n", frag.Index+1, root)
+		reportProgress(frag.Index, frag.Size, frag.Size)
+		webhook.fragmentUploaded(frag, "reused", root, nil, nil)
+		return root, nil
+	}
+	root, err := uploadWholeOrSubs(frag)
+	if err == nil {
+		fragmentUploadedAt.done(frag.Index)
+	}
+	switch {
+	case err != nil:
+		webhook.fragmentUploaded(frag, "failed", "", nil, err)
+	case root == "":
+		webhook.fragmentUploaded(frag, "uploaded", "", subUploads.get(frag.Index), nil)
+	default:
+		webhook.fragmentUploaded(frag, "uploaded", root, nil, nil)
+	}
+	return root, err
+}
+
+func uploadWholeOrSubs(frag Fragment) (string, error) {
+	reportProgress(frag.Index, 0, frag.Size)
+	root, err := withFragmentRetry("上传", frag.Index, fileSizeOrUnknown(frag.Path), func() (string, error) {
+		nonces.observe(frag.Index)
+		return storage.Upload(frag.Path)
+	}, nil)
+	if err == nil {
+		reportProgress(frag.Index, frag.Size, frag.Size)
+		dedupe.record(frag, root)
+	}
+	if err == nil || subFragmentSize <= 0 || runCtx.Err() != nil {
+		return root, err
+	}
+	info, serr := os.Stat(frag.Path)
+	if serr != nil || info.Size() <= subFragmentSize {
+		return "", err // 分片本身不比段大，分段没有意义
+	}
+
+	logrus.Warnf("分片 %d 整片上传失败，改为每段 %d bytes 分段上传: %v", frag.Index+1, subFragmentSize, err)
+	subs, serr := uploadSubFragments(frag, info.Size())
+	if serr != nil {
+		return "", fmt.Errorf("%w；分段上传也失败: %v", err, serr)
+	}
+	subUploads.set(frag.Index, subs)
+	reportProgress(frag.Index, frag.Size, frag.Size)
+	return "", nil
+}
+
+func uploadSubFragments(frag Fragment, total int64) ([]SubFragment, error) {
+	src, err := os.Open(frag.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var subs []SubFragment
+	buf := make([]byte, min(ioBufferSize, subFragmentSize))
+	for off := int64(0); off < total; off += subFragmentSize {
+		size := min(subFragmentSize, total-off)
+		part := fmt.Sprintf("%s.part%03d", frag.Path, len(subs))
+		if err := writeSection(part, src, off, size, buf); err != nil {
+			os.Remove(part)
+			return nil, err
+		}
+		root, err := withFragmentRetry("上传", frag.Index, size, func() (string, error) {
+			return storage.Upload(part)
+		}, nil)
+		os.Remove(part)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 段: %w", len(subs)+1, err)
+		}
+		fmt.Printf("分片 %d 第 %d 段上传成功（offset %d, %d bytes），root = %s\n", frag.Index+1, len(subs)+1, off, size, root)
+		subs = append(subs, SubFragment{Offset: off, Size: size, Root: root})
+		if off+size < total {
+			// 分段按上传数据计，压缩时换算成原始字节，保证不超过 frag.Size
+			reportProgress(frag.Index, frag.Size*(off+size)/total, frag.Size)
+		}
+	}
+	return subs, nil
+}
+
+func writeSection(dst string, src io.ReaderAt, off, size int64, buf []byte) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyBuffer(out, io.NewSectionReader(ctxReaderAt{runCtx, src}, off, size), buf)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// 按顺序下载各段并拼成分片的上传数据，返回临时文件路径
+func downloadSubFragments(frag ManifestFragment) (string, error) {
+	out, err := os.CreateTemp("", "0g-download-*.dat")
+	if err != nil {
+		return "", err
+	}
+	fail := func(err error) (string, error) {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	for i, sub := range frag.Subs {
+		tmpPath, err := downloadStoredRoot(frag, sub.Root)
+		if err != nil {
+			return fail(fmt.Errorf("第 %d 段: %w", i+1, err))
+		}
+		err = copyFragmentData(out, tmpPath, sub.Size, sub.Size)
+		os.Remove(tmpPath)
+		if err != nil {
+			return fail(fmt.Errorf("第 %d 段: %w", i+1, err))
+		}
+	}
+	if err := out.Close(); err != nil {
+		return fail(err)
+	}
+	return out.Name(), nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 模拟存储端的大小限制：超过 limit 的文件上传失败；文件名包含 fail 的也失败
+type sizeLimitStorage struct {
+	*countingStorage
+	limit int64
+	fail  string
+}
+
+func (s sizeLimitStorage) Upload(path string) (string, error) {
+	if info, err := os.Stat(path); err != nil || info.Size() > s.limit {
+		return "", errors.New("payload too large")
+	}
+	if s.fail != "" && strings.Contains(filepath.Base(path), s.fail) {
+		return "", errors.New("connection reset")
+	}
+	return s.countingStorage.Upload(path)
+}
+
+// 整片上传总是失败、分段上传成功：manifest 记录各段，文件照样能恢复；
+// 中断后 --resume 跳过已经分段上传完成的分片，不再重传它们的各段
+func TestFragmentRetryDifferentSize(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize, retrySubSize, fragmentRetries = 1000, "400", 0
+	compressCodec = "none" // 按原始大小触发存储端的限制
+	path := filepath.Join(dir, "data.bin")
+	data := writeTestFile(t, path, 3000, 7)
+	filePath, manifestPath = path, path+".manifest.json"
+	store := testStore()
+	storage = sizeLimitStorage{countingStorage: &countingStorage{fakeStorage: store}, limit: 400, fail: strings.TrimSuffix(fragmentFileName(2, 3), ".dat")}
+	if err := run(); err == nil {
+		t.Fatal("分片 3 的各段也上传失败时 run 应返回错误")
+	}
+
+	indexerURL = newFakeNodeServer(t, func(root string) *nodeFileInfo { return &nodeFileInfo{Finalized: true} }).URL
+	counter := &countingStorage{fakeStorage: store}
+	storage = sizeLimitStorage{countingStorage: counter, limit: 400}
+	resumeUpload = true
+	m := uploadTestFile(t, path)
+
+	if n := counter.uploadCount(); n != 3 {
+		t.Fatalf("--resume 应只上传分片 3 的 3 段，实际上传了 %d 次", n)
+	}
+	for _, frag := range m.Fragments {
+		if frag.Root != "" || len(frag.Subs) != 3 {
+			t.Fatalf("分片 %d 应记录 3 段、没有整片 root: root %q，%d 段", frag.Index, frag.Root, len(frag.Subs))
+		}
+	}
+	assertFileContent(t, path+".restored", data)
+
+	dlManifests = []string{manifestPath}
+	dlOutput = filepath.Join(dir, "out.bin")
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dlOutput, data)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// verify --fragments：只下载并校验选中的分片，重复的序号只校验一次
+func TestVerifyFragmentSubset(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 4500, 5)
+	m := uploadTestFile(t, path)
+	counter := useCountingStorage()
+
+	frags, err := selectFragments(m, "3, 1,3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() {
+		if err := verifyFragmentSubset(m, frags); err != nil {
+			t.Fatalf("未损坏的分片校验失败: %v", err)
+		}
+	})
+	want := []string{m.Fragments[3].Root, m.Fragments[1].Root}
+	if strings.Join(counter.downloads, ",") != strings.Join(want, ",") {
+		t.Fatalf("下载了 %v，期望只下载分片 4、2: %v", counter.downloads, want)
+	}
+	if !strings.Contains(out, "抽查的 2/5 个分片全部校验通过") {
+		t.Fatalf("输出缺少结论:\n%s", out)
+	}
+
+	// 存储里的分片 2 被损坏：校验失败，另一个选中的分片照常通过
+	os.WriteFile(filepath.Join(counter.dir, m.Fragments[1].Root), make([]byte, 1000), 0644)
+	fragmentRetries = 0
+	out = captureStdout(t, func() { err = verifyFragmentSubset(m, frags) })
+	if err == nil || exitCode(err) != ExitVerify {
+		t.Fatalf("分片损坏时应以校验错误退出，实际 %v", err)
+	}
+	if !strings.Contains(out, "分片 02 校验失败") || !strings.Contains(out, "分片 04 校验通过") {
+		t.Fatalf("应只报告分片 02 失败:\n%s", out)
+	}
+
+	if _, err := selectFragments(m, "7"); err == nil {
+		t.Fatal("不存在的分片序号应报错")
+	}
+}
+
+// --sample-verify：抽取 ceil(比例 × 分片数) 个不重复的分片，同一个 seed 每次抽到的相同
+func TestSampleFragmentsDeterministic(t *testing.T) {
+	m := &Manifest{}
+	for i := 0; i < 95; i++ {
+		m.Fragments = append(m.Fragments, ManifestFragment{Index: i})
+	}
+	first := fragmentIndices(sampleFragments(m, 0.1, 42))
+	if len(first) != 10 {
+		t.Fatalf("95 个分片按 10%% 应抽查 10 个，实际 %d: %v", len(first), first)
+	}
+	for i := 1; i < len(first); i++ {
+		if first[i] <= first[i-1] {
+			t.Fatalf("抽到的分片应按序号排列且不重复: %v", first)
+		}
+	}
+	for run := 0; run < 3; run++ {
+		if again := fragmentIndices(sampleFragments(m, 0.1, 42)); formatShardList(again) != formatShardList(first) {
+			t.Fatalf("同一个 seed 抽到了不同的分片: %v vs %v", again, first)
+		}
+	}
+	if other := fragmentIndices(sampleFragments(m, 0.1, 43)); formatShardList(other) == formatShardList(first) {
+		t.Fatalf("不同 seed 抽到了相同的分片: %v", other)
+	}
+
+	if n := len(sampleFragments(m, 0.001, 1)); n != 1 {
+		t.Fatalf("比例很小时至少抽查 1 个分片，实际 %d", n)
+	}
+	if n := len(sampleFragments(m, 1, 1)); n != 95 {
+		t.Fatalf("比例为 1 时应抽查全部分片，实际 %d", n)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	vcManifest string
+	vcName     string
+)
+
+// 恢复前确认 manifest 里的 root 仍然和链上记录一致（没被替换、大小没变）
+func newVerifyChainCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "verify-chain",
+		Short: "逐个查询 manifest 中 root 的链上提交记录，检查 root 和大小是否一致",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			m, err := loadManifestFor(vcManifest, namespace, vcName)
+			if err != nil {
+				return configError(fmt.Errorf("读取 manifest 失败: %w", err))
+			}
+			return verifyManifestAgainstChain(m)
+		},
+	}
+	c.Flags().StringVar(&vcManifest, "manifest", "", "manifest 路径（必填）")
+	c.Flags().StringVar(&vcName, "name", "", "多文件 manifest 中的原始文件名")
+	c.MarkFlagRequired("manifest")
+	return c
+}
+
+func verifyManifestAgainstChain(m *Manifest) error {
+	var bad int
+	for _, frag := range m.Fragments {
+		pieces := frag.pieces()
+		for i, p := range pieces {
+			name := fmt.Sprintf("分片 %02d", frag.Index+1)
+			if len(pieces) > 1 {
+				name += fmt.Sprintf(" 第 %d 段", i+1)
+			}
+			if !verifyPieceAgainstChain(name, p) {
+				bad++
+			}
+		}
+	}
+
+	if bad > 0 {
+		return verifyErrorf("%d/%d 个 root 与链上记录不一致", bad, len(m.Roots()))
+	}
+	fmt.Printf("全部 %d 个分片与链上记录一致\n", len(m.Fragments))
+	return nil
+}
+
+func verifyPieceAgainstChain(name string, p SubFragment) bool {
+	info, err := chainFileInfo(p.Root)
+	switch {
+	case err != nil:
+		fmt.Printf("%s 查询失败: %v\n", name, err)
+	case info == nil:
+		fmt.Printf("%s 链上没有记录: root %s\n", name, p.Root)
+	case !strings.EqualFold(info.Tx.DataMerkleRoot, p.Root):
+		fmt.Printf("%s root 不一致: manifest %s，链上 %s\n", name, p.Root, info.Tx.DataMerkleRoot)
+	case info.Tx.Size != p.Size:
+		fmt.Printf("%s 大小不一致: manifest %d bytes，链上 %d bytes\n", name, p.Size, info.Tx.Size)
+	default:
+		fmt.Printf("%s 一致（seq %d，%d bytes）\n", name, info.Tx.Seq, info.Tx.Size)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 假链上记录里分片 2 的大小和 manifest 不一致，verify-chain 应报告这一个不一致
+func TestVerifyManifestAgainstChainReportsSizeMismatch(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 2500, 0)
+	m := uploadTestFile(t, path)
+
+	sizes := map[string]int64{}
+	for _, frag := range m.Fragments {
+		sizes[frag.Root] = frag.uploadedSize() // 默认 zstd 压缩，链上记录的是压缩后的大小
+	}
+	sizes[m.Fragments[1].Root] = 999
+	indexerURL = newFakeNodeServer(t, func(root string) *nodeFileInfo {
+		info := &nodeFileInfo{Finalized: true}
+		info.Tx.DataMerkleRoot, info.Tx.Size = root, sizes[root]
+		return info
+	}).URL
+
+	var err error
+	out := captureStdout(t, func() { err = verifyManifestAgainstChain(m) })
+	if err == nil || exitCode(err) != ExitVerify || !strings.Contains(err.Error(), "1/3 个 root") {
+		t.Fatalf("期望 1 个 root 不一致，实际 %v", err)
+	}
+	if want := fmt.Sprintf("分片 02 大小不一致: manifest %d bytes，链上 999 bytes", m.Fragments[1].uploadedSize()); !strings.Contains(out, want) {
+		t.Fatalf("输出中没有报告分片 2 的大小不一致:\n%s", out)
+	}
+	if strings.Count(out, "一致（seq") != 2 {
+		t.Fatalf("其余 2 个分片应报告一致:\n%s", out)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const sdkModule = "github.com/0gfoundation/0g-storage-client"
+
+// 发布时用 -ldflags "-X main.buildVersion=v1.2.3" 注入
+var buildVersion = "dev"
+
+// 报告本工具版本、内嵌 SDK 版本，以及当前 --rpc / --indexer 是否可达
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "输出版本信息，并检查 RPC / indexer 是否可达",
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			fmt.Printf("版本:       %s (%s)\n", buildVersion, runtime.Version())
+			fmt.Printf("SDK 版本:   %s\n", sdkVersion())
+
+			var failed bool
+			rpcVer, d, err := pingEndpoint(rpcURL, "web3_clientVersion")
+			if err != nil {
+				failed = true
+				fmt.Printf("RPC:        %s 不可达: %v\n", rpcURL, err)
+			} else {
+				fmt.Printf("RPC:        %s 正常 (%v)，版本 %s\n", rpcURL, d.Round(time.Millisecond), rpcVer)
+			}
+			// indexer 没有版本接口，只检查能否正常响应
+			_, d, err = pingEndpoint(indexerURL, "indexer_getShardedNodes")
+			if err != nil {
+				failed = true
+				fmt.Printf("Indexer:    %s 不可达: %v\n", indexerURL, err)
+			} else {
+				fmt.Printf("Indexer:    %s 正常 (%v)\n", indexerURL, d.Round(time.Millisecond))
+			}
+			if failed {
+				return configError(fmt.Errorf("部分 endpoint 不可达"))
+			}
+			return nil
+		},
+	}
+}
+
+func sdkVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "未知"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == sdkModule {
+			if dep.Replace != nil {
+				return dep.Version + " => " + dep.Replace.Path + " " + dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "未知"
+}
+
+// 调一次 JSON-RPC，返回字符串结果（非字符串结果时为空）和耗时
+func pingEndpoint(url string, method string) (string, time.Duration, error) {
+	start := time.Now()
+	var raw interface{}
+	if err := rpcCall(url, method, []interface{}{}, &raw); err != nil {
+		return "", 0, err
+	}
+	s, _ := raw.(string)
+	return s, time.Since(start), nil
+}
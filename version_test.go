@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 假的 JSON-RPC endpoint：web3_clientVersion 返回版本号，其他方法返回空数组
+func newFakeVersionServer(t *testing.T, version string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		result := json.RawMessage(`[]`)
+		if req.Method == "web3_clientVersion" {
+			result, _ = json.Marshal(version)
+		}
+		json.NewEncoder(w).Encode(rpcResponse{Result: result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVersionCommandShowsEndpointVersion(t *testing.T) {
+	setupTest(t)
+	rpcURL = newFakeVersionServer(t, "zgchaind/v0.4.2").URL
+	indexerURL = newFakeVersionServer(t, "").URL
+	c := newVersionCmd()
+	var err error
+	out := captureStdout(t, func() { err = c.RunE(c, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "版本 zgchaind/v0.4.2") || !strings.Contains(out, "Indexer:    "+indexerURL+" 正常") {
+		t.Fatalf("输出中没有 endpoint 版本和状态:\n%s", out)
+	}
+}
+
+func TestVersionCommandReportsUnreachable(t *testing.T) {
+	setupTest(t)
+	srv := newFakeVersionServer(t, "x")
+	rpcURL, indexerURL = srv.URL, srv.URL
+	srv.Close()
+	c := newVersionCmd()
+	var err error
+	out := captureStdout(t, func() { err = c.RunE(c, nil) })
+	if err == nil || !strings.Contains(out, "不可达") {
+		t.Fatalf("endpoint 不可达时应报错，实际 %v:\n%s", err, out)
+	}
+}
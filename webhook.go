@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// --webhook：每个分片上传结束（成功、复用或失败）后向该地址 POST 一个 JSON 事件，方便接入外部系统。
+// 事件由单独的 goroutine 按顺序发送，失败重试，重试用完只记警告，不影响上传：队列满时丢弃新事件，
+// main 退出前最多等 webhookFlushTimeout 让队列发完。请求走 endpointClient，和 RPC 一样受 --proxy 等影响。
+// 给了 --webhook-secret 时带 X-Signature-256: sha256=<HMAC-SHA256(secret, body) 的十六进制>
+var (
+	webhookURL      string
+	webhookSecret   string
+	webhookRetries  = 3
+	webhookRetryGap = 2 * time.Second
+	webhookTimeout  = 10 * time.Second
+
+	webhookFlushTimeout = 30 * time.Second
+)
+
+const webhookQueueSize = 256
+
+type webhookEvent struct {
+	Event  string   `json:"event"` // 固定为 fragment_upload
+	Index  int      `json:"index"` // 与 manifest 的分片 index 一致，从 0 开始
+	Source string   `json:"source,omitempty"`
+	Root   string   `json:"root,omitempty"`
+	Subs   []string `json:"subs,omitempty"` // 分段上传时各段的 root
+	Size   int64    `json:"size"`
+	Status string   `json:"status"` // uploaded / reused / failed
+	Error  string   `json:"error,omitempty"`
+	Time   string   `json:"time"`
+}
+
+type webhookNotifier struct {
+	once  sync.Once
+	queue chan webhookEvent
+	done  chan struct{}
+}
+
+var webhook = &webhookNotifier{}
+
+func validateWebhook() error {
+	if webhookURL == "" {
+		if webhookSecret != "" {
+			return fmt.Errorf("--webhook-secret 需要配合 --webhook")
+		}
+		return nil
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("--webhook 地址无效: %q", webhookURL)
+	}
+	return nil
+}
+
+// 分片上传结束后调用；没有 --webhook 时什么都不做
+func (w *webhookNotifier) fragmentUploaded(frag Fragment, status, root string, subs []SubFragment, err error) {
+	if webhookURL == "" {
+		return
+	}
+	ev := webhookEvent{
+		Event:  "fragment_upload",
+		Index:  frag.Index,
+		Source: frag.Source,
+		Root:   root,
+		Size:   frag.Size,
+		Status: status,
+		Time:   time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, s := range subs {
+		ev.Subs = append(ev.Subs, s.Root)
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	w.once.Do(w.start)
+	select {
+	case w.queue <- ev:
+	default:
+		logrus.Warnf("webhook 队列已满（%d 个事件未发出），丢弃分片 %d 的事件", webhookQueueSize, frag.Index+1)
+	}
+}
+
+func (w *webhookNotifier) start() {
+	w.queue = make(chan webhookEvent, webhookQueueSize)
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		for ev := range w.queue {
+			if err := deliverWebhook(ev); err != nil {
+				logrus.Warnf("分片 %d 的 webhook 事件发送失败（已放弃）: %v", ev.Index+1, err)
+			}
+		}
+	}()
+}
+
+// main 退出前调用，等待队列里的事件发完；webhook 迟迟不响应时最多等 webhookFlushTimeout
+func (w *webhookNotifier) flush() {
+	if w.queue == nil {
+		return
+	}
+	close(w.queue)
+	select {
+	case <-w.done:
+	case <-time.After(webhookFlushTimeout):
+		logrus.Warnf("等待 webhook 事件发送超过 %s，剩余的事件不再发送", webhookFlushTimeout)
+	}
+}
+
+func deliverWebhook(ev webhookEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for attempt := 0; attempt <= webhookRetries; attempt++ {
+		if attempt > 0 {
+			logrus.Warnf("分片 %d 的 webhook 事件发送失败，%s 后第 %d 次重试: %v", ev.Index+1, webhookRetryGap, attempt, lastErr)
+			select {
+			case <-runCtx.Done():
+				return fmt.Errorf("%w（运行已取消）", lastErr)
+			case <-time.After(webhookRetryGap):
+			}
+		}
+		if lastErr = postWebhook(body); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func postWebhook(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhookSecret != "" {
+		req.Header.Set("X-Signature-256", "sha256="+webhookSignature(webhookSecret, body))
+	}
+	resp, err := endpointClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}
+
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
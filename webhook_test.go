@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// --webhook：每个分片上传后 POST 一个带 HMAC 签名的事件；webhook 第一次返回 500 时重试，上传不受影响
+func TestWebhookEventsDeliveredAndSigned(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	webhookSecret = "s3cret"
+	prevGap := webhookRetryGap
+	webhookRetryGap = time.Millisecond
+	t.Cleanup(func() { webhookRetryGap = prevGap })
+
+	var mu sync.Mutex
+	var events []webhookEvent
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			http.Error(w, "temporarily unavailable", http.StatusInternalServerError)
+			return
+		}
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get("X-Signature-256") != want {
+			t.Errorf("签名头 %q，期望 %q", r.Header.Get("X-Signature-256"), want)
+		}
+		var ev webhookEvent
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Errorf("事件不是 JSON: %v", err)
+		}
+		events = append(events, ev)
+	}))
+	t.Cleanup(srv.Close)
+	webhookURL = srv.URL
+	if err := validateWebhook(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "data.bin")
+	writeTestFile(t, path, 2500, 7)
+	m := uploadTestFile(t, path)
+	webhook.flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != len(m.Fragments) || requests != len(m.Fragments)+1 {
+		t.Fatalf("期望 %d 个事件（其中一个重试一次），实际收到 %d 个事件、%d 次请求", len(m.Fragments), len(events), requests)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Index < events[j].Index })
+	for i, ev := range events {
+		frag := m.Fragments[i]
+		if ev.Event != "fragment_upload" || ev.Index != frag.Index || ev.Root != frag.Root || ev.Size != frag.Size || ev.Status != "uploaded" {
+			t.Fatalf("分片 %d 的事件不对: %+v", i+1, ev)
+		}
+	}
+}
+
+// webhook 不响应时：入队不阻塞上传，队列满了丢弃新事件；flush 最多等 webhookFlushTimeout
+func TestWebhookSlowEndpointDoesNotBlock(t *testing.T) {
+	setupTest(t)
+	prevFlush := webhookFlushTimeout
+	webhookFlushTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { webhookFlushTimeout = prevFlush })
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	webhookURL = srv.URL
+
+	w := &webhookNotifier{}
+	start := time.Now()
+	for i := 0; i < webhookQueueSize+10; i++ {
+		w.fragmentUploaded(Fragment{Index: i, Size: 1}, "uploaded", "0xaa", nil, nil)
+	}
+	w.flush()
+	if took := time.Since(start); took > 5*time.Second {
+		t.Fatalf("webhook 不响应时入队和 flush 用了 %s，不应一直等待", took)
+	}
+
+	close(release) // 让后台的发送尽快结束，不影响后面的测试
+	<-w.done
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// --skip-zero-fragments：稀疏文件里常有大段的零。切分后检查每个分片，全零的不上传，
+// manifest 里记 zero（没有 root），下载时直接生成同样长度的零，不走网络
+var skipZeroFragments bool
+
+// 标记全零的分片，返回个数
+func markZeroFragments(frags []Fragment) (int, error) {
+	var n int
+	for i := range frags {
+		f, err := os.Open(frags[i].Path)
+		if err != nil {
+			return 0, err
+		}
+		zero, err := isAllZero(f)
+		f.Close()
+		if err != nil {
+			return 0, fmt.Errorf("检查分片 %d 失败: %w", frags[i].Index+1, err)
+		}
+		if zero {
+			frags[i].Zero = true
+			n++
+			fmt.Printf("分片 %d 全部是零，跳过上传\n", frags[i].Index+1)
+		}
+	}
+	return n, nil
+}
+
+func isAllZero(r io.Reader) (bool, error) {
+	buf := make([]byte, min(ioBufferSize, 1<<20))
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false, nil
+			}
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+func nonZeroFragments(frags []Fragment) []Fragment {
+	var out []Fragment
+	for _, frag := range frags {
+		if !frag.Zero {
+			out = append(out, frag)
+		}
+	}
+	return out
+}
+
+// 全零分片的“下载”：生成一个 storedSize 长的空洞文件
+func zeroFragmentFile(frag ManifestFragment) (string, error) {
+	f, err := os.CreateTemp("", "0g-download-*.dat")
+	if err != nil {
+		return "", err
+	}
+	err = f.Truncate(frag.storedSize())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// audit-local 用：源文件中该分片对应的区域是否仍然全零
+func localRegionZero(src io.ReaderAt, frag ManifestFragment) (bool, error) {
+	return isAllZero(io.NewSectionReader(ctxReaderAt{runCtx, src}, frag.Offset, frag.Size))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// --skip-zero-fragments：中间的全零分片不上传，manifest 标记 zero，下载时不经网络直接补零
+func TestSkipZeroFragments(t *testing.T) {
+	dir := setupTest(t)
+	fragmentSize = 1000
+	skipZeroFragments = true
+	src := filepath.Join(dir, "sparse.bin")
+	data := writeTestFile(t, src, 3000, 2)
+	for i := 1000; i < 2000; i++ {
+		data[i] = 0
+	}
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	counter := useCountingStorage()
+	m := uploadTestFile(t, src)
+	if n := counter.uploadCount(); n != 2 {
+		t.Fatalf("上传了 %d 个分片，全零分片不应上传", n)
+	}
+	if f := m.Fragments[1]; !f.Zero || f.Root != "" {
+		t.Fatalf("分片 2 应标记 zero 且没有 root: %+v", f)
+	}
+	if m.Fragments[0].Zero || m.Fragments[2].Zero {
+		t.Fatal("非零分片不应标记 zero")
+	}
+
+	counter.downloads = nil
+	dlManifests = []string{manifestPath}
+	dlOutput = filepath.Join(t.TempDir(), "out.bin")
+	if err := restoreFromManifest(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, dlOutput, data)
+	if n := counter.downloadCount(); n != 2 {
+		t.Fatalf("下载了 %d 个分片，全零分片不应经网络获取", n)
+	}
+}